@@ -1,123 +1,498 @@
 package main
 
 import (
+	"bufio"
 	"context"
+	"crypto/tls"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"log"
+	"log/slog"
+	"maps"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
 	"time"
 
+	"github.com/aristath/gollama-ui/internal/auth"
+	"github.com/aristath/gollama-ui/internal/certs"
 	"github.com/aristath/gollama-ui/internal/client"
+	"github.com/aristath/gollama-ui/internal/config"
 	"github.com/aristath/gollama-ui/internal/handlers"
+	"github.com/aristath/gollama-ui/internal/logging"
+	"github.com/aristath/gollama-ui/internal/metrics"
 	"github.com/aristath/gollama-ui/internal/modelmanager"
+	"github.com/aristath/gollama-ui/internal/ratelimit"
 	"github.com/aristath/gollama-ui/internal/server"
 )
 
+// configPollInterval is how often the config file is checked for changes
+const configPollInterval = 2 * time.Second
+
+// reloadable lists the settings applied when the config file is reloaded
+var reloadable = map[string]bool{
+	"logging.level":    true,
+	"logging.content":  true,
+	"tools.web_search": true,
+	"tools.feeds":      true,
+	"tools.sentinel":   true,
+	"chat.timeout":     true,
+}
+
 func main() {
+	// Flags are bound to the default configuration for -help; the loader
+	// applies the ones that were set over the config file and environment
+	config.BindFlags(flag.CommandLine, config.Default())
 	var (
-		host        = flag.String("host", "0.0.0.0", "Server host")
-		port        = flag.String("port", "3000", "Server port")
-		ollamaURL   = flag.String("ollama", "http://localhost:8080", "llama.cpp server URL")
-		ddgsURL     = flag.String("ddgs", "http://localhost:8000", "ddgs search service URL")
-		sentinelURL = flag.String("sentinel", "http://localhost:8081", "Sentinel portfolio API URL")
-		staticDir   = flag.String("static", "./web", "Static files directory")
-		configDir   = flag.String("config", "./config", "Configuration directory")
-		chatTimeout = flag.Duration("chat-timeout", 24*time.Hour, "Chat request timeout (e.g., 1h, 24h, 48h) - default 24h for slow hardware like RPi")
+		configDir    = flag.String("config", "./config", "Configuration directory")
+		configFile   = flag.String("config-file", "", "YAML config file, optional; GOLLAMA_* environment variables and flags override its settings (default <config>/config.yaml)")
+		hashPassword = flag.Bool("hash-password", false, "Read a password from stdin, print its bcrypt hash for -auth-config and exit")
 	)
 	flag.Parse()
 
-	// Validate static directory exists
-	absStaticDir, err := filepath.Abs(*staticDir)
-	if err != nil {
-		log.Fatalf("Failed to resolve static directory: %v", err)
+	if *hashPassword {
+		if err := printPasswordHash(os.Stdin, os.Stdout); err != nil {
+			log.Fatalf("Failed to hash password: %v", err)
+		}
+		return
+	}
+
+	configPath := *configFile
+	if configPath == "" {
+		configPath = filepath.Join(*configDir, "config.yaml")
 	}
 
-	if info, err := os.Stat(absStaticDir); err != nil || !info.IsDir() {
-		log.Fatalf("Static directory does not exist: %s", absStaticDir)
+	if err := os.MkdirAll(*configDir, 0755); err != nil {
+		log.Fatalf("Failed to create config directory: %v", err)
 	}
 
-	// Initialize llama.cpp client
-	ollamaClient, err := client.New(*ollamaURL)
+	// Import the settings files of earlier versions into the config file
+	if err := config.Migrate(configPath, *configDir); err != nil {
+		log.Fatalf("Failed to migrate settings: %v", err)
+	}
+	chatTimeoutSettingsPath := filepath.Join(*configDir, "chat-timeout-settings.json")
+	if timeout := handlers.NewChatTimeoutSettings(chatTimeoutSettingsPath).GetDuration(); timeout > 0 {
+		if err := config.MigrateFile(configPath, chatTimeoutSettingsPath, map[string]interface{}{"chat.timeout": timeout.String()}); err != nil {
+			log.Fatalf("Failed to migrate chat timeout settings: %v", err)
+		}
+	}
+
+	loader := config.NewLoader(configPath, flag.CommandLine, os.LookupEnv)
+	cfg, err := loader.Load()
 	if err != nil {
-		log.Fatalf("Failed to create llama.cpp client: %v", err)
+		log.Fatalf("Invalid configuration:\n%v", err)
 	}
 
-	// Initialize search and news clients for web search and news reading
-	searchClient := client.NewSearchClient(*ddgsURL)
-	customFeedsPath := filepath.Join(*configDir, "custom-feeds.json")
-	newsClient := client.NewNewsClient(customFeedsPath)
+	if err := logging.Setup(os.Stderr, cfg.Logging.Level, cfg.Logging.Format, cfg.Logging.Content); err != nil {
+		log.Fatalf("Invalid logging settings: %v", err)
+	}
 
-	// Initialize Sentinel portfolio client
-	sentinelClient := client.NewSentinelClient(*sentinelURL)
+	if err := handlers.ValidateContextStrategy(cfg.Chat.ContextStrategy); err != nil {
+		fatal("Invalid chat.context_strategy", "error", err)
+	}
 
-	// Initialize tool settings for managing which tools are enabled
-	toolSettingsPath := filepath.Join(*configDir, "tool-settings.json")
-	toolSettings := handlers.NewToolSettings(toolSettingsPath)
+	// The web UI is embedded unless a directory overrides it
+	var absStaticDir string
+	if cfg.Server.StaticDir != "" {
+		absStaticDir, err = filepath.Abs(cfg.Server.StaticDir)
+		if err != nil {
+			fatal("Failed to resolve static directory", "error", err)
+		}
+		if info, err := os.Stat(absStaticDir); err != nil || !info.IsDir() {
+			fatal("Static directory does not exist", "path", absStaticDir)
+		}
+	}
 
-	// Initialize chat timeout settings for dynamic timeout adjustment
-	chatTimeoutSettingsPath := filepath.Join(*configDir, "chat-timeout-settings.json")
-	chatTimeoutSettings := handlers.NewChatTimeoutSettings(chatTimeoutSettingsPath)
+	// Initialize the inference backend client
+	backendConfig := client.Config{BaseURL: cfg.Backend.URL}
+	if cfg.Backend.ConfigFile != "" {
+		backendConfig, err = client.LoadConfig(cfg.Backend.ConfigFile)
+		if err != nil {
+			fatal("Failed to load backend config", "error", err)
+		}
+		if backendConfig.BaseURL == "" {
+			backendConfig.BaseURL = cfg.Backend.URL
+		}
+	}
+	if cfg.Backend.APIKey != "" {
+		backendConfig.APIKey = cfg.Backend.APIKey
+	}
 
-	// Use the stored timeout if available, otherwise use the flag
-	effectiveTimeout := chatTimeoutSettings.GetDuration()
-	if effectiveTimeout == 0 {
-		effectiveTimeout = *chatTimeout
+	backendConfigs := []client.BackendConfig{{
+		Name:        "default",
+		Kind:        cfg.Backend.Kind,
+		Config:      backendConfig,
+		WaitSeconds: int(cfg.Backend.Wait.Seconds()),
+	}}
+	if cfg.Backend.BackendsFile != "" {
+		backendConfigs, err = client.LoadBackendsConfig(cfg.Backend.BackendsFile)
+		if err != nil {
+			fatal("Failed to load backends config", "error", err)
+		}
 	}
 
-	// Health check ddgs service on startup
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	if err := searchClient.HealthCheck(ctx); err != nil {
-		log.Printf("Warning: ddgs service unavailable: %v", err)
-		log.Printf("Web search and news functionality will be disabled")
-	} else {
-		log.Printf("ddgs service OK: %s", *ddgsURL)
+	ollamaClient, err := client.NewRouter(backendConfigs)
+	if err != nil {
+		fatal("Failed to create backend clients", "error", err)
 	}
+	go ollamaClient.Monitor(context.Background(), cfg.Services.HealthInterval)
 
-	// Health check Sentinel service on startup
-	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	if err := sentinelClient.HealthCheck(ctx); err != nil {
-		log.Printf("Warning: Sentinel service unavailable: %v", err)
-		log.Printf("Portfolio analysis functionality will be disabled")
-	} else {
-		log.Printf("Sentinel service OK: %s", *sentinelURL)
+	// Initialize search and news clients for web search and news reading
+	searchClient := client.NewSearchClient(cfg.Services.DDGSURL)
+	// Custom feeds are kept in the config file. The news client reads them
+	// from a file written at startup, and feeds changed in the UI are saved
+	// to both.
+	newsFeedsPath := filepath.Join(*configDir, "news-feeds.json")
+	if err := writeFeeds(newsFeedsPath, cfg.Tools.CustomFeeds); err != nil {
+		fatal("Failed to write news feeds", "error", err)
 	}
+	newsClient := client.NewNewsClient(newsFeedsPath)
+	var feedsMu sync.Mutex
+	appliedFeeds := cfg.Tools.CustomFeeds
+	feedSettings := handlers.NewFeedSettings(func(feeds map[string]string) error {
+		if err := config.Update(configPath, map[string]interface{}{"tools.custom_feeds": feeds}); err != nil {
+			return err
+		}
+		feedsMu.Lock()
+		appliedFeeds = feeds
+		feedsMu.Unlock()
+		return nil
+	})
+
+	// Initialize Sentinel portfolio client
+	sentinelClient := client.NewSentinelClient(cfg.Services.SentinelURL)
+
+	// Tool settings are kept in the config file; changes made in the UI are
+	// saved there
+	toolSettings := handlers.NewToolSettings("")
+	toolSettings.Reset(cfg.Tools.WebSearch, cfg.Tools.Feeds, cfg.Tools.Sentinel)
+	toolSettings.SetSaver(func(webSearch, feeds, sentinel bool) error {
+		return config.Update(configPath, map[string]interface{}{
+			"tools.web_search": webSearch,
+			"tools.feeds":      feeds,
+			"tools.sentinel":   sentinel,
+		})
+	})
 
 	// Initialize tool executor for function calling
 	toolExecutor := handlers.NewToolExecutor(searchClient, newsClient, sentinelClient, toolSettings)
 
+	// Authenticated users keep their own conversations, personas and tool
+	// settings here
+	usersDir := filepath.Join(*configDir, "users")
+	userTools := handlers.NewUserToolSettings(usersDir, toolSettings)
+	toolExecutor.SetUserToolSettings(userTools)
+
 	// Initialize handlers
 	modelsHandler := handlers.NewModelsHandler(ollamaClient)
-	chatHandler := handlers.NewChatHandlerWithTimeout(ollamaClient, toolExecutor, effectiveTimeout)
+	scheduler, err := handlers.NewScheduler(ollamaClient, cfg.Chat.Slots)
+	if err != nil {
+		fatal("Invalid chat.slots", "error", err)
+	}
+	chatHandler := handlers.NewChatHandlerWithTimeout(scheduler, toolExecutor, cfg.Chat.Timeout)
+	contextWindow := handlers.NewContextWindow(ollamaClient, handlers.ContextConfig{
+		Strategy:      cfg.Chat.ContextStrategy,
+		KeepTurns:     cfg.Chat.ContextKeepTurns,
+		ReserveTokens: cfg.Chat.ContextReserve,
+	})
+	conversationStore := handlers.NewConversationStore(filepath.Join(*configDir, "conversations"))
+	conversationStore.SetUsersDir(usersDir)
+	contextWindow.SetSummarizer(handlers.NewSummarizer(scheduler, conversationStore))
+	chatHandler.SetContextWindow(contextWindow)
+	chatHandler.SetResumeGrace(cfg.Chat.ResumeGrace)
+	chatHandler.SetConversationStore(conversationStore)
+	if err := chatHandler.SetNotifier(cfg.Chat.NotifyURL, cfg.Chat.NotifyFormat, cfg.Chat.NotifyHosts...); err != nil {
+		fatal("Invalid notification settings", "error", err)
+	}
+	limits, err := ratelimit.LoadConfig(cfg.Server.RateLimitsFile)
+	if err != nil {
+		fatal("Invalid rate limits", "error", err)
+	}
+	chatHandler.SetToolLimiter(ratelimit.NewLimiter(limits.Tools))
+	modelPresets := handlers.NewModelPresets(filepath.Join(*configDir, "model-presets.json"))
+	chatHandler.SetModelPresets(modelPresets)
+	presetsHandler := handlers.NewPresetsHandler(modelPresets)
+	personaStore := handlers.NewPersonaStore(filepath.Join(*configDir, "personas.json"))
+	personaStore.SetUsersDir(usersDir)
+	chatHandler.SetPersonaStore(personaStore)
+	personasHandler := handlers.NewPersonasHandler(personaStore)
 	unloadHandler := handlers.NewUnloadHandler(ollamaClient)
+	backendsHandler := handlers.NewBackendsHandler(ollamaClient)
 	settingsHandler := handlers.NewSettingsHandler(newsClient, toolSettings)
-	settingsHandler.SetChatTimeoutSettings(chatTimeoutSettings)
+	chatTimeoutHandler := handlers.NewChatTimeoutHandler(chatHandler, func(timeout time.Duration) error {
+		return config.Update(configPath, map[string]interface{}{"chat.timeout": timeout.String()})
+	})
+
+	// Keep checking the services tools depend on, and that data can be saved.
+	// Tools are disabled while their service is down.
+	services := handlers.NewServiceMonitor(map[string]handlers.ServiceChecker{
+		handlers.ServiceDDGS:      searchClient,
+		handlers.ServiceSentinel:  sentinelClient,
+		handlers.ServiceFeeds:     handlers.NewFeedsChecker(newsClient),
+		handlers.ServiceConfigDir: handlers.DirChecker(*configDir),
+	})
+	toolExecutor.SetServiceMonitor(services)
+	go services.Monitor(context.Background(), cfg.Services.HealthInterval)
+
+	healthHandler := handlers.NewHealthHandler(ollamaClient, services)
+	healthHandler.SetToolExecutor(toolExecutor)
+	healthHandler.SetRequiredServices(handlers.ServiceConfigDir)
+	userSettingsHandler := handlers.NewUserSettingsHandler(userTools)
+	userSettingsHandler.SetToolExecutor(toolExecutor)
+
+	// Collect Prometheus metrics, served at /metrics
+	registry := metrics.NewRegistry()
+	handlerMetrics := handlers.NewMetrics(registry)
+	chatHandler.SetMetrics(handlerMetrics)
+	toolExecutor.SetMetrics(handlerMetrics)
+	scheduler.RegisterMetrics(registry)
+	backendsHandler.RegisterMetrics(registry)
+	services.RegisterMetrics(registry)
+
+	// Authenticate API requests
+	var authenticator *auth.Authenticator
+	if cfg.Auth.ConfigFile != "" {
+		authConfig, err := auth.LoadConfig(cfg.Auth.ConfigFile)
+		if err != nil {
+			fatal("Failed to load auth config", "error", err)
+		}
+		authenticator, err = auth.New(authConfig)
+		if err != nil {
+			fatal("Invalid auth config", "error", err)
+		}
+		userStore, err := auth.NewUserStore(filepath.Join(*configDir, "users.json"))
+		if err != nil {
+			fatal("Failed to load users", "error", err)
+		}
+		authenticator.SetUserStore(userStore)
+	} else {
+		slog.Warn("Authentication is disabled, anyone who can reach the server can use it; see -auth-config", "host", cfg.Server.Host)
+	}
 
 	// Initialize model manager for model switching
 	manager := modelmanager.New(
-		"/mnt/nvme/llm/models",                // Models directory
-		"/mnt/nvme/llm/config/llama-server.conf",     // Config file path
-		*ollamaURL,                    // Base URL for health checks
+		cfg.Models.Dir,
+		cfg.Models.ServerConfig,
+		cfg.Backend.URL, // Base URL for health checks
 	)
 	loadHandler := handlers.NewLoadHandler(manager)
 
 	// Create server
-	srv := server.New(modelsHandler, chatHandler, unloadHandler, loadHandler, settingsHandler, absStaticDir)
+	srv, err := server.New(server.Handlers{
+		Models:   modelsHandler,
+		Chat:     chatHandler,
+		Unload:   unloadHandler,
+		Load:     loadHandler,
+		Settings: settingsHandler,
+		Presets:  presetsHandler,
+		Personas: personasHandler,
+		Backends: backendsHandler,
+		Health:   healthHandler,
+		Metrics:  registry,
+		Auth:     authenticator,
+
+		UserSettings: userSettingsHandler,
+		ChatTimeout:  chatTimeoutHandler,
+		Feeds:        feedSettings,
+	}, server.Config{
+		StaticDir:      absStaticDir,
+		AllowedOrigins: cfg.Server.AllowedOrigins,
+		RateLimits:     limits,
+	})
+	if err != nil {
+		fatal("Failed to create server", "error", err)
+	}
+
+	// Serve HTTPS when a certificate is provided or requested
+	var tlsConfig *tls.Config
+	tlsSettings := cfg.Server.TLS
+	if tlsSettings.SelfSigned || tlsSettings.Cert != "" || tlsSettings.Key != "" {
+		cert, err := loadCertificate(tlsSettings.Cert, tlsSettings.Key, tlsSettings.SelfSigned, *configDir, cfg.Server.Host)
+		if err != nil {
+			fatal("Failed to set up TLS", "error", err)
+		}
+		tlsConfig = &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   tls.VersionTLS12,
+		}
+		slog.Info("TLS enabled", "fingerprint", certs.Fingerprint(cert))
+	}
 
 	// Start HTTP server
-	addr := fmt.Sprintf("%s:%s", *host, *port)
-	log.Printf("Starting server on %s", addr)
-	log.Printf("llama.cpp URL: %s", *ollamaURL)
-	log.Printf("ddgs search URL: %s", *ddgsURL)
-	log.Printf("Sentinel API URL: %s", *sentinelURL)
-	log.Printf("Chat timeout: %v", *chatTimeout)
-	log.Printf("Serving static files from: %s", absStaticDir)
-
-	if err := http.ListenAndServe(addr, srv); err != nil {
-		log.Fatalf("Server failed: %v", err)
-	}
-}
\ No newline at end of file
+	addr := fmt.Sprintf("%s:%s", cfg.Server.Host, cfg.Server.Port)
+	slog.Info("Starting server", "addr", addr, "tls", tlsConfig != nil)
+	for _, b := range backendConfigs {
+		slog.Info("Backend", "name", b.Name, "kind", b.Kind, "url", b.BaseURL)
+	}
+	slog.Info("Settings",
+		"config_file", configPath,
+		"ddgs_url", cfg.Services.DDGSURL,
+		"sentinel_url", cfg.Services.SentinelURL,
+		"chat_timeout", cfg.Chat.Timeout,
+		"slots", cfg.Chat.Slots,
+		"context_strategy", cfg.Chat.ContextStrategy,
+		"context_keep_turns", cfg.Chat.ContextKeepTurns,
+		"context_reserve", cfg.Chat.ContextReserve,
+		"static_dir", absStaticDir,
+		"allowed_origins", cfg.Server.AllowedOrigins,
+	)
+
+	// Requests are cancelled once running chats are drained on shutdown,
+	// ending streams that never finish by themselves
+	baseCtx, cancelRequests := context.WithCancel(context.Background())
+	defer cancelRequests()
+	httpServer := &http.Server{
+		Addr:        addr,
+		Handler:     srv,
+		TLSConfig:   tlsConfig,
+		BaseContext: func(net.Listener) context.Context { return baseCtx },
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		if tlsConfig == nil {
+			serveErr <- httpServer.ListenAndServe()
+			return
+		}
+		if tlsSettings.HTTPRedirect != "" {
+			go func() {
+				slog.Info("Redirecting HTTP to HTTPS", "addr", tlsSettings.HTTPRedirect)
+				if err := http.ListenAndServe(tlsSettings.HTTPRedirect, certs.RedirectHandler(cfg.Server.Port)); err != nil {
+					fatal("HTTP redirect failed", "error", err)
+				}
+			}()
+		}
+		serveErr <- httpServer.ListenAndServeTLS("", "")
+	}()
+
+	// Reload the config file on SIGHUP and when it changes. Logging, tool and
+	// chat timeout settings apply at once; others need a restart.
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	current := cfg
+	go loader.Watch(baseCtx, configPollInterval, reload, func(next *config.Config) {
+		if err := logging.SetLevel(next.Logging.Level); err != nil {
+			slog.Error("Failed to apply log level", "error", err)
+		}
+		logging.SetContent(next.Logging.Content)
+		toolSettings.Reset(next.Tools.WebSearch, next.Tools.Feeds, next.Tools.Sentinel)
+		chatHandler.SetChatTimeout(next.Chat.Timeout)
+
+		for _, name := range config.Changed(current, next) {
+			if name == "tools.custom_feeds" {
+				// Feeds saved in the UI are already in use
+				feedsMu.Lock()
+				applied := maps.Equal(appliedFeeds, next.Tools.CustomFeeds)
+				feedsMu.Unlock()
+				if applied {
+					continue
+				}
+			}
+			if !reloadable[name] {
+				slog.Warn("Setting changed, restart to apply it", "setting", name)
+			}
+		}
+		current = next
+		slog.Info("Configuration reloaded")
+	})
+
+	// Shut down gracefully on SIGINT and SIGTERM, as sent by systemctl stop
+	signals, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	select {
+	case err := <-serveErr:
+		fatal("Server failed", "error", err)
+	case <-signals.Done():
+	}
+	stop() // A second signal stops at once
+
+	slog.Info("Shutting down, draining running chats", "drain_timeout", cfg.Server.DrainTimeout)
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), cfg.Server.DrainTimeout)
+	defer cancelDrain()
+	chatHandler.Shutdown(drainCtx)
+
+	cancelRequests()
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelShutdown()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		slog.Warn("Failed to close connections", "error", err)
+	}
+	slog.Info("Server stopped")
+}
+
+// loadCertificate loads the certificate given with -tls-cert and -tls-key, or
+// with -tls-self-signed creates one on first run
+func loadCertificate(certFile, keyFile string, selfSigned bool, configDir, host string) (tls.Certificate, error) {
+	if !selfSigned {
+		if certFile == "" || keyFile == "" {
+			return tls.Certificate{}, fmt.Errorf("-tls-cert and -tls-key must be set together")
+		}
+		return certs.Load(certFile, keyFile)
+	}
+
+	if certFile == "" {
+		certFile = filepath.Join(configDir, "tls", "cert.pem")
+	}
+	if keyFile == "" {
+		keyFile = filepath.Join(configDir, "tls", "key.pem")
+	}
+
+	hosts := certs.DefaultHosts(host)
+	cert, created, err := certs.LoadOrCreateSelfSigned(certFile, keyFile, hosts)
+	if err != nil {
+		return cert, err
+	}
+	if created {
+		slog.Info("Created a self-signed certificate", "cert", certFile, "hosts", hosts)
+	}
+	return cert, nil
+}
+
+// printPasswordHash reads a password from the first line of r and writes its
+// bcrypt hash to w
+func printPasswordHash(r io.Reader, w io.Writer) error {
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return err
+	}
+	password := strings.TrimRight(line, "\r\n")
+	if password == "" {
+		return fmt.Errorf("empty password")
+	}
+
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, hash)
+	return err
+}
+
+// writeFeeds writes custom feeds to the file the news client reads them from
+func writeFeeds(path string, feeds map[string]string) error {
+	if feeds == nil {
+		feeds = map[string]string{}
+	}
+	data, err := json.MarshalIndent(feeds, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal feeds: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write feeds: %w", err)
+	}
+	return nil
+}
+
+// fatal logs an error and exits
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
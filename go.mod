@@ -3,7 +3,10 @@ module github.com/aristath/gollama-ui
 go 1.25.5
 
 require (
+	github.com/andybalholm/brotli v1.2.0
 	github.com/go-chi/chi/v5 v5.2.3
 	github.com/go-chi/cors v1.2.2
 	github.com/liliang-cn/ollama-go v0.2.1
+	golang.org/x/crypto v0.45.0
+	gopkg.in/yaml.v3 v3.0.1
 )
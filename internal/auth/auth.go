@@ -0,0 +1,307 @@
+// Package auth authenticates clients of the web UI and API with static API
+// tokens, password sign-in with session cookies, or a header set by a trusted
+// reverse proxy, and restricts routes to roles.
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Roles
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// Authentication methods
+const (
+	MethodToken   = "token"
+	MethodSession = "session"
+	MethodProxy   = "proxy"
+	MethodNone    = "none"
+)
+
+// defaultProxyHeader names the user in proxy mode
+const defaultProxyHeader = "X-Forwarded-User"
+
+// defaultSessionTTL is how long a session lasts unless configured
+const defaultSessionTTL = 7 * 24 * time.Hour
+
+// Identity is an authenticated client
+type Identity struct {
+	Name   string `json:"name"`
+	Role   string `json:"role"`
+	Method string `json:"method"`
+}
+
+// IsAdmin reports whether the identity has the admin role
+func (i Identity) IsAdmin() bool {
+	return i.Role == RoleAdmin
+}
+
+type identityKey struct{}
+
+// WithIdentity returns a context carrying an identity
+func WithIdentity(ctx context.Context, id Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, id)
+}
+
+// FromContext returns the identity carried by ctx
+func FromContext(ctx context.Context) (Identity, bool) {
+	id, ok := ctx.Value(identityKey{}).(Identity)
+	return id, ok
+}
+
+// token is a static API token
+type token struct {
+	value    []byte
+	identity Identity
+}
+
+// user is a user signing in with a password
+type user struct {
+	hash []byte
+	role string
+}
+
+// Authenticator authenticates requests. A nil *Authenticator disables
+// authentication and treats every client as an admin.
+type Authenticator struct {
+	tokens []token
+	users  map[string]user
+	store  *UserStore
+
+	secret        []byte
+	ttl           time.Duration
+	secureCookies bool
+
+	proxyHeader  string
+	proxyTrusted []*net.IPNet
+	proxyAdmins  map[string]bool
+
+	dummyOnce sync.Once
+	dummyHash []byte
+}
+
+// New creates an authenticator from its configuration
+func New(cfg Config) (*Authenticator, error) {
+	a := &Authenticator{
+		users:         make(map[string]user),
+		ttl:           defaultSessionTTL,
+		secureCookies: cfg.Session.Secure,
+	}
+
+	for i, t := range cfg.Tokens {
+		role, err := parseRole(t.Role)
+		if err != nil {
+			return nil, fmt.Errorf("token %d: %w", i, err)
+		}
+		if len(t.Token) < 16 {
+			return nil, fmt.Errorf("token %d: tokens must be at least 16 characters", i)
+		}
+		name := t.Name
+		if name == "" {
+			name = fmt.Sprintf("token-%d", i)
+		}
+		if !ValidName(name) {
+			return nil, fmt.Errorf("token %d: invalid name: %q", i, name)
+		}
+		a.tokens = append(a.tokens, token{
+			value:    []byte(t.Token),
+			identity: Identity{Name: name, Role: role, Method: MethodToken},
+		})
+	}
+
+	for _, u := range cfg.Users {
+		if !ValidName(u.Username) {
+			return nil, fmt.Errorf("invalid username: %q", u.Username)
+		}
+		if _, ok := a.users[u.Username]; ok {
+			return nil, fmt.Errorf("user %s: duplicate username", u.Username)
+		}
+		role, err := parseRole(u.Role)
+		if err != nil {
+			return nil, fmt.Errorf("user %s: %w", u.Username, err)
+		}
+		if _, err := bcrypt.Cost([]byte(u.PasswordHash)); err != nil {
+			return nil, fmt.Errorf("user %s: password_hash is not a bcrypt hash", u.Username)
+		}
+		a.users[u.Username] = user{hash: []byte(u.PasswordHash), role: role}
+	}
+
+	if cfg.Session.TTL != "" {
+		ttl, err := time.ParseDuration(cfg.Session.TTL)
+		if err != nil || ttl <= 0 {
+			return nil, fmt.Errorf("invalid session ttl: %s", cfg.Session.TTL)
+		}
+		a.ttl = ttl
+	}
+	if cfg.Session.Secret != "" {
+		if len(cfg.Session.Secret) < 32 {
+			return nil, errors.New("session secret must be at least 32 characters")
+		}
+		a.secret = []byte(cfg.Session.Secret)
+	} else {
+		a.secret = make([]byte, 32)
+		if _, err := rand.Read(a.secret); err != nil {
+			return nil, fmt.Errorf("failed to generate session secret: %w", err)
+		}
+	}
+
+	if cfg.Proxy != nil {
+		if len(cfg.Proxy.TrustedProxies) == 0 {
+			return nil, errors.New("proxy mode needs trusted_proxies")
+		}
+		for _, p := range cfg.Proxy.TrustedProxies {
+			network, err := parseNetwork(p)
+			if err != nil {
+				return nil, err
+			}
+			a.proxyTrusted = append(a.proxyTrusted, network)
+		}
+		a.proxyHeader = cfg.Proxy.Header
+		if a.proxyHeader == "" {
+			a.proxyHeader = defaultProxyHeader
+		}
+		a.proxyAdmins = make(map[string]bool)
+		for _, name := range cfg.Proxy.Admins {
+			a.proxyAdmins[name] = true
+		}
+	}
+
+	if len(a.tokens) == 0 && len(a.users) == 0 && a.proxyHeader == "" {
+		return nil, errors.New("no tokens, users or proxy configured")
+	}
+
+	return a, nil
+}
+
+// parseRole validates a role, defaulting to the user role
+func parseRole(role string) (string, error) {
+	switch role {
+	case "":
+		return RoleUser, nil
+	case RoleUser, RoleAdmin:
+		return role, nil
+	default:
+		return "", fmt.Errorf("unknown role: %s", role)
+	}
+}
+
+// parseNetwork parses an IP or CIDR
+func parseNetwork(s string) (*net.IPNet, error) {
+	if !strings.Contains(s, "/") {
+		if ip := net.ParseIP(s); ip != nil && ip.To4() != nil {
+			s += "/32"
+		} else {
+			s += "/128"
+		}
+	}
+
+	_, network, err := net.ParseCIDR(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid trusted proxy: %s", s)
+	}
+	return network, nil
+}
+
+// Authenticate identifies the client of a request from, in order, the proxy
+// header, a bearer token or a session cookie. Tokens and proxy users share
+// the data of password users with the same name.
+func (a *Authenticator) Authenticate(r *http.Request) (Identity, bool) {
+	if a.proxyHeader != "" && a.fromTrustedProxy(r) {
+		if name := r.Header.Get(a.proxyHeader); ValidName(name) {
+			role := RoleUser
+			if a.proxyAdmins[name] {
+				role = RoleAdmin
+			}
+			return Identity{Name: name, Role: role, Method: MethodProxy}, true
+		}
+	}
+
+	if value, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		for _, t := range a.tokens {
+			if subtle.ConstantTimeCompare(t.value, []byte(value)) == 1 {
+				return t.identity, true
+			}
+		}
+		return Identity{}, false
+	}
+
+	if cookie, err := r.Cookie(SessionCookie); err == nil {
+		if name, u, ok := a.verifySession(cookie.Value, time.Now()); ok {
+			return Identity{Name: name, Role: u.role, Method: MethodSession}, true
+		}
+	}
+
+	return Identity{}, false
+}
+
+// fromTrustedProxy reports whether the request comes from a trusted proxy
+func (a *Authenticator) fromTrustedProxy(r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, network := range a.proxyTrusted {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Middleware rejects unauthenticated requests with 401 and stores the
+// identity of authenticated ones in their context
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a == nil {
+			next.ServeHTTP(w, r.WithContext(WithIdentity(r.Context(), Identity{Role: RoleAdmin, Method: MethodNone})))
+			return
+		}
+
+		id, ok := a.Authenticate(r)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="gollama-ui"`)
+			http.Error(w, "authentication required", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(WithIdentity(r.Context(), id)))
+	})
+}
+
+// RequireAdmin rejects requests of non-admins with 403. It must run after
+// Middleware.
+func RequireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if id, ok := FromContext(r.Context()); !ok || !id.IsAdmin() {
+			http.Error(w, "admin role required", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// HashPassword returns the bcrypt hash of a password for the users list
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Config lists the ways clients can authenticate. Any combination of the
+// methods can be enabled.
+type Config struct {
+	Tokens  []TokenConfig `json:"tokens,omitempty"`
+	Users   []UserConfig  `json:"users,omitempty"`
+	Session SessionConfig `json:"session,omitempty"`
+	Proxy   *ProxyConfig  `json:"proxy,omitempty"`
+}
+
+// TokenConfig is a static API token, sent as "Authorization: Bearer <token>"
+type TokenConfig struct {
+	Name  string `json:"name"`
+	Token string `json:"token"`
+	Role  string `json:"role,omitempty"` // user (default) or admin
+}
+
+// UserConfig is a user signing in with a password through the web UI
+type UserConfig struct {
+	Username     string `json:"username"`
+	PasswordHash string `json:"password_hash"` // bcrypt hash, see -hash-password
+	Role         string `json:"role,omitempty"`
+}
+
+// SessionConfig configures the session cookies of signed-in users
+type SessionConfig struct {
+	// Secret signs session cookies. When empty a random secret is used and
+	// sessions end when the server restarts.
+	Secret string `json:"secret,omitempty"`
+	// TTL is how long a session lasts, e.g. "24h" (default 7 days)
+	TTL string `json:"ttl,omitempty"`
+	// Secure marks cookies as HTTPS-only even when the server itself is
+	// reached over HTTP, e.g. behind a TLS-terminating proxy
+	Secure bool `json:"secure,omitempty"`
+}
+
+// ProxyConfig trusts a header set by an authenticating reverse proxy, such as
+// oauth2-proxy or Authelia, to name the user
+type ProxyConfig struct {
+	Header         string   `json:"header,omitempty"` // default X-Forwarded-User
+	TrustedProxies []string `json:"trusted_proxies"`  // IPs or CIDRs the header is accepted from
+	Admins         []string `json:"admins,omitempty"` // users given the admin role
+}
+
+// LoadConfig reads the auth configuration from a JSON file
+func LoadConfig(path string) (Config, error) {
+	var cfg Config
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, fmt.Errorf("failed to read auth config: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("failed to parse auth config: %w", err)
+	}
+
+	return cfg, nil
+}
@@ -0,0 +1,171 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// SessionCookie holds the session of a signed-in user
+const SessionCookie = "gollama_session"
+
+// LoginRequest signs a user in
+type LoginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// signSession returns a session cookie value for a user, valid until expires
+// or until the user's password changes
+func (a *Authenticator) signSession(name string, u user, expires time.Time) string {
+	payload := strconv.FormatInt(expires.Unix(), 10) + "|" + a.fingerprint(u) + "|" + name
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." +
+		base64.RawURLEncoding.EncodeToString(a.mac(payload))
+}
+
+// verifySession returns the user of a session cookie value that is correctly
+// signed, not expired at now, and signed with the user's current password
+func (a *Authenticator) verifySession(value string, now time.Time) (string, user, bool) {
+	encoded, signature, ok := strings.Cut(value, ".")
+	if !ok {
+		return "", user{}, false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", user{}, false
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, a.mac(string(payload))) {
+		return "", user{}, false
+	}
+
+	parts := strings.SplitN(string(payload), "|", 3)
+	if len(parts) != 3 {
+		return "", user{}, false
+	}
+	unix, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || now.Unix() >= unix {
+		return "", user{}, false
+	}
+	name := parts[2]
+	u, ok := a.lookupUser(name)
+	if !ok || !hmac.Equal([]byte(parts[1]), []byte(a.fingerprint(u))) {
+		return "", user{}, false
+	}
+	return name, u, true
+}
+
+// fingerprint identifies the password hash of a user in their sessions, so
+// changing or resetting the password ends them
+func (a *Authenticator) fingerprint(u user) string {
+	return hex.EncodeToString(a.mac(string(u.hash))[:8])
+}
+
+// mac signs a session payload
+func (a *Authenticator) mac(payload string) []byte {
+	h := hmac.New(sha256.New, a.secret)
+	h.Write([]byte(payload))
+	return h.Sum(nil)
+}
+
+// checkPassword reports whether password is the password of a user. Unknown
+// users are checked against a random hash so they take as long to reject.
+func (a *Authenticator) checkPassword(name, password string) (user, bool) {
+	u, ok := a.lookupUser(name)
+	hash := u.hash
+	if !ok {
+		hash = a.unknownUserHash()
+	}
+	if bcrypt.CompareHashAndPassword(hash, []byte(password)) != nil || !ok {
+		return user{}, false
+	}
+	return u, true
+}
+
+// unknownUserHash returns a hash no password matches, at the cost of the
+// configured users' hashes
+func (a *Authenticator) unknownUserHash() []byte {
+	a.dummyOnce.Do(func() {
+		cost := bcrypt.DefaultCost
+		for _, u := range a.users {
+			if c, err := bcrypt.Cost(u.hash); err == nil {
+				cost = c
+			}
+			break
+		}
+		password := make([]byte, 32)
+		rand.Read(password)
+		a.dummyHash, _ = bcrypt.GenerateFromPassword(password, cost)
+	})
+	return a.dummyHash
+}
+
+// Login handles POST /api/auth/login, setting a session cookie for a user
+// with a valid password
+func (a *Authenticator) Login(w http.ResponseWriter, r *http.Request) {
+	if len(a.users) == 0 && a.store == nil {
+		http.Error(w, "password sign-in is not enabled", http.StatusNotFound)
+		return
+	}
+
+	var req LoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	u, ok := a.checkPassword(req.Username, req.Password)
+	if !ok {
+		http.Error(w, "invalid username or password", http.StatusUnauthorized)
+		return
+	}
+
+	expires := time.Now().Add(a.ttl)
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookie,
+		Value:    a.signSession(req.Username, u, expires),
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   a.secureCookies || r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	writeJSON(w, http.StatusOK, Identity{Name: req.Username, Role: u.role, Method: MethodSession})
+}
+
+// Logout handles POST /api/auth/logout, clearing the session cookie
+func (a *Authenticator) Logout(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookie,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   a.secureCookies || r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Me handles GET /api/auth/me, returning the identity of the client. It must
+// run after Middleware.
+func Me(w http.ResponseWriter, r *http.Request) {
+	id, ok := FromContext(r.Context())
+	if !ok {
+		http.Error(w, "authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, id)
+}
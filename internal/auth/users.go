@@ -0,0 +1,300 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-chi/chi/v5"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// minPasswordLength is the shortest password accepted by the admin API
+const minPasswordLength = 8
+
+// namePattern restricts user names, which also name each user's data directory
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9@_-][A-Za-z0-9@._-]{0,63}$`)
+
+// ValidName reports whether name can be used as a user name
+func ValidName(name string) bool {
+	return namePattern.MatchString(name)
+}
+
+// User sources
+const (
+	SourceConfig  = "config"  // Listed in the auth config file, read-only
+	SourceManaged = "managed" // Created through the admin API
+)
+
+// UserInfo describes a user in the admin API
+type UserInfo struct {
+	Username string `json:"username"`
+	Role     string `json:"role"`
+	Source   string `json:"source"`
+}
+
+// UserUpdate is the body of PUT /api/admin/users/{name}
+type UserUpdate struct {
+	Password string `json:"password,omitempty"` // Required for new users
+	Role     string `json:"role,omitempty"`     // Kept when empty; new users default to user
+}
+
+// UserStore persists the users managed through the admin API
+type UserStore struct {
+	path  string
+	mu    sync.RWMutex
+	users map[string]UserConfig
+}
+
+// NewUserStore loads the users stored at path, which may not exist yet
+func NewUserStore(path string) (*UserStore, error) {
+	s := &UserStore{
+		path:  path,
+		users: make(map[string]UserConfig),
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, fmt.Errorf("failed to read users: %w", err)
+	}
+
+	var users []UserConfig
+	if err := json.Unmarshal(data, &users); err != nil {
+		return nil, fmt.Errorf("failed to parse users: %w", err)
+	}
+	for _, u := range users {
+		if !ValidName(u.Username) {
+			return nil, fmt.Errorf("invalid username: %q", u.Username)
+		}
+		if _, err := parseRole(u.Role); err != nil {
+			return nil, fmt.Errorf("user %s: %w", u.Username, err)
+		}
+		s.users[u.Username] = u
+	}
+
+	return s, nil
+}
+
+// Get returns a stored user
+func (s *UserStore) Get(name string) (UserConfig, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	u, ok := s.users[name]
+	return u, ok
+}
+
+// List returns the stored users sorted by name
+func (s *UserStore) List() []UserConfig {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.list()
+}
+
+// list returns the stored users sorted by name. s.mu must be held.
+func (s *UserStore) list() []UserConfig {
+	result := make([]UserConfig, 0, len(s.users))
+	for _, u := range s.users {
+		result = append(result, u)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Username < result[j].Username
+	})
+	return result
+}
+
+// Put creates or replaces a user. The lock is held through the write, so
+// concurrent changes reach the file in the order they were made.
+func (s *UserStore) Put(u UserConfig) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.users[u.Username] = u
+	return s.save()
+}
+
+// Delete removes a user
+func (s *UserStore) Delete(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.users, name)
+	return s.save()
+}
+
+// save writes the users file, readable only by the server since it holds
+// password hashes. s.mu must be held.
+func (s *UserStore) save() error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(s.list(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal users: %w", err)
+	}
+
+	if err := os.WriteFile(s.path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write users file: %w", err)
+	}
+
+	return nil
+}
+
+// SetUserStore sets the store of users managed through the admin API, who
+// sign in like the users of the auth config
+func (a *Authenticator) SetUserStore(store *UserStore) {
+	a.store = store
+}
+
+// lookupUser returns a user of the auth config or of the user store
+func (a *Authenticator) lookupUser(name string) (user, bool) {
+	if u, ok := a.users[name]; ok {
+		return u, true
+	}
+	if a.store == nil {
+		return user{}, false
+	}
+
+	u, ok := a.store.Get(name)
+	if !ok {
+		return user{}, false
+	}
+	role, err := parseRole(u.Role)
+	if err != nil {
+		return user{}, false
+	}
+	return user{hash: []byte(u.PasswordHash), role: role}, true
+}
+
+// ListUsers handles GET /api/admin/users
+func (a *Authenticator) ListUsers(w http.ResponseWriter, r *http.Request) {
+	users := make([]UserInfo, 0, len(a.users))
+	for name, u := range a.users {
+		users = append(users, UserInfo{Username: name, Role: u.role, Source: SourceConfig})
+	}
+	if a.store != nil {
+		for _, u := range a.store.List() {
+			if _, ok := a.users[u.Username]; ok {
+				continue
+			}
+			role, _ := parseRole(u.Role)
+			users = append(users, UserInfo{Username: u.Username, Role: role, Source: SourceManaged})
+		}
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].Username < users[j].Username
+	})
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"users": users,
+	})
+}
+
+// PutUser handles PUT /api/admin/users/{name}, creating a user or changing
+// the password or role of one
+func (a *Authenticator) PutUser(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	if !ValidName(name) {
+		http.Error(w, "invalid username", http.StatusBadRequest)
+		return
+	}
+	if _, ok := a.users[name]; ok {
+		http.Error(w, "users of the auth config cannot be changed through the API", http.StatusConflict)
+		return
+	}
+	if a.store == nil {
+		http.Error(w, "user management is not enabled", http.StatusNotFound)
+		return
+	}
+
+	var req UserUpdate
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	u, exists := a.store.Get(name)
+	u.Username = name
+	if req.Role != "" {
+		role, err := parseRole(req.Role)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		u.Role = role
+	} else if !exists {
+		u.Role = RoleUser
+	}
+
+	switch {
+	case req.Password != "":
+		if len([]rune(strings.TrimSpace(req.Password))) < minPasswordLength {
+			http.Error(w, fmt.Sprintf("password must be at least %d characters", minPasswordLength), http.StatusBadRequest)
+			return
+		}
+		hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to hash password: %v", err), http.StatusInternalServerError)
+			return
+		}
+		u.PasswordHash = string(hash)
+	case !exists:
+		http.Error(w, "password is required for new users", http.StatusBadRequest)
+		return
+	}
+
+	if err := a.store.Put(u); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to save user: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	status := http.StatusOK
+	if !exists {
+		status = http.StatusCreated
+	}
+	writeJSON(w, status, UserInfo{Username: name, Role: u.Role, Source: SourceManaged})
+}
+
+// DeleteUser handles DELETE /api/admin/users/{name}. The user's sessions end
+// at once; their data is kept.
+func (a *Authenticator) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	if _, ok := a.users[name]; ok {
+		http.Error(w, "users of the auth config cannot be changed through the API", http.StatusConflict)
+		return
+	}
+	if a.store == nil {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return
+	}
+	if _, ok := a.store.Get(name); !ok {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return
+	}
+
+	if err := a.store.Delete(name); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to delete user: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success":  true,
+		"username": name,
+	})
+}
+
+// writeJSON writes payload as a JSON response with the given status
+func writeJSON(w http.ResponseWriter, status int, payload interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(payload)
+}
@@ -0,0 +1,161 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidName(t *testing.T) {
+	for _, name := range []string{"alice", "bob.smith", "carol@example.com", "token-0"} {
+		assert.True(t, ValidName(name), name)
+	}
+	for _, name := range []string{"", ".", "..", ".hidden", "a/b", "a b", strings.Repeat("a", 65)} {
+		assert.False(t, ValidName(name), name)
+	}
+}
+
+// adminRouter serves the admin API of a, like the server
+func adminRouter(a *Authenticator) http.Handler {
+	r := chi.NewRouter()
+	r.Post("/api/auth/login", a.Login)
+	r.Get("/api/admin/users", a.ListUsers)
+	r.Put("/api/admin/users/{name}", a.PutUser)
+	r.Delete("/api/admin/users/{name}", a.DeleteUser)
+	return r
+}
+
+func TestAdminAPI_ManageUsers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.json")
+	store, err := NewUserStore(path)
+	assert.NoError(t, err)
+
+	a := newTestAuthenticator(t)
+	a.SetUserStore(store)
+	router := adminRouter(a)
+
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return w
+	}
+
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPut, "/api/admin/users/carol", `{}`).Code, "password required")
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPut, "/api/admin/users/carol", `{"password":"short"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPut, "/api/admin/users/carol", `{"password":"carol-password","role":"root"}`).Code)
+	assert.Equal(t, http.StatusConflict, do(http.MethodPut, "/api/admin/users/alice", `{"password":"new-password"}`).Code)
+
+	w := do(http.MethodPut, "/api/admin/users/carol", `{"password":"carol-password"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Contains(t, w.Body.String(), `"role":"user"`)
+
+	// carol can sign in, and the password is kept when only the role changes
+	assert.Equal(t, http.StatusOK, do(http.MethodPost, "/api/auth/login", `{"username":"carol","password":"carol-password"}`).Code)
+	assert.Equal(t, http.StatusOK, do(http.MethodPut, "/api/admin/users/carol", `{"role":"admin"}`).Code)
+	w = do(http.MethodPost, "/api/auth/login", `{"username":"carol","password":"carol-password"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"role":"admin"`)
+
+	w = do(http.MethodGet, "/api/admin/users", "")
+	var list struct {
+		Users []UserInfo `json:"users"`
+	}
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&list))
+	assert.Equal(t, []UserInfo{
+		{Username: "alice", Role: RoleAdmin, Source: SourceConfig},
+		{Username: "bob", Role: RoleUser, Source: SourceConfig},
+		{Username: "carol", Role: RoleAdmin, Source: SourceManaged},
+	}, list.Users)
+
+	// The users file only holds hashes and is private
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(data), "carol-password")
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	reloaded, err := NewUserStore(path)
+	assert.NoError(t, err)
+	_, ok := reloaded.Get("carol")
+	assert.True(t, ok)
+
+	// Deleting carol ends carol's sessions
+	carol, _ := a.lookupUser("carol")
+	session := a.signSession("carol", carol, time.Now().Add(time.Hour))
+	assert.Equal(t, http.StatusOK, do(http.MethodDelete, "/api/admin/users/carol", "").Code)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodDelete, "/api/admin/users/carol", "").Code)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(&http.Cookie{Name: SessionCookie, Value: session})
+	_, ok = a.Authenticate(req.WithContext(context.Background()))
+	assert.False(t, ok)
+}
+
+func TestAdminAPI_PasswordChangeEndsSessions(t *testing.T) {
+	store, err := NewUserStore(filepath.Join(t.TempDir(), "users.json"))
+	assert.NoError(t, err)
+
+	a := newTestAuthenticator(t)
+	a.SetUserStore(store)
+	router := adminRouter(a)
+
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return w
+	}
+	signedIn := func(cookie *http.Cookie) bool {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(cookie)
+		_, ok := a.Authenticate(req)
+		return ok
+	}
+
+	assert.Equal(t, http.StatusCreated, do(http.MethodPut, "/api/admin/users/carol", `{"password":"carol-password"}`).Code)
+	w := do(http.MethodPost, "/api/auth/login", `{"username":"carol","password":"carol-password"}`)
+	cookies := w.Result().Cookies()
+	if !assert.Len(t, cookies, 1) {
+		return
+	}
+	assert.True(t, signedIn(cookies[0]))
+
+	// Changing the role keeps the session, changing the password ends it
+	assert.Equal(t, http.StatusOK, do(http.MethodPut, "/api/admin/users/carol", `{"role":"admin"}`).Code)
+	assert.True(t, signedIn(cookies[0]))
+	assert.Equal(t, http.StatusOK, do(http.MethodPut, "/api/admin/users/carol", `{"password":"carol-password"}`).Code)
+	assert.False(t, signedIn(cookies[0]), "new hash, even for the same password")
+}
+
+func TestUserStore_ConcurrentPuts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.json")
+	store, err := NewUserStore(path)
+	assert.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, store.Put(UserConfig{Username: fmt.Sprintf("user-%d", i), Role: RoleUser}))
+		}()
+	}
+	wg.Wait()
+
+	// The last write holds every user
+	reloaded, err := NewUserStore(path)
+	assert.NoError(t, err)
+	assert.Equal(t, store.List(), reloaded.List())
+	assert.Len(t, reloaded.List(), 20)
+}
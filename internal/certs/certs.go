@@ -0,0 +1,181 @@
+// Package certs provides the TLS certificate of the server, generating a
+// self-signed one when none is provided
+package certs
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// validity is how long a self-signed certificate is valid. Browsers reject
+// server certificates valid for longer than 825 days.
+const validity = 825 * 24 * time.Hour
+
+// renewBefore is how long before it expires a self-signed certificate is
+// replaced
+const renewBefore = 30 * 24 * time.Hour
+
+// Load loads a certificate and its private key from PEM files
+func Load(certFile, keyFile string) (tls.Certificate, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return cert, fmt.Errorf("failed to load certificate: %w", err)
+	}
+	return cert, nil
+}
+
+// LoadOrCreateSelfSigned loads the certificate in certFile and keyFile, or
+// creates a self-signed certificate for hosts and saves it there when the
+// files don't exist or the certificate is about to expire. It reports
+// whether a certificate was created.
+func LoadOrCreateSelfSigned(certFile, keyFile string, hosts []string) (tls.Certificate, bool, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err == nil && time.Until(cert.Leaf.NotAfter) > renewBefore {
+		return cert, false, nil
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return cert, false, fmt.Errorf("failed to load certificate: %w", err)
+	}
+
+	certPEM, keyPEM, err := selfSigned(hosts, time.Now())
+	if err != nil {
+		return cert, false, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(certFile), 0700); err != nil {
+		return cert, false, fmt.Errorf("failed to create certificate directory: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(keyFile), 0700); err != nil {
+		return cert, false, fmt.Errorf("failed to create key directory: %w", err)
+	}
+	if err := os.WriteFile(keyFile, keyPEM, 0600); err != nil {
+		return cert, false, fmt.Errorf("failed to write key: %w", err)
+	}
+	if err := os.WriteFile(certFile, certPEM, 0644); err != nil {
+		return cert, false, fmt.Errorf("failed to write certificate: %w", err)
+	}
+
+	cert, err = tls.X509KeyPair(certPEM, keyPEM)
+	return cert, true, err
+}
+
+// selfSigned creates a PEM-encoded self-signed certificate for hosts, which
+// can be host names or IP addresses, and its private key
+func selfSigned(hosts []string, now time.Time) (certPEM, keyPEM []byte, err error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate serial number: %w", err)
+	}
+
+	template := x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"gollama-ui"}, CommonName: hosts[0]},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(validity),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, h)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create certificate: %w", err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode key: %w", err)
+	}
+
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM, nil
+}
+
+// DefaultHosts returns the names a server listening on listenHost is likely
+// reached by on the LAN: localhost, the machine's host name, and its IP
+// addresses
+func DefaultHosts(listenHost string) []string {
+	hosts := []string{"localhost"}
+	seen := map[string]bool{"localhost": true}
+	add := func(h string) {
+		if h != "" && !seen[h] {
+			seen[h] = true
+			hosts = append(hosts, h)
+		}
+	}
+
+	if name, err := os.Hostname(); err == nil {
+		add(name)
+		if !strings.Contains(name, ".") {
+			add(name + ".local")
+		}
+	}
+
+	if ip := net.ParseIP(listenHost); ip == nil || !ip.IsUnspecified() {
+		add(listenHost)
+	}
+
+	add("127.0.0.1")
+	add("::1")
+	if addrs, err := net.InterfaceAddrs(); err == nil {
+		for _, a := range addrs {
+			if ipNet, ok := a.(*net.IPNet); ok && !ipNet.IP.IsLinkLocalUnicast() {
+				add(ipNet.IP.String())
+			}
+		}
+	}
+
+	return hosts
+}
+
+// Fingerprint returns the SHA-256 fingerprint of a certificate, which users
+// can compare with the one their browser shows
+func Fingerprint(cert tls.Certificate) string {
+	sum := sha256.Sum256(cert.Certificate[0])
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+// RedirectHandler redirects plain HTTP requests to the same host and path
+// over HTTPS on httpsPort
+func RedirectHandler(httpsPort string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		} else if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+}
@@ -0,0 +1,91 @@
+package certs
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadOrCreateSelfSigned(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "tls", "cert.pem")
+	keyFile := filepath.Join(dir, "tls", "key.pem")
+	hosts := []string{"localhost", "pi.local", "192.168.1.20"}
+
+	cert, created, err := LoadOrCreateSelfSigned(certFile, keyFile, hosts)
+	assert.NoError(t, err)
+	assert.True(t, created)
+	if !assert.NotNil(t, cert.Leaf) {
+		return
+	}
+	for _, h := range hosts {
+		assert.NoError(t, cert.Leaf.VerifyHostname(h), h)
+	}
+	assert.Error(t, cert.Leaf.VerifyHostname("example.com"))
+
+	info, err := os.Stat(keyFile)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// The certificate is kept across restarts, so browsers don't warn again
+	again, created, err := LoadOrCreateSelfSigned(certFile, keyFile, hosts)
+	assert.NoError(t, err)
+	assert.False(t, created)
+	assert.Equal(t, Fingerprint(cert), Fingerprint(again))
+}
+
+func TestLoadOrCreateSelfSigned_Renews(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+
+	certPEM, keyPEM, err := selfSigned([]string{"localhost"}, time.Now().Add(-validity+time.Hour))
+	assert.NoError(t, err)
+	os.WriteFile(certFile, certPEM, 0644)
+	os.WriteFile(keyFile, keyPEM, 0600)
+
+	cert, created, err := LoadOrCreateSelfSigned(certFile, keyFile, []string{"localhost"})
+	assert.NoError(t, err)
+	assert.True(t, created)
+	assert.True(t, cert.Leaf.NotAfter.After(time.Now().Add(renewBefore)))
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	dir := t.TempDir()
+	_, err := Load(filepath.Join(dir, "missing.pem"), filepath.Join(dir, "missing.key"))
+	assert.Error(t, err)
+}
+
+func TestDefaultHosts(t *testing.T) {
+	hosts := DefaultHosts("0.0.0.0")
+	assert.Contains(t, hosts, "localhost")
+	assert.Contains(t, hosts, "127.0.0.1")
+	assert.NotContains(t, hosts, "0.0.0.0")
+
+	assert.Contains(t, DefaultHosts("pi.example.com"), "pi.example.com")
+}
+
+func TestRedirectHandler(t *testing.T) {
+	tests := map[string]struct {
+		port   string
+		target string
+		want   string
+	}{
+		"custom port":  {"3443", "http://pi.local:3000/api/models?x=1", "https://pi.local:3443/api/models?x=1"},
+		"default port": {"443", "http://pi.local/", "https://pi.local/"},
+		"ipv6":         {"443", "http://[fd00::1]:80/", "https://[fd00::1]/"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			RedirectHandler(tt.port).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+			assert.Equal(t, http.StatusMovedPermanently, rec.Code)
+			assert.Equal(t, tt.want, rec.Header().Get("Location"))
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package client
+
+import (
+	"context"
+	"fmt"
+)
+
+// Supported backend kinds
+const (
+	BackendLlamaCpp = "llamacpp"
+	BackendOllama   = "ollama"
+	BackendOpenAI   = "openai" // Generic OpenAI-compatible server (vLLM, LM Studio, hosted APIs)
+)
+
+// Backend is implemented by every supported inference server client
+type Backend interface {
+	ListModels(ctx context.Context) ([]Model, error)
+	ChatStream(ctx context.Context, req ChatRequest) (<-chan ChatResponse, error)
+	UnloadModel(ctx context.Context, modelName string) error
+	CountTokens(ctx context.Context, text string) (int, error)
+	ContextSize(ctx context.Context) (int, error)
+	CheckHealth(ctx context.Context) (string, error) // Returns one of the State* constants
+}
+
+// ModelContextSizer is implemented by backends that run several models at
+// once, each with its own context size. ModelContextSize also reports whether
+// the size is fixed until the model is reloaded, so callers can cache it.
+type ModelContextSizer interface {
+	ModelContextSize(ctx context.Context, model string, opts *Options) (int, bool, error)
+}
+
+// NewBackend creates a client for the given backend kind
+func NewBackend(kind string, cfg Config) (Backend, error) {
+	switch kind {
+	case BackendLlamaCpp, "":
+		return NewWithConfig(cfg)
+	case BackendOpenAI:
+		return NewOpenAIClient(cfg)
+	case BackendOllama:
+		return NewOllamaClient(cfg.BaseURL)
+	default:
+		return nil, fmt.Errorf("unknown backend: %s", kind)
+	}
+}
+
+// sendResponse delivers resp unless ctx is done first, so stream readers never
+// block on a consumer that has gone away. It reports whether resp was delivered.
+func sendResponse(ctx context.Context, ch chan<- ChatResponse, resp ChatResponse) bool {
+	select {
+	case ch <- resp:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
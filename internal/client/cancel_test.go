@@ -0,0 +1,98 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newEndlessServer streams chunks until the request is cancelled and reports
+// when the server saw the client go away
+func newEndlessServer(chunk string, gone chan<- struct{}) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		flusher := w.(http.Flusher)
+		for {
+			select {
+			case <-r.Context().Done():
+				close(gone)
+				return
+			default:
+			}
+			fmt.Fprint(w, chunk)
+			flusher.Flush()
+			time.Sleep(time.Millisecond)
+		}
+	}))
+}
+
+// assertNoLeak waits for the goroutine count to return to baseline
+func assertNoLeak(t *testing.T, baseline int) {
+	t.Helper()
+	// Polled by hand: assert.Eventually runs its condition in a goroutine
+	deadline := time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > baseline {
+		if time.Now().After(deadline) {
+			t.Fatalf("goroutines leaked: %d running, %d before", runtime.NumGoroutine(), baseline)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestClient_ChatStream_CancelStopsReader(t *testing.T) {
+	baseline := runtime.NumGoroutine()
+	gone := make(chan struct{})
+	server := newEndlessServer("data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"x\"},\"finish_reason\":null}]}\n\n", gone)
+
+	client, _ := NewWithConfig(Config{BaseURL: server.URL})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := client.ChatStream(ctx, ChatRequest{Model: "m"})
+	assert.NoError(t, err)
+
+	// Read one chunk, then stop reading like a handler whose client went away
+	<-stream
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+
+	select {
+	case <-gone:
+	case <-time.After(2 * time.Second):
+		t.Fatal("upstream request was not closed")
+	}
+
+	client.httpClient.CloseIdleConnections()
+	server.Close()
+	assertNoLeak(t, baseline)
+}
+
+func TestOllamaClient_ChatStream_CancelStopsReader(t *testing.T) {
+	baseline := runtime.NumGoroutine()
+	gone := make(chan struct{})
+	server := newEndlessServer(`{"model":"m","message":{"role":"assistant","content":"x"},"done":false}`+"\n", gone)
+
+	client, _ := NewOllamaClient(server.URL)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := client.ChatStream(ctx, ChatRequest{Model: "m"})
+	assert.NoError(t, err)
+
+	<-stream
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+
+	select {
+	case <-gone:
+	case <-time.After(2 * time.Second):
+		t.Fatal("upstream request was not closed")
+	}
+
+	client.httpClient.CloseIdleConnections()
+	server.Close()
+	assertNoLeak(t, baseline)
+}
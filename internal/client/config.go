@@ -0,0 +1,147 @@
+package client
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Config configures an OpenAI-compatible backend client
+type Config struct {
+	BaseURL      string            `json:"base_url"`
+	APIKey       string            `json:"api_key,omitempty"`      // Sent as a bearer token
+	Organization string            `json:"organization,omitempty"` // Sent as OpenAI-Organization
+	Headers      map[string]string `json:"headers,omitempty"`      // Extra headers sent with every request
+	TLS          TLSConfig         `json:"tls,omitempty"`
+	Paths        PathConfig        `json:"paths,omitempty"`
+	ContextSize  int               `json:"context_size,omitempty"` // Overrides the context size reported by the backend
+}
+
+// TLSConfig configures TLS for HTTPS backends
+type TLSConfig struct {
+	InsecureSkipVerify bool   `json:"insecure_skip_verify,omitempty"`
+	CAFile             string `json:"ca_file,omitempty"`   // PEM bundle of additional trusted CAs
+	CertFile           string `json:"cert_file,omitempty"` // Client certificate for mutual TLS
+	KeyFile            string `json:"key_file,omitempty"`
+}
+
+// PathConfig overrides the API paths used by the client.
+// An empty Tokenize or Props path disables that endpoint, and an empty
+// Health path makes health checks list the models instead.
+type PathConfig struct {
+	Models          string `json:"models,omitempty"`
+	ChatCompletions string `json:"chat_completions,omitempty"`
+	Tokenize        string `json:"tokenize,omitempty"`
+	Props           string `json:"props,omitempty"`
+	Health          string `json:"health,omitempty"`
+}
+
+// llamaCppPaths are the endpoints exposed by llama.cpp's server
+var llamaCppPaths = PathConfig{
+	Models:          "/v1/models",
+	ChatCompletions: "/v1/chat/completions",
+	Tokenize:        "/tokenize",
+	Props:           "/props",
+	Health:          "/health",
+}
+
+// openAIPaths are the endpoints of a generic OpenAI-compatible server
+var openAIPaths = PathConfig{
+	Models:          "/v1/models",
+	ChatCompletions: "/v1/chat/completions",
+}
+
+// LoadConfig reads a backend config from a JSON file
+func LoadConfig(path string) (Config, error) {
+	var cfg Config
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, fmt.Errorf("failed to read backend config: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("failed to parse backend config: %w", err)
+	}
+
+	return cfg, nil
+}
+
+// withDefaults fills unset paths from defaults
+func (p PathConfig) withDefaults(defaults PathConfig) PathConfig {
+	if p.Models == "" {
+		p.Models = defaults.Models
+	}
+	if p.ChatCompletions == "" {
+		p.ChatCompletions = defaults.ChatCompletions
+	}
+	if p.Tokenize == "" {
+		p.Tokenize = defaults.Tokenize
+	}
+	if p.Props == "" {
+		p.Props = defaults.Props
+	}
+	if p.Health == "" {
+		p.Health = defaults.Health
+	}
+	return p
+}
+
+// normalizeBaseURL ensures the URL has a protocol and no trailing slash
+func normalizeBaseURL(host, fallback string) string {
+	if host == "" {
+		host = fallback
+	}
+	if !strings.HasPrefix(host, "http://") && !strings.HasPrefix(host, "https://") {
+		host = "http://" + host
+	}
+	return strings.TrimSuffix(host, "/")
+}
+
+// newHTTPClient creates an HTTP client using the TLS settings
+func (t TLSConfig) newHTTPClient() (*http.Client, error) {
+	if !t.InsecureSkipVerify && t.CAFile == "" && t.CertFile == "" {
+		return &http.Client{
+			Timeout: 0, // No timeout for streaming responses
+		}, nil
+	}
+
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: t.InsecureSkipVerify,
+	}
+
+	if t.CAFile != "" {
+		pem, err := os.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", t.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if t.CertFile != "" || t.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   0, // No timeout for streaming responses
+	}, nil
+}
@@ -0,0 +1,233 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Backend states tracked by the health monitor
+const (
+	StateUnknown = "unknown" // Not checked yet
+	StateLoading = "loading" // Reachable but still loading a model
+	StateReady   = "ready"
+	StateDown    = "down"
+)
+
+// healthCheckTimeout bounds a single health check
+const healthCheckTimeout = 5 * time.Second
+
+// BackendStatus is the monitored state of a backend
+type BackendStatus struct {
+	Name     string    `json:"name"`
+	State    string    `json:"state"`
+	Previous string    `json:"previous,omitempty"`
+	Error    string    `json:"error,omitempty"`
+	Since    time.Time `json:"since"`
+}
+
+// Monitor polls every backend's health until ctx is done. Requests wait for
+// or fail over from backends that are not ready only while it runs.
+func (r *Router) Monitor(ctx context.Context, interval time.Duration) {
+	r.statusMu.Lock()
+	r.monitoring = true
+	r.statusMu.Unlock()
+
+	defer func() {
+		r.statusMu.Lock()
+		r.monitoring = false
+		r.statusMu.Unlock()
+	}()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		r.checkAll(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Statuses returns the monitored state of every backend
+func (r *Router) Statuses() []BackendStatus {
+	r.statusMu.Lock()
+	defer r.statusMu.Unlock()
+
+	result := make([]BackendStatus, 0, len(r.backends))
+	for _, nb := range r.backends {
+		result = append(result, r.status[nb.config.Name])
+	}
+	return result
+}
+
+// ModelState returns the monitored state of the backend that owns the model
+func (r *Router) ModelState(model string) string {
+	nb, _ := r.resolve(model)
+	return r.backendStatus(nb.config.Name).State
+}
+
+// Subscribe returns a channel receiving every state transition. Slow
+// subscribers miss transitions rather than blocking the monitor.
+func (r *Router) Subscribe() (<-chan BackendStatus, func()) {
+	ch := make(chan BackendStatus, 16)
+
+	r.statusMu.Lock()
+	r.subscribers[ch] = struct{}{}
+	r.statusMu.Unlock()
+
+	unsubscribe := func() {
+		r.statusMu.Lock()
+		delete(r.subscribers, ch)
+		r.statusMu.Unlock()
+	}
+
+	return ch, unsubscribe
+}
+
+// checkAll checks every backend concurrently
+func (r *Router) checkAll(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, nb := range r.backends {
+		wg.Add(1)
+		go func(nb namedBackend) {
+			defer wg.Done()
+			r.check(ctx, nb)
+		}(nb)
+	}
+	wg.Wait()
+}
+
+// check checks one backend and records its state
+func (r *Router) check(ctx context.Context, nb namedBackend) string {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	state, err := nb.backend.CheckHealth(ctx)
+	errMsg := ""
+	if err != nil {
+		errMsg = err.Error()
+	}
+
+	r.setStatus(nb.config.Name, state, errMsg)
+	return state
+}
+
+// setStatus records a backend's state and notifies subscribers on transitions
+func (r *Router) setStatus(name, state, errMsg string) {
+	r.statusMu.Lock()
+	defer r.statusMu.Unlock()
+
+	current := r.status[name]
+	if current.State == state {
+		current.Error = errMsg
+		r.status[name] = current
+		return
+	}
+
+	status := BackendStatus{
+		Name:     name,
+		State:    state,
+		Previous: current.State,
+		Error:    errMsg,
+		Since:    time.Now(),
+	}
+	r.status[name] = status
+
+	close(r.changed)
+	r.changed = make(chan struct{})
+
+	for ch := range r.subscribers {
+		select {
+		case ch <- status:
+		default:
+		}
+	}
+}
+
+// backendStatus returns the monitored state of a backend
+func (r *Router) backendStatus(name string) BackendStatus {
+	r.statusMu.Lock()
+	defer r.statusMu.Unlock()
+	return r.status[name]
+}
+
+// selectReady returns nb if it is ready, otherwise its first ready fallback.
+// When neither is ready it waits up to the backend's wait time for one to
+// become ready. Backends that have not been checked yet count as ready.
+func (r *Router) selectReady(ctx context.Context, nb namedBackend, model string) (namedBackend, string, error) {
+	r.statusMu.Lock()
+	monitoring := r.monitoring
+	r.statusMu.Unlock()
+	if !monitoring {
+		return nb, model, nil
+	}
+
+	// The monitored state may be stale, so recheck before falling back
+	if state := r.backendStatus(nb.config.Name).State; state != StateReady && state != StateUnknown {
+		r.check(ctx, nb)
+	}
+
+	deadline := time.Now().Add(time.Duration(nb.config.WaitSeconds) * time.Second)
+	for {
+		r.statusMu.Lock()
+		changed := r.changed
+		selected, selectedModel, ok := r.readyLocked(nb, model)
+		state := r.status[nb.config.Name].State
+		r.statusMu.Unlock()
+
+		if ok {
+			return selected, selectedModel, nil
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return nb, model, fmt.Errorf("backend %s is %s", nb.config.Name, state)
+		}
+
+		timer := time.NewTimer(remaining)
+		select {
+		case <-changed:
+			timer.Stop()
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nb, model, ctx.Err()
+		}
+	}
+}
+
+// readyLocked picks nb or the first of its fallbacks that is ready.
+// statusMu must be held.
+func (r *Router) readyLocked(nb namedBackend, model string) (namedBackend, string, bool) {
+	if isUsable(r.status[nb.config.Name].State) {
+		return nb, model, true
+	}
+
+	for _, name := range nb.config.Fallback {
+		if !isUsable(r.status[name].State) {
+			continue
+		}
+		for _, fb := range r.backends {
+			if fb.config.Name != name {
+				continue
+			}
+			if fb.config.FallbackModel != "" {
+				return fb, fb.config.FallbackModel, true
+			}
+			return fb, model, true
+		}
+	}
+
+	return nb, model, false
+}
+
+// isUsable reports whether requests may be sent to a backend in state
+func isUsable(state string) bool {
+	return state == StateReady || state == StateUnknown
+}
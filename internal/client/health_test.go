@@ -0,0 +1,141 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newHealthServer serves a llama.cpp-style health endpoint that reports
+// loading until ready is set, and a chat endpoint that answers "ok"
+func newHealthServer(ready *atomic.Bool) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/health":
+			if !ready.Load() {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				fmt.Fprint(w, `{"error":{"code":503,"message":"Loading model"}}`)
+				return
+			}
+			fmt.Fprint(w, `{"status":"ok"}`)
+		case "/v1/chat/completions":
+			fmt.Fprint(w, "data: {\"model\":\"m\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"ok\"},\"finish_reason\":\"stop\"}]}\n\n")
+			fmt.Fprint(w, "data: [DONE]\n\n")
+		}
+	}))
+}
+
+func TestClient_CheckHealth(t *testing.T) {
+	var ready atomic.Bool
+	server := newHealthServer(&ready)
+	defer server.Close()
+
+	client, _ := NewWithConfig(Config{BaseURL: server.URL})
+
+	state, err := client.CheckHealth(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, StateLoading, state)
+
+	ready.Store(true)
+	state, err = client.CheckHealth(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, StateReady, state)
+
+	server.Close()
+	state, err = client.CheckHealth(context.Background())
+	assert.Error(t, err)
+	assert.Equal(t, StateDown, state)
+}
+
+func TestRouter_FailsOverToReadyBackend(t *testing.T) {
+	var primaryReady, secondaryReady atomic.Bool
+	secondaryReady.Store(true)
+	primary := newHealthServer(&primaryReady)
+	defer primary.Close()
+	secondary := newHealthServer(&secondaryReady)
+	defer secondary.Close()
+
+	router, err := NewRouter([]BackendConfig{
+		{Name: "pi", Config: Config{BaseURL: primary.URL}, Fallback: []string{"desktop"}},
+		{Name: "desktop", Config: Config{BaseURL: secondary.URL}, FallbackModel: "big"},
+	})
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go router.Monitor(ctx, time.Hour)
+	waitForState(t, router, "pi", StateLoading)
+
+	nb, model, err := router.selectReady(context.Background(), router.backends[0], "small")
+	assert.NoError(t, err)
+	assert.Equal(t, "desktop", nb.config.Name)
+	assert.Equal(t, "big", model)
+}
+
+func TestRouter_WaitsForBackend(t *testing.T) {
+	var ready atomic.Bool
+	server := newHealthServer(&ready)
+	defer server.Close()
+
+	router, _ := NewRouter([]BackendConfig{
+		{Name: "pi", Config: Config{BaseURL: server.URL}, WaitSeconds: 5},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go router.Monitor(ctx, 20*time.Millisecond)
+	waitForState(t, router, "pi", StateLoading)
+
+	updates, unsubscribe := router.Subscribe()
+	defer unsubscribe()
+
+	time.AfterFunc(50*time.Millisecond, func() { ready.Store(true) })
+
+	stream, err := router.ChatStream(context.Background(), ChatRequest{Model: "m"})
+	assert.NoError(t, err)
+	for range stream {
+	}
+
+	status := <-updates
+	assert.Equal(t, "pi", status.Name)
+	assert.Equal(t, StateReady, status.State)
+	assert.Equal(t, StateLoading, status.Previous)
+}
+
+func TestRouter_WaitTimesOut(t *testing.T) {
+	var ready atomic.Bool
+	server := newHealthServer(&ready)
+	defer server.Close()
+
+	router, _ := NewRouter([]BackendConfig{{Name: "pi", Config: Config{BaseURL: server.URL}}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go router.Monitor(ctx, time.Hour)
+	waitForState(t, router, "pi", StateLoading)
+
+	_, err := router.ChatStream(context.Background(), ChatRequest{Model: "m"})
+	assert.EqualError(t, err, "backend pi is loading")
+}
+
+func TestNewRouter_InvalidFallback(t *testing.T) {
+	_, err := NewRouter([]BackendConfig{{Name: "pi", Fallback: []string{"missing"}}})
+	assert.Error(t, err)
+
+	_, err = NewRouter([]BackendConfig{{Name: "pi", Fallback: []string{"pi"}}})
+	assert.Error(t, err)
+}
+
+// waitForState waits until the monitor has recorded state for a backend
+func waitForState(t *testing.T, router *Router, name, state string) {
+	t.Helper()
+	assert.Eventually(t, func() bool {
+		return router.backendStatus(name).State == state
+	}, time.Second, 5*time.Millisecond)
+}
@@ -7,15 +7,22 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"strings"
+	"time"
+
+	"github.com/aristath/gollama-ui/internal/logging"
 )
 
-// Client wraps the llama.cpp OpenAI API client
+// Client wraps the llama.cpp OpenAI API client. It also works with any other
+// OpenAI-compatible server such as vLLM, LM Studio or hosted endpoints.
 type Client struct {
 	baseURL    string
 	httpClient *http.Client
 	host       string
+	config     Config
+	paths      PathConfig
 }
 
 // Model represents a model (compatible with llama.cpp response)
@@ -24,6 +31,7 @@ type Model struct {
 	Size       int64  `json:"size,omitempty"`
 	Digest     string `json:"digest,omitempty"`
 	ModifiedAt string `json:"modified_at,omitempty"`
+	Backend    string `json:"backend,omitempty"` // Name of the backend serving the model, when several are configured
 }
 
 // Tool calling support structures
@@ -63,6 +71,11 @@ type ChatRequest struct {
 	Messages []ChatMessage `json:"messages"`
 	Stream   bool          `json:"stream,omitempty"`
 	Tools    []Tool        `json:"tools,omitempty"`
+	Options  *Options      `json:"options,omitempty"`
+
+	// Structured output constraints; at most one of them may be set
+	ResponseFormat *ResponseFormat `json:"response_format,omitempty"`
+	Grammar        string          `json:"grammar,omitempty"`
 }
 
 // ChatResponse represents a streaming chat response chunk
@@ -72,6 +85,28 @@ type ChatResponse struct {
 	Done      bool         `json:"done"`
 	DoneReason string      `json:"done_reason,omitempty"`
 	Error     string       `json:"error,omitempty"`
+
+	// Set on the done response when the backend reports them
+	Usage   *Usage   `json:"usage,omitempty"`
+	Timings *Timings `json:"timings,omitempty"`
+}
+
+// Usage reports the number of tokens a response used
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// Timings reports how fast the prompt was processed and the response generated,
+// as llama.cpp returns them
+type Timings struct {
+	PromptN            int     `json:"prompt_n"`
+	PromptMs           float64 `json:"prompt_ms"`
+	PromptPerSecond    float64 `json:"prompt_per_second"`
+	PredictedN         int     `json:"predicted_n"`
+	PredictedMs        float64 `json:"predicted_ms"`
+	PredictedPerSecond float64 `json:"predicted_per_second"`
 }
 
 // llama.cpp API structures
@@ -99,35 +134,84 @@ type OpenAIChatChunk struct {
 	Created int64          `json:"created"`
 	Model   string         `json:"model"`
 	Choices []OpenAIChoice `json:"choices"`
+	Usage   *Usage         `json:"usage,omitempty"`
+	Timings *Timings       `json:"timings,omitempty"` // llama.cpp only
+}
+
+// TokenizeResponse represents llama.cpp's /tokenize response
+type TokenizeResponse struct {
+	Tokens []int `json:"tokens"`
+}
+
+// PropsResponse represents the parts of llama.cpp's /props response we use
+type PropsResponse struct {
+	DefaultGenerationSettings struct {
+		NCtx int `json:"n_ctx"`
+	} `json:"default_generation_settings"`
 }
 
 // New creates a new llama.cpp client
 func New(host string) (*Client, error) {
-	if host == "" {
-		host = "http://localhost:8080"
-	}
+	return NewWithConfig(Config{BaseURL: host})
+}
+
+// NewWithConfig creates a new llama.cpp client from a backend config
+func NewWithConfig(cfg Config) (*Client, error) {
+	return newClient(cfg, llamaCppPaths)
+}
 
-	// Ensure host has protocol
-	if !strings.HasPrefix(host, "http://") && !strings.HasPrefix(host, "https://") {
-		host = "http://" + host
+// NewOpenAIClient creates a client for a generic OpenAI-compatible server,
+// which has no tokenize or props endpoints unless configured
+func NewOpenAIClient(cfg Config) (*Client, error) {
+	return newClient(cfg, openAIPaths)
+}
+
+// newClient creates a client using defaultPaths for unset path overrides
+func newClient(cfg Config, defaultPaths PathConfig) (*Client, error) {
+	host := normalizeBaseURL(cfg.BaseURL, "http://localhost:8080")
+
+	httpClient, err := cfg.TLS.newHTTPClient()
+	if err != nil {
+		return nil, err
 	}
 
 	return &Client{
-		baseURL: host,
-		httpClient: &http.Client{
-			Timeout: 0, // No timeout for streaming responses
-		},
-		host: host,
+		baseURL:    host,
+		httpClient: httpClient,
+		host:       host,
+		config:     cfg,
+		paths:      cfg.Paths.withDefaults(defaultPaths),
 	}, nil
 }
 
+// newRequest creates a request to path with the configured auth and extra headers
+func (c *Client) newRequest(ctx context.Context, method, path string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.config.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.config.APIKey)
+	}
+	if c.config.Organization != "" {
+		req.Header.Set("OpenAI-Organization", c.config.Organization)
+	}
+	for name, value := range c.config.Headers {
+		req.Header.Set(name, value)
+	}
+
+	return req, nil
+}
+
 // ListModels returns all available models
 func (c *Client) ListModels(ctx context.Context) ([]Model, error) {
-	url := fmt.Sprintf("%s/v1/models", c.baseURL)
-
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	req, err := c.newRequest(ctx, "GET", c.paths.Models, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, err
 	}
 
 	resp, err := c.httpClient.Do(req)
@@ -160,7 +244,7 @@ func (c *Client) ListModels(ctx context.Context) ([]Model, error) {
 
 // ChatStream handles streaming chat requests
 func (c *Client) ChatStream(ctx context.Context, req ChatRequest) (<-chan ChatResponse, error) {
-	url := fmt.Sprintf("%s/v1/chat/completions", c.baseURL)
+	url := c.baseURL + c.paths.ChatCompletions
 
 	// Convert to OpenAI format
 	openAIReq := map[string]interface{}{
@@ -169,6 +253,19 @@ func (c *Client) ChatStream(ctx context.Context, req ChatRequest) (<-chan ChatRe
 		"stream":   true,
 	}
 
+	// Ask for token usage at the end of the stream
+	openAIReq["stream_options"] = map[string]bool{"include_usage": true}
+
+	if req.Options != nil {
+		req.Options.apply(openAIReq)
+	}
+	if req.ResponseFormat != nil {
+		openAIReq["response_format"] = req.ResponseFormat
+	}
+	if req.Grammar != "" {
+		openAIReq["grammar"] = req.Grammar
+	}
+
 	// Note: llama.cpp server does NOT support the tools parameter with any model.
 	// Sending tools causes the server to close the connection (exit 52).
 	// Tools are not sent to the backend. Instead, they are handled at the
@@ -180,33 +277,33 @@ func (c *Client) ChatStream(ctx context.Context, req ChatRequest) (<-chan ChatRe
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	httpReq, err := c.newRequest(ctx, "POST", c.paths.ChatCompletions, bytes.NewReader(body))
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, err
 	}
 
-	httpReq.Header.Set("Content-Type", "application/json")
-
-	fmt.Printf("DEBUG: Sending request to %s with model %s\n", url, req.Model)
-	fmt.Printf("DEBUG: Request body: %s\n", string(body))
+	slog.DebugContext(ctx, "Sending chat request",
+		"url", url,
+		"model", req.Model,
+		"messages", len(req.Messages),
+		"body", logging.Content(string(body)),
+	)
 
+	start := time.Now()
 	resp, err := c.httpClient.Do(httpReq)
 	if err != nil {
-		fmt.Printf("DEBUG: HTTP request error: %v\n", err)
+		slog.WarnContext(ctx, "Chat request failed", "url", url, "model", req.Model, "error", err)
 		return nil, fmt.Errorf("failed to start chat: %w", err)
 	}
 
-	fmt.Printf("DEBUG: Got response status %d\n", resp.StatusCode)
-	fmt.Printf("DEBUG: Response headers: %+v\n", resp.Header)
-
 	if resp.StatusCode != http.StatusOK {
 		defer resp.Body.Close()
 		body, _ := io.ReadAll(resp.Body)
-		fmt.Printf("DEBUG: llama.cpp status %d, body: %s\n", resp.StatusCode, string(body))
+		slog.WarnContext(ctx, "Chat request rejected", "url", url, "model", req.Model, "status", resp.StatusCode)
 		return nil, fmt.Errorf("failed to start chat: status %d: %s", resp.StatusCode, string(body))
 	}
 
-	fmt.Printf("DEBUG: Chat stream started successfully, model: %s\n", req.Model)
+	slog.DebugContext(ctx, "Chat stream started", "model", req.Model, "latency", time.Since(start))
 
 	// Create output channel
 	responseChan := make(chan ChatResponse, 10)
@@ -216,6 +313,16 @@ func (c *Client) ChatStream(ctx context.Context, req ChatRequest) (<-chan ChatRe
 		defer close(responseChan)
 		defer resp.Body.Close()
 
+		// Close the body as soon as the request is cancelled so the backend
+		// stops generating, even while the scanner is blocked on a read
+		stop := context.AfterFunc(ctx, func() { resp.Body.Close() })
+		defer stop()
+
+		// The finishing chunk is held back until the stream ends, since the
+		// usage may follow in a chunk of its own
+		var final *ChatResponse
+		sawDone := false
+
 		scanner := bufio.NewScanner(resp.Body)
 		for scanner.Scan() {
 			line := scanner.Text()
@@ -227,11 +334,8 @@ func (c *Client) ChatStream(ctx context.Context, req ChatRequest) (<-chan ChatRe
 
 			// Check for [DONE] marker
 			if line == "data: [DONE]" {
-				responseChan <- ChatResponse{
-					Model: req.Model,
-					Done:  true,
-				}
-				return
+				sawDone = true
+				break
 			}
 
 			// Parse Server-Sent Events format
@@ -242,48 +346,182 @@ func (c *Client) ChatStream(ctx context.Context, req ChatRequest) (<-chan ChatRe
 			jsonStr := strings.TrimPrefix(line, "data: ")
 			var chunk OpenAIChatChunk
 			if err := json.Unmarshal([]byte(jsonStr), &chunk); err != nil {
-				responseChan <- ChatResponse{
+				sendResponse(ctx, responseChan, ChatResponse{
 					Model: req.Model,
 					Done:  true,
 					Error: fmt.Sprintf("failed to parse chunk: %v", err),
-				}
+				})
 				return
 			}
 
-			// Extract content from choices
-			if len(chunk.Choices) > 0 {
-				choice := chunk.Choices[0]
-
-				responseChan <- ChatResponse{
-					Model: chunk.Model,
-					Message: ChatMessage{
-						Role:      choice.Delta.Role,
-						Content:   choice.Delta.Content,
-						ToolCalls: choice.Delta.ToolCalls,
-					},
-					Done:       choice.FinishReason != nil,
-					DoneReason: func() string { if choice.FinishReason != nil { return *choice.FinishReason } ; return "" }(),
+			// Chunks after the finishing one only carry usage
+			if final != nil {
+				if chunk.Usage != nil {
+					final.Usage = chunk.Usage
+				}
+				if chunk.Timings != nil {
+					final.Timings = chunk.Timings
 				}
+				continue
+			}
 
-				// If finished, return
-				if choice.FinishReason != nil {
+			// Extract content from choices
+			if len(chunk.Choices) == 0 {
+				continue
+			}
+			choice := chunk.Choices[0]
+
+			response := ChatResponse{
+				Model: chunk.Model,
+				Message: ChatMessage{
+					Role:      choice.Delta.Role,
+					Content:   choice.Delta.Content,
+					ToolCalls: choice.Delta.ToolCalls,
+				},
+			}
+
+			if choice.FinishReason == nil {
+				if !sendResponse(ctx, responseChan, response) {
 					return
 				}
+				continue
 			}
+
+			response.Done = true
+			response.DoneReason = *choice.FinishReason
+			response.Usage = chunk.Usage
+			response.Timings = chunk.Timings
+			final = &response
 		}
 
-		if err := scanner.Err(); err != nil {
-			responseChan <- ChatResponse{
+		if err := scanner.Err(); err != nil && final == nil {
+			sendResponse(ctx, responseChan, ChatResponse{
 				Model: req.Model,
 				Done:  true,
 				Error: fmt.Sprintf("scanner error: %v", err),
-			}
+			})
+			return
+		}
+
+		if final != nil {
+			slog.DebugContext(ctx, "Chat stream finished", "model", req.Model, "reason", final.DoneReason)
+			sendResponse(ctx, responseChan, *final)
+		} else if sawDone {
+			sendResponse(ctx, responseChan, ChatResponse{
+				Model: req.Model,
+				Done:  true,
+			})
 		}
 	}()
 
 	return responseChan, nil
 }
 
+// CountTokens returns the number of tokens the loaded model uses for text.
+// Servers without a tokenize endpoint get an estimate of four bytes per token.
+func (c *Client) CountTokens(ctx context.Context, text string) (int, error) {
+	if c.paths.Tokenize == "" {
+		return (len(text) + 3) / 4, nil
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"content": text,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := c.newRequest(ctx, "POST", c.paths.Tokenize, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to tokenize: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return 0, fmt.Errorf("failed to tokenize: status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var tokenizeResp TokenizeResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tokenizeResp); err != nil {
+		return 0, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return len(tokenizeResp.Tokens), nil
+}
+
+// ContextSize returns the context window (n_ctx) of the loaded model
+func (c *Client) ContextSize(ctx context.Context) (int, error) {
+	if c.config.ContextSize > 0 {
+		return c.config.ContextSize, nil
+	}
+	if c.paths.Props == "" {
+		return 0, fmt.Errorf("context size is unknown: set context_size in the backend config")
+	}
+
+	req, err := c.newRequest(ctx, "GET", c.paths.Props, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get props: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return 0, fmt.Errorf("failed to get props: status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var props PropsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&props); err != nil {
+		return 0, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	if props.DefaultGenerationSettings.NCtx <= 0 {
+		return 0, fmt.Errorf("backend did not report a context size")
+	}
+
+	return props.DefaultGenerationSettings.NCtx, nil
+}
+
+// CheckHealth reports whether the server is ready. llama.cpp answers its
+// health endpoint with 503 while a model is still loading.
+func (c *Client) CheckHealth(ctx context.Context) (string, error) {
+	path := c.paths.Health
+	if path == "" {
+		path = c.paths.Models
+	}
+
+	req, err := c.newRequest(ctx, "GET", path, nil)
+	if err != nil {
+		return StateDown, err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return StateDown, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		return StateReady, nil
+	case resp.StatusCode == http.StatusServiceUnavailable && c.paths.Health != "":
+		return StateLoading, nil
+	default:
+		body, _ := io.ReadAll(resp.Body)
+		return StateDown, fmt.Errorf("status %d: %s", resp.StatusCode, string(body))
+	}
+}
+
 // UnloadModel is not supported by llama.cpp
 // Returns an error indicating the operation is not supported
 func (c *Client) UnloadModel(ctx context.Context, modelName string) error {
@@ -0,0 +1,493 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aristath/gollama-ui/internal/logging"
+)
+
+// maxNDJSONLineSize bounds a single line of Ollama's streaming response
+const maxNDJSONLineSize = 1024 * 1024
+
+// OllamaClient talks to a native Ollama server
+type OllamaClient struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// Ollama API structures
+type ollamaModel struct {
+	Name       string    `json:"name"`
+	Model      string    `json:"model"`
+	ModifiedAt time.Time `json:"modified_at"`
+	Size       int64     `json:"size"`
+	Digest     string    `json:"digest"`
+}
+
+type ollamaTagsResponse struct {
+	Models []ollamaModel `json:"models"`
+}
+
+type ollamaRunningModel struct {
+	Name          string `json:"name"`
+	Model         string `json:"model"`
+	ContextLength int    `json:"context_length"`
+}
+
+type ollamaShowResponse struct {
+	Parameters string `json:"parameters"` // Modelfile parameters, one "name value" per line
+}
+
+type ollamaPsResponse struct {
+	Models []ollamaRunningModel `json:"models"`
+}
+
+type ollamaToolCall struct {
+	Function struct {
+		Name      string                 `json:"name"`
+		Arguments map[string]interface{} `json:"arguments"`
+	} `json:"function"`
+}
+
+type ollamaMessage struct {
+	Role      string           `json:"role"`
+	Content   string           `json:"content"`
+	ToolCalls []ollamaToolCall `json:"tool_calls,omitempty"`
+}
+
+type ollamaChatChunk struct {
+	Model      string        `json:"model"`
+	Message    ollamaMessage `json:"message"`
+	Done       bool          `json:"done"`
+	DoneReason string        `json:"done_reason"`
+	Error      string        `json:"error"`
+
+	// Set on the done chunk; durations are in nanoseconds
+	PromptEvalCount    int   `json:"prompt_eval_count"`
+	PromptEvalDuration int64 `json:"prompt_eval_duration"`
+	EvalCount          int   `json:"eval_count"`
+	EvalDuration       int64 `json:"eval_duration"`
+}
+
+// stats converts the counters of a done chunk to usage and timings
+func (c ollamaChatChunk) stats() (*Usage, *Timings) {
+	if c.EvalCount == 0 && c.PromptEvalCount == 0 {
+		return nil, nil
+	}
+
+	usage := &Usage{
+		PromptTokens:     c.PromptEvalCount,
+		CompletionTokens: c.EvalCount,
+		TotalTokens:      c.PromptEvalCount + c.EvalCount,
+	}
+	timings := &Timings{
+		PromptN:     c.PromptEvalCount,
+		PromptMs:    float64(c.PromptEvalDuration) / 1e6,
+		PredictedN:  c.EvalCount,
+		PredictedMs: float64(c.EvalDuration) / 1e6,
+	}
+	if c.PromptEvalDuration > 0 {
+		timings.PromptPerSecond = float64(c.PromptEvalCount) / (float64(c.PromptEvalDuration) / 1e9)
+	}
+	if c.EvalDuration > 0 {
+		timings.PredictedPerSecond = float64(c.EvalCount) / (float64(c.EvalDuration) / 1e9)
+	}
+
+	return usage, timings
+}
+
+// NewOllamaClient creates a new native Ollama client
+func NewOllamaClient(host string) (*OllamaClient, error) {
+	if host == "" {
+		host = "http://localhost:11434"
+	}
+
+	// Ensure host has protocol
+	if !strings.HasPrefix(host, "http://") && !strings.HasPrefix(host, "https://") {
+		host = "http://" + host
+	}
+
+	return &OllamaClient{
+		baseURL: strings.TrimSuffix(host, "/"),
+		httpClient: &http.Client{
+			Timeout: 0, // No timeout for streaming responses
+		},
+	}, nil
+}
+
+// ListModels returns all locally available models
+func (c *OllamaClient) ListModels(ctx context.Context) ([]Model, error) {
+	var tags ollamaTagsResponse
+	if err := c.getJSON(ctx, "/api/tags", &tags); err != nil {
+		return nil, fmt.Errorf("failed to list models: %w", err)
+	}
+
+	result := make([]Model, 0, len(tags.Models))
+	for _, m := range tags.Models {
+		result = append(result, Model{
+			Name:       m.Name,
+			Size:       m.Size,
+			Digest:     m.Digest,
+			ModifiedAt: m.ModifiedAt.Format(time.RFC3339),
+		})
+	}
+
+	return result, nil
+}
+
+// ChatStream handles streaming chat requests using Ollama's NDJSON protocol
+func (c *OllamaClient) ChatStream(ctx context.Context, req ChatRequest) (<-chan ChatResponse, error) {
+	if req.Grammar != "" {
+		return nil, fmt.Errorf("GBNF grammars are not supported by the Ollama backend")
+	}
+
+	messages, err := toOllamaMessages(req.Messages)
+	if err != nil {
+		return nil, err
+	}
+
+	ollamaReq := map[string]interface{}{
+		"model":    req.Model,
+		"messages": messages,
+		"stream":   true,
+	}
+	if len(req.Tools) > 0 {
+		ollamaReq["tools"] = req.Tools
+	}
+	if req.Options != nil {
+		ollamaReq["options"] = req.Options.ollamaOptions()
+	}
+	if req.ResponseFormat != nil {
+		if schema := req.ResponseFormat.Schema(); schema != nil {
+			ollamaReq["format"] = schema
+		} else {
+			ollamaReq["format"] = "json"
+		}
+	}
+
+	body, err := json.Marshal(ollamaReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/api/chat", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	slog.DebugContext(ctx, "Sending chat request",
+		"url", c.baseURL+"/api/chat",
+		"model", req.Model,
+		"messages", len(req.Messages),
+		"body", logging.Content(string(body)),
+	)
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		slog.WarnContext(ctx, "Chat request failed", "url", c.baseURL+"/api/chat", "model", req.Model, "error", err)
+		return nil, fmt.Errorf("failed to start chat: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		slog.WarnContext(ctx, "Chat request rejected", "url", c.baseURL+"/api/chat", "model", req.Model, "status", resp.StatusCode)
+		return nil, fmt.Errorf("failed to start chat: status %d: %s", resp.StatusCode, string(body))
+	}
+
+	responseChan := make(chan ChatResponse, 10)
+
+	go func() {
+		defer close(responseChan)
+		defer resp.Body.Close()
+
+		// Close the body as soon as the request is cancelled so the backend
+		// stops generating, even while the scanner is blocked on a read
+		stop := context.AfterFunc(ctx, func() { resp.Body.Close() })
+		defer stop()
+
+		scanner := bufio.NewScanner(resp.Body)
+		scanner.Buffer(make([]byte, 64*1024), maxNDJSONLineSize)
+
+		toolCallCount := 0
+		for scanner.Scan() {
+			line := scanner.Bytes()
+			if len(bytes.TrimSpace(line)) == 0 {
+				continue
+			}
+
+			var chunk ollamaChatChunk
+			if err := json.Unmarshal(line, &chunk); err != nil {
+				sendResponse(ctx, responseChan, ChatResponse{
+					Model: req.Model,
+					Done:  true,
+					Error: fmt.Sprintf("failed to parse chunk: %v", err),
+				})
+				return
+			}
+
+			if chunk.Error != "" {
+				sendResponse(ctx, responseChan, ChatResponse{
+					Model: req.Model,
+					Done:  true,
+					Error: chunk.Error,
+				})
+				return
+			}
+
+			// Ollama sends complete tool calls without IDs; give them one so
+			// they can be matched with their results like OpenAI tool calls
+			toolCalls := make([]ToolCall, 0, len(chunk.Message.ToolCalls))
+			for _, tc := range chunk.Message.ToolCalls {
+				args, _ := json.Marshal(tc.Function.Arguments)
+				toolCallCount++
+				toolCalls = append(toolCalls, ToolCall{
+					ID:   fmt.Sprintf("call_%d", toolCallCount),
+					Type: "function",
+					Function: FunctionCall{
+						Name:      tc.Function.Name,
+						Arguments: string(args),
+					},
+				})
+			}
+
+			doneReason := chunk.DoneReason
+			if chunk.Done && toolCallCount > 0 {
+				doneReason = "tool_calls"
+			}
+
+			response := ChatResponse{
+				Model: chunk.Model,
+				Message: ChatMessage{
+					Role:      chunk.Message.Role,
+					Content:   chunk.Message.Content,
+					ToolCalls: toolCalls,
+				},
+				Done:       chunk.Done,
+				DoneReason: doneReason,
+			}
+			if chunk.Done {
+				response.Usage, response.Timings = chunk.stats()
+			}
+
+			if !sendResponse(ctx, responseChan, response) {
+				return
+			}
+
+			if chunk.Done {
+				return
+			}
+		}
+
+		if err := scanner.Err(); err != nil {
+			sendResponse(ctx, responseChan, ChatResponse{
+				Model: req.Model,
+				Done:  true,
+				Error: fmt.Sprintf("scanner error: %v", err),
+			})
+		}
+	}()
+
+	return responseChan, nil
+}
+
+// UnloadModel evicts a model from memory by setting its keep_alive to zero
+func (c *OllamaClient) UnloadModel(ctx context.Context, modelName string) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"model":      modelName,
+		"keep_alive": 0,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/api/generate", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to unload model: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to unload model: status %d: %s", resp.StatusCode, string(body))
+	}
+
+	return nil
+}
+
+// CountTokens estimates the token count of text. Ollama has no tokenize
+// endpoint, so this uses the common approximation of four bytes per token.
+func (c *OllamaClient) CountTokens(ctx context.Context, text string) (int, error) {
+	return (len(text) + 3) / 4, nil
+}
+
+// ContextSize returns the context length of the first loaded model. Use
+// ModelContextSize when the model is known, as Ollama can run several.
+func (c *OllamaClient) ContextSize(ctx context.Context) (int, error) {
+	var ps ollamaPsResponse
+	if err := c.getJSON(ctx, "/api/ps", &ps); err != nil {
+		return 0, fmt.Errorf("failed to get running models: %w", err)
+	}
+
+	for _, m := range ps.Models {
+		if m.ContextLength > 0 {
+			return m.ContextLength, nil
+		}
+	}
+
+	return 0, fmt.Errorf("no loaded model reports a context length")
+}
+
+// ollamaDefaultContextLength is the context length Ollama loads models with
+// when neither the request nor the Modelfile sets num_ctx
+const ollamaDefaultContextLength = 4096
+
+// ModelContextSize returns the context length Ollama runs a model with: the
+// request's num_ctx, then the length the model is loaded with, then the
+// Modelfile's num_ctx, then Ollama's default. Only the length of a loaded
+// model is reported as fixed, so it is looked up again once the model loads.
+func (c *OllamaClient) ModelContextSize(ctx context.Context, model string, opts *Options) (int, bool, error) {
+	if opts != nil && opts.NumCtx != nil {
+		return *opts.NumCtx, false, nil
+	}
+
+	var ps ollamaPsResponse
+	if err := c.getJSON(ctx, "/api/ps", &ps); err != nil {
+		return 0, false, fmt.Errorf("failed to get running models: %w", err)
+	}
+
+	for _, m := range ps.Models {
+		if (sameOllamaModel(m.Name, model) || sameOllamaModel(m.Model, model)) && m.ContextLength > 0 {
+			return m.ContextLength, true, nil
+		}
+	}
+
+	var show ollamaShowResponse
+	if err := c.postJSON(ctx, "/api/show", map[string]string{"model": model}, &show); err != nil {
+		return 0, false, fmt.Errorf("failed to show model: %w", err)
+	}
+
+	for _, line := range strings.Split(show.Parameters, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "num_ctx" {
+			if n, err := strconv.Atoi(fields[1]); err == nil && n > 0 {
+				return n, false, nil
+			}
+		}
+	}
+
+	return ollamaDefaultContextLength, false, nil
+}
+
+// sameOllamaModel reports whether two model names refer to the same model.
+// Names without a tag mean the latest tag.
+func sameOllamaModel(a, b string) bool {
+	if a == "" || b == "" {
+		return false
+	}
+	if !strings.Contains(a, ":") {
+		a += ":latest"
+	}
+	if !strings.Contains(b, ":") {
+		b += ":latest"
+	}
+	return a == b
+}
+
+// CheckHealth reports whether the Ollama server is reachable
+func (c *OllamaClient) CheckHealth(ctx context.Context) (string, error) {
+	var version struct {
+		Version string `json:"version"`
+	}
+	if err := c.getJSON(ctx, "/api/version", &version); err != nil {
+		return StateDown, err
+	}
+	return StateReady, nil
+}
+
+// getJSON makes a GET request and decodes the JSON response
+func (c *OllamaClient) getJSON(ctx context.Context, path string, result interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+path, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	return c.doJSON(req, result)
+}
+
+// postJSON makes a POST request with a JSON body and decodes the JSON response
+func (c *OllamaClient) postJSON(ctx context.Context, path string, body, result interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+path, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	return c.doJSON(req, result)
+}
+
+// doJSON sends a request and decodes the JSON response
+func (c *OllamaClient) doJSON(req *http.Request, result interface{}) error {
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("status %d: %s", resp.StatusCode, string(body))
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return nil
+}
+
+// toOllamaMessages converts chat messages to Ollama's format, where tool call
+// arguments are JSON objects rather than strings
+func toOllamaMessages(messages []ChatMessage) ([]ollamaMessage, error) {
+	result := make([]ollamaMessage, 0, len(messages))
+	for _, msg := range messages {
+		om := ollamaMessage{
+			Role:    msg.Role,
+			Content: msg.Content,
+		}
+		for _, tc := range msg.ToolCalls {
+			var call ollamaToolCall
+			call.Function.Name = tc.Function.Name
+			if tc.Function.Arguments != "" {
+				if err := json.Unmarshal([]byte(tc.Function.Arguments), &call.Function.Arguments); err != nil {
+					return nil, fmt.Errorf("invalid arguments for tool call %s: %w", tc.ID, err)
+				}
+			}
+			om.ToolCalls = append(om.ToolCalls, call)
+		}
+		result = append(result, om)
+	}
+	return result, nil
+}
@@ -0,0 +1,171 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOllamaClient_ListModels(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/tags", r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"models":[{"name":"llama3.2:latest","model":"llama3.2:latest","modified_at":"2025-01-02T03:04:05Z","size":2019393189,"digest":"a80c4f17acd5"}]}`)
+	}))
+	defer server.Close()
+
+	client, err := NewOllamaClient(server.URL)
+	assert.NoError(t, err)
+
+	models, err := client.ListModels(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, models, 1)
+	assert.Equal(t, "llama3.2:latest", models[0].Name)
+	assert.Equal(t, int64(2019393189), models[0].Size)
+	assert.Equal(t, "a80c4f17acd5", models[0].Digest)
+	assert.Equal(t, "2025-01-02T03:04:05Z", models[0].ModifiedAt)
+}
+
+func TestOllamaClient_ChatStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/chat", r.URL.Path)
+
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		assert.Equal(t, true, body["stream"])
+		options := body["options"].(map[string]interface{})
+		assert.Equal(t, float64(64), options["num_predict"])
+
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		fmt.Fprintln(w, `{"model":"llama3.2","message":{"role":"assistant","content":"Hel"},"done":false}`)
+		fmt.Fprintln(w, `{"model":"llama3.2","message":{"role":"assistant","content":"lo"},"done":false}`)
+		fmt.Fprintln(w, `{"model":"llama3.2","message":{"role":"assistant","content":""},"done":true,"done_reason":"stop","prompt_eval_count":10,"prompt_eval_duration":500000000,"eval_count":2,"eval_duration":250000000}`)
+	}))
+	defer server.Close()
+
+	client, _ := NewOllamaClient(server.URL)
+	maxTokens := 64
+	stream, err := client.ChatStream(context.Background(), ChatRequest{
+		Model:    "llama3.2",
+		Messages: []ChatMessage{{Role: "user", Content: "hi"}},
+		Options:  &Options{MaxTokens: &maxTokens},
+	})
+	assert.NoError(t, err)
+
+	var content string
+	var last ChatResponse
+	for resp := range stream {
+		content += resp.Message.Content
+		last = resp
+	}
+	assert.Equal(t, "Hello", content)
+	assert.True(t, last.Done)
+	assert.Equal(t, "stop", last.DoneReason)
+	assert.Equal(t, &Usage{PromptTokens: 10, CompletionTokens: 2, TotalTokens: 12}, last.Usage)
+	assert.Equal(t, 20.0, last.Timings.PromptPerSecond)
+	assert.Equal(t, 8.0, last.Timings.PredictedPerSecond)
+}
+
+func TestOllamaClient_ChatStream_ToolCalls(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"model":"llama3.2","message":{"role":"assistant","content":"","tool_calls":[{"function":{"name":"web_search","arguments":{"query":"pi"}}}]},"done":false}`)
+		fmt.Fprintln(w, `{"model":"llama3.2","message":{"role":"assistant","content":""},"done":true,"done_reason":"stop"}`)
+	}))
+	defer server.Close()
+
+	client, _ := NewOllamaClient(server.URL)
+	stream, err := client.ChatStream(context.Background(), ChatRequest{
+		Model:    "llama3.2",
+		Messages: []ChatMessage{{Role: "user", Content: "search pi"}},
+	})
+	assert.NoError(t, err)
+
+	var toolCalls []ToolCall
+	var last ChatResponse
+	for resp := range stream {
+		toolCalls = append(toolCalls, resp.Message.ToolCalls...)
+		last = resp
+	}
+	assert.Len(t, toolCalls, 1)
+	assert.Equal(t, "call_1", toolCalls[0].ID)
+	assert.Equal(t, "web_search", toolCalls[0].Function.Name)
+	assert.JSONEq(t, `{"query":"pi"}`, toolCalls[0].Function.Arguments)
+	assert.Equal(t, "tool_calls", last.DoneReason)
+}
+
+func TestOllamaClient_ChatStream_RejectsGrammar(t *testing.T) {
+	client, _ := NewOllamaClient("http://localhost:1")
+	_, err := client.ChatStream(context.Background(), ChatRequest{Model: "m", Grammar: "root ::= \"a\""})
+	assert.Error(t, err)
+}
+
+func TestOllamaClient_UnloadModel(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/generate", r.URL.Path)
+
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		assert.Equal(t, "llama3.2", body["model"])
+		assert.Equal(t, float64(0), body["keep_alive"])
+
+		fmt.Fprint(w, `{"model":"llama3.2","done":true,"done_reason":"unload"}`)
+	}))
+	defer server.Close()
+
+	client, _ := NewOllamaClient(server.URL)
+	assert.NoError(t, client.UnloadModel(context.Background(), "llama3.2"))
+}
+
+func TestOllamaClient_ModelContextSize(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/ps":
+			fmt.Fprint(w, `{"models":[{"name":"llama3.2:latest","model":"llama3.2:latest","context_length":4096},{"name":"qwen3:8b","model":"qwen3:8b","context_length":8192}]}`)
+		case "/api/show":
+			var body map[string]string
+			json.NewDecoder(r.Body).Decode(&body)
+			switch body["model"] {
+			case "mistral":
+				fmt.Fprint(w, `{"parameters":"stop \"[INST]\"\nnum_ctx 16384","model_info":{"llama.context_length":32768}}`)
+			case "phi3":
+				fmt.Fprint(w, `{"parameters":"stop \"<|end|>\"","model_info":{"phi3.context_length":131072}}`)
+			default:
+				w.WriteHeader(http.StatusNotFound)
+				fmt.Fprint(w, `{"error":"model not found"}`)
+			}
+		}
+	}))
+	defer server.Close()
+
+	client, err := NewOllamaClient(server.URL)
+	assert.NoError(t, err)
+
+	numCtx := 2048
+	tests := []struct {
+		model string
+		opts  *Options
+		want  int
+		fixed bool
+	}{
+		{"qwen3:8b", nil, 8192, true},                        // Loaded, not listed first
+		{"llama3.2", nil, 4096, true},                        // Loaded, without the latest tag
+		{"mistral", nil, 16384, false},                       // Not loaded, from the Modelfile, not the trained length
+		{"phi3", nil, ollamaDefaultContextLength, false},     // Not loaded, Ollama's default
+		{"qwen3:8b", &Options{NumCtx: &numCtx}, 2048, false}, // The request's num_ctx reloads the model
+	}
+	for _, tt := range tests {
+		n, fixed, err := client.ModelContextSize(context.Background(), tt.model, tt.opts)
+		assert.NoError(t, err, tt.model)
+		assert.Equal(t, tt.want, n, tt.model)
+		assert.Equal(t, tt.fixed, fixed, tt.model)
+	}
+
+	_, _, err = client.ModelContextSize(context.Background(), "missing", nil)
+	assert.Error(t, err)
+}
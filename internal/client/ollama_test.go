@@ -0,0 +1,155 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewWithConfig_Defaults(t *testing.T) {
+	client, err := NewWithConfig(Config{BaseURL: "localhost:8080/"})
+	assert.NoError(t, err)
+	assert.Equal(t, "http://localhost:8080", client.baseURL)
+	assert.Equal(t, "/v1/chat/completions", client.paths.ChatCompletions)
+	assert.Equal(t, "/tokenize", client.paths.Tokenize)
+}
+
+func TestNewWithConfig_InvalidCAFile(t *testing.T) {
+	_, err := NewWithConfig(Config{
+		BaseURL: "https://example.com",
+		TLS:     TLSConfig{CAFile: "/nonexistent/ca.pem"},
+	})
+	assert.Error(t, err)
+}
+
+func TestClient_SendsAuthHeaders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		assert.Equal(t, "org-123", r.Header.Get("OpenAI-Organization"))
+		assert.Equal(t, "pi-lab", r.Header.Get("X-Client"))
+		assert.Equal(t, "/api/v1/models", r.URL.Path)
+
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"object":"list","data":[{"id":"gpt-oss-20b","object":"model"}]}`)
+	}))
+	defer server.Close()
+
+	client, err := NewOpenAIClient(Config{
+		BaseURL:      server.URL,
+		APIKey:       "secret-key",
+		Organization: "org-123",
+		Headers:      map[string]string{"X-Client": "pi-lab"},
+		Paths:        PathConfig{Models: "/api/v1/models"},
+	})
+	assert.NoError(t, err)
+
+	models, err := client.ListModels(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, models, 1)
+	assert.Equal(t, "gpt-oss-20b", models[0].Name)
+}
+
+func TestClient_RejectedWithoutAPIKey(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"error":"missing api key"}`)
+			return
+		}
+	}))
+	defer server.Close()
+
+	client, _ := NewOpenAIClient(Config{BaseURL: server.URL})
+	_, err := client.ListModels(context.Background())
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "401")
+}
+
+func TestClient_ChatStream_WithAuth(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer secret-key", r.Header.Get("Authorization"))
+		assert.Equal(t, "/v1/chat/completions", r.URL.Path)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"model\":\"m\",\"choices\":[{\"index\":0,\"delta\":{\"role\":\"assistant\",\"content\":\"Hi\"},\"finish_reason\":null}]}\n\n")
+		fmt.Fprint(w, "data: {\"model\":\"m\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\" there\"},\"finish_reason\":\"stop\"}]}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	client, _ := NewOpenAIClient(Config{BaseURL: server.URL, APIKey: "secret-key"})
+	stream, err := client.ChatStream(context.Background(), ChatRequest{
+		Model:    "m",
+		Messages: []ChatMessage{{Role: "user", Content: "hello"}},
+	})
+	assert.NoError(t, err)
+
+	var content string
+	var last ChatResponse
+	for resp := range stream {
+		content += resp.Message.Content
+		last = resp
+	}
+	assert.Equal(t, "Hi there", content)
+	assert.True(t, last.Done)
+	assert.Equal(t, "stop", last.DoneReason)
+}
+
+func TestClient_ChatStream_Stats(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		assert.Equal(t, map[string]interface{}{"include_usage": true}, body["stream_options"])
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"model\":\"m\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Hi\"},\"finish_reason\":null}]}\n\n")
+		fmt.Fprint(w, "data: {\"model\":\"m\",\"choices\":[{\"index\":0,\"delta\":{},\"finish_reason\":\"stop\"}],\"timings\":{\"prompt_n\":12,\"prompt_ms\":100,\"prompt_per_second\":120,\"predicted_n\":1,\"predicted_ms\":50,\"predicted_per_second\":20}}\n\n")
+		fmt.Fprint(w, "data: {\"model\":\"m\",\"choices\":[],\"usage\":{\"prompt_tokens\":12,\"completion_tokens\":1,\"total_tokens\":13}}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	client, _ := New(server.URL)
+	stream, err := client.ChatStream(context.Background(), ChatRequest{
+		Model:    "m",
+		Messages: []ChatMessage{{Role: "user", Content: "hello"}},
+	})
+	assert.NoError(t, err)
+
+	var responses []ChatResponse
+	for resp := range stream {
+		responses = append(responses, resp)
+	}
+	assert.Len(t, responses, 2)
+
+	last := responses[1]
+	assert.True(t, last.Done)
+	assert.Equal(t, "stop", last.DoneReason)
+	assert.Equal(t, &Usage{PromptTokens: 12, CompletionTokens: 1, TotalTokens: 13}, last.Usage)
+	assert.Equal(t, 12, last.Timings.PromptN)
+	assert.Equal(t, 20.0, last.Timings.PredictedPerSecond)
+}
+
+func TestClient_OpenAIContextSize(t *testing.T) {
+	client, _ := NewOpenAIClient(Config{BaseURL: "http://localhost:1"})
+
+	_, err := client.ContextSize(context.Background())
+	assert.Error(t, err)
+
+	n, err := client.CountTokens(context.Background(), "12345678")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	client, _ = NewOpenAIClient(Config{BaseURL: "http://localhost:1", ContextSize: 32768})
+	n, err = client.ContextSize(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 32768, n)
+}
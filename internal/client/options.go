@@ -0,0 +1,137 @@
+package client
+
+import "fmt"
+
+// maxStopSequences limits the number of stop sequences per request
+const maxStopSequences = 16
+
+// Options holds generation parameters forwarded to the backend.
+// Nil fields are left to the backend's defaults.
+type Options struct {
+	Temperature   *float64 `json:"temperature,omitempty"`
+	TopP          *float64 `json:"top_p,omitempty"`
+	TopK          *int     `json:"top_k,omitempty"`
+	MinP          *float64 `json:"min_p,omitempty"`
+	RepeatPenalty *float64 `json:"repeat_penalty,omitempty"`
+	Seed          *int64   `json:"seed,omitempty"`
+	MaxTokens     *int     `json:"max_tokens,omitempty"`
+	Stop          []string `json:"stop,omitempty"`
+
+	// NumCtx is the context length to load the model with. Only Ollama can
+	// change it; llama.cpp uses the length it was started with.
+	NumCtx *int `json:"num_ctx,omitempty"`
+}
+
+// Validate checks that all set options are within sensible ranges
+func (o *Options) Validate() error {
+	if o.Temperature != nil && (*o.Temperature < 0 || *o.Temperature > 5) {
+		return fmt.Errorf("temperature must be between 0 and 5")
+	}
+	if o.TopP != nil && (*o.TopP < 0 || *o.TopP > 1) {
+		return fmt.Errorf("top_p must be between 0 and 1")
+	}
+	if o.TopK != nil && *o.TopK < 0 {
+		return fmt.Errorf("top_k must not be negative")
+	}
+	if o.MinP != nil && (*o.MinP < 0 || *o.MinP > 1) {
+		return fmt.Errorf("min_p must be between 0 and 1")
+	}
+	if o.RepeatPenalty != nil && (*o.RepeatPenalty < 0 || *o.RepeatPenalty > 10) {
+		return fmt.Errorf("repeat_penalty must be between 0 and 10")
+	}
+	if o.MaxTokens != nil && *o.MaxTokens < 1 {
+		return fmt.Errorf("max_tokens must be at least 1")
+	}
+	if o.NumCtx != nil && *o.NumCtx < 1 {
+		return fmt.Errorf("num_ctx must be at least 1")
+	}
+	if len(o.Stop) > maxStopSequences {
+		return fmt.Errorf("at most %d stop sequences are allowed", maxStopSequences)
+	}
+	for _, stop := range o.Stop {
+		if stop == "" {
+			return fmt.Errorf("stop sequences must not be empty")
+		}
+	}
+	return nil
+}
+
+// Merge returns a copy of o with every option set in override taking precedence
+func (o Options) Merge(override *Options) Options {
+	if override == nil {
+		return o
+	}
+	if override.Temperature != nil {
+		o.Temperature = override.Temperature
+	}
+	if override.TopP != nil {
+		o.TopP = override.TopP
+	}
+	if override.TopK != nil {
+		o.TopK = override.TopK
+	}
+	if override.MinP != nil {
+		o.MinP = override.MinP
+	}
+	if override.RepeatPenalty != nil {
+		o.RepeatPenalty = override.RepeatPenalty
+	}
+	if override.Seed != nil {
+		o.Seed = override.Seed
+	}
+	if override.MaxTokens != nil {
+		o.MaxTokens = override.MaxTokens
+	}
+	if override.Stop != nil {
+		o.Stop = override.Stop
+	}
+	if override.NumCtx != nil {
+		o.NumCtx = override.NumCtx
+	}
+	return o
+}
+
+// apply adds the set options to an OpenAI-compatible request body
+func (o *Options) apply(body map[string]interface{}) {
+	if o.Temperature != nil {
+		body["temperature"] = *o.Temperature
+	}
+	if o.TopP != nil {
+		body["top_p"] = *o.TopP
+	}
+	if o.TopK != nil {
+		body["top_k"] = *o.TopK
+	}
+	if o.MinP != nil {
+		body["min_p"] = *o.MinP
+	}
+	if o.RepeatPenalty != nil {
+		body["repeat_penalty"] = *o.RepeatPenalty
+	}
+	if o.Seed != nil {
+		body["seed"] = *o.Seed
+	}
+	if o.MaxTokens != nil {
+		body["max_tokens"] = *o.MaxTokens
+	}
+	if len(o.Stop) > 0 {
+		body["stop"] = o.Stop
+	}
+}
+
+// ollamaOptions converts the set options to Ollama's options object
+func (o *Options) ollamaOptions() map[string]interface{} {
+	opts := make(map[string]interface{})
+	o.apply(opts)
+
+	// Ollama calls the response length limit num_predict
+	if maxTokens, ok := opts["max_tokens"]; ok {
+		delete(opts, "max_tokens")
+		opts["num_predict"] = maxTokens
+	}
+	if o.NumCtx != nil {
+		opts["num_ctx"] = *o.NumCtx
+	}
+
+	return opts
+}
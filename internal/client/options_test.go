@@ -0,0 +1,72 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOptions_Validate(t *testing.T) {
+	f := func(v float64) *float64 { return &v }
+	n := func(v int) *int { return &v }
+
+	tests := []struct {
+		name    string
+		options Options
+		wantErr string
+	}{
+		{name: "empty", options: Options{}},
+		{name: "all set", options: Options{Temperature: f(0.7), TopP: f(0.9), TopK: n(40), MinP: f(0.05), RepeatPenalty: f(1.1), MaxTokens: n(256), Stop: []string{"\n\n"}, NumCtx: n(8192)}},
+		{name: "bounds", options: Options{Temperature: f(5), TopP: f(0), TopK: n(0), MinP: f(1), RepeatPenalty: f(10), MaxTokens: n(1)}},
+		{name: "temperature too high", options: Options{Temperature: f(5.1)}, wantErr: "temperature"},
+		{name: "negative temperature", options: Options{Temperature: f(-0.1)}, wantErr: "temperature"},
+		{name: "top_p too high", options: Options{TopP: f(1.5)}, wantErr: "top_p"},
+		{name: "negative top_k", options: Options{TopK: n(-1)}, wantErr: "top_k"},
+		{name: "min_p too high", options: Options{MinP: f(2)}, wantErr: "min_p"},
+		{name: "repeat_penalty too high", options: Options{RepeatPenalty: f(11)}, wantErr: "repeat_penalty"},
+		{name: "zero max_tokens", options: Options{MaxTokens: n(0)}, wantErr: "max_tokens"},
+		{name: "zero num_ctx", options: Options{NumCtx: n(0)}, wantErr: "num_ctx"},
+		{name: "too many stop sequences", options: Options{Stop: make([]string, maxStopSequences+1)}, wantErr: "stop sequences"},
+		{name: "empty stop sequence", options: Options{Stop: []string{""}}, wantErr: "empty"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.options.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestOptions_Merge(t *testing.T) {
+	f := func(v float64) *float64 { return &v }
+	n := func(v int) *int { return &v }
+
+	base := Options{Temperature: f(0.2), TopK: n(20), Stop: []string{"END"}}
+
+	assert.Equal(t, base, base.Merge(nil))
+
+	merged := base.Merge(&Options{Temperature: f(0.9), MaxTokens: n(64), NumCtx: n(8192)})
+	assert.Equal(t, 0.9, *merged.Temperature, "overrides win")
+	assert.Equal(t, 20, *merged.TopK, "unset overrides keep the base")
+	assert.Equal(t, 64, *merged.MaxTokens)
+	assert.Equal(t, 8192, *merged.NumCtx)
+	assert.Equal(t, []string{"END"}, merged.Stop)
+	assert.Equal(t, 0.2, *base.Temperature, "the base is not modified")
+}
+
+func TestOptions_OllamaOptions(t *testing.T) {
+	n := func(v int) *int { return &v }
+
+	opts := (&Options{MaxTokens: n(64), TopK: n(40), NumCtx: n(8192)}).ollamaOptions()
+	assert.Equal(t, map[string]interface{}{"num_predict": 64, "top_k": 40, "num_ctx": 8192}, opts)
+
+	// llama.cpp's context length is fixed when it starts
+	body := make(map[string]interface{})
+	(&Options{NumCtx: n(8192)}).apply(body)
+	assert.Empty(t, body)
+}
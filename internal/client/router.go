@@ -0,0 +1,289 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// modelSeparator separates the backend name from the model name in routed
+// model names, e.g. "desktop/qwen2.5-32b"
+const modelSeparator = "/"
+
+var validBackendName = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+
+// BackendConfig configures one named backend
+type BackendConfig struct {
+	Name string `json:"name"`
+	Kind string `json:"kind"` // One of the Backend* constants, defaults to llamacpp
+	Config
+
+	// Fallback lists backends tried in order while this one is not ready
+	Fallback []string `json:"fallback,omitempty"`
+	// FallbackModel is the model requested when this backend stands in for
+	// another one; empty keeps the original model name
+	FallbackModel string `json:"fallback_model,omitempty"`
+	// WaitSeconds is how long a request waits for this backend or one of its
+	// fallbacks to become ready before failing
+	WaitSeconds int `json:"wait_seconds,omitempty"`
+}
+
+// BackendsFile is the layout of the backends config file
+type BackendsFile struct {
+	Backends []BackendConfig `json:"backends"`
+}
+
+// BackendHealth reports the health of a named backend
+type BackendHealth struct {
+	Name      string `json:"name"`
+	Kind      string `json:"kind"`
+	BaseURL   string `json:"base_url"`
+	Status    string `json:"status"` // "ok" or "down"
+	State     string `json:"state"`  // Last state seen by the health monitor
+	Error     string `json:"error,omitempty"`
+	Models    int    `json:"models"`
+	LatencyMs int64  `json:"latency_ms"`
+}
+
+// namedBackend is a backend together with its configuration
+type namedBackend struct {
+	config  BackendConfig
+	backend Backend
+}
+
+// Router serves models from several named backends. When more than one
+// backend is configured, model names are prefixed with the backend name, and
+// every request is routed to the backend that owns the model.
+type Router struct {
+	backends []namedBackend // The first backend is the default
+
+	mu     sync.RWMutex
+	owners map[string]int // Unprefixed model name -> backend index, from the last listing
+
+	statusMu    sync.Mutex
+	status      map[string]BackendStatus
+	monitoring  bool
+	changed     chan struct{} // Closed and replaced on every state transition
+	subscribers map[chan BackendStatus]struct{}
+}
+
+// LoadBackendsConfig reads a list of named backends from a JSON file
+func LoadBackendsConfig(path string) ([]BackendConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backends config: %w", err)
+	}
+
+	var file BackendsFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse backends config: %w", err)
+	}
+
+	return file.Backends, nil
+}
+
+// NewRouter creates a router over the configured backends
+func NewRouter(configs []BackendConfig) (*Router, error) {
+	if len(configs) == 0 {
+		return nil, fmt.Errorf("at least one backend is required")
+	}
+
+	r := &Router{
+		owners:      make(map[string]int),
+		status:      make(map[string]BackendStatus),
+		changed:     make(chan struct{}),
+		subscribers: make(map[chan BackendStatus]struct{}),
+	}
+
+	seen := make(map[string]bool)
+	for _, cfg := range configs {
+		if !validBackendName.MatchString(cfg.Name) {
+			return nil, fmt.Errorf("invalid backend name %q", cfg.Name)
+		}
+		if seen[cfg.Name] {
+			return nil, fmt.Errorf("duplicate backend name %q", cfg.Name)
+		}
+		seen[cfg.Name] = true
+
+		if cfg.Kind == "" {
+			cfg.Kind = BackendLlamaCpp
+		}
+
+		backend, err := NewBackend(cfg.Kind, cfg.Config)
+		if err != nil {
+			return nil, fmt.Errorf("backend %s: %w", cfg.Name, err)
+		}
+
+		r.backends = append(r.backends, namedBackend{config: cfg, backend: backend})
+		r.status[cfg.Name] = BackendStatus{Name: cfg.Name, State: StateUnknown, Since: time.Now()}
+	}
+
+	for _, nb := range r.backends {
+		for _, name := range nb.config.Fallback {
+			if name == nb.config.Name || !seen[name] {
+				return nil, fmt.Errorf("backend %s: invalid fallback %q", nb.config.Name, name)
+			}
+		}
+	}
+
+	return r, nil
+}
+
+// ListModels returns the models of all reachable backends. Backends that fail
+// are skipped unless all of them fail.
+func (r *Router) ListModels(ctx context.Context) ([]Model, error) {
+	type listing struct {
+		models []Model
+		err    error
+	}
+
+	listings := make([]listing, len(r.backends))
+	var wg sync.WaitGroup
+	for i, nb := range r.backends {
+		wg.Add(1)
+		go func(i int, nb namedBackend) {
+			defer wg.Done()
+			models, err := nb.backend.ListModels(ctx)
+			listings[i] = listing{models: models, err: err}
+		}(i, nb)
+	}
+	wg.Wait()
+
+	var result []Model
+	var errs []string
+	owners := make(map[string]int)
+	for i, l := range listings {
+		name := r.backends[i].config.Name
+		if l.err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", name, l.err))
+			continue
+		}
+		for _, m := range l.models {
+			if _, ok := owners[m.Name]; !ok {
+				owners[m.Name] = i
+			}
+			if len(r.backends) > 1 {
+				m.Name = name + modelSeparator + m.Name
+				m.Backend = name
+			}
+			result = append(result, m)
+		}
+	}
+
+	if len(errs) == len(r.backends) {
+		return nil, fmt.Errorf("all backends failed: %s", strings.Join(errs, "; "))
+	}
+
+	r.mu.Lock()
+	r.owners = owners
+	r.mu.Unlock()
+
+	return result, nil
+}
+
+// ChatStream sends the request to the backend that owns the model, or to a
+// fallback while that backend is not ready
+func (r *Router) ChatStream(ctx context.Context, req ChatRequest) (<-chan ChatResponse, error) {
+	nb, model := r.resolve(req.Model)
+	nb, model, err := r.selectReady(ctx, nb, model)
+	if err != nil {
+		return nil, err
+	}
+	req.Model = model
+	return nb.backend.ChatStream(ctx, req)
+}
+
+// UnloadModel unloads the model from the backend that owns it
+func (r *Router) UnloadModel(ctx context.Context, modelName string) error {
+	nb, model := r.resolve(modelName)
+	return nb.backend.UnloadModel(ctx, model)
+}
+
+// CountTokens counts tokens using the default backend
+func (r *Router) CountTokens(ctx context.Context, text string) (int, error) {
+	return r.backends[0].backend.CountTokens(ctx, text)
+}
+
+// ContextSize returns the context size of the default backend
+func (r *Router) ContextSize(ctx context.Context) (int, error) {
+	return r.backends[0].backend.ContextSize(ctx)
+}
+
+// CountModelTokens counts tokens using the backend that owns the model
+func (r *Router) CountModelTokens(ctx context.Context, model, text string) (int, error) {
+	nb, _ := r.resolve(model)
+	return nb.backend.CountTokens(ctx, text)
+}
+
+// ModelContextSize returns the context size of the model on the backend that
+// owns it, and whether it is fixed until the model is reloaded
+func (r *Router) ModelContextSize(ctx context.Context, model string, opts *Options) (int, bool, error) {
+	nb, name := r.resolve(model)
+	if mc, ok := nb.backend.(ModelContextSizer); ok {
+		return mc.ModelContextSize(ctx, name, opts)
+	}
+	n, err := nb.backend.ContextSize(ctx)
+	return n, err == nil, err
+}
+
+// Health checks every backend by listing its models
+func (r *Router) Health(ctx context.Context) []BackendHealth {
+	result := make([]BackendHealth, len(r.backends))
+
+	var wg sync.WaitGroup
+	for i, nb := range r.backends {
+		wg.Add(1)
+		go func(i int, nb namedBackend) {
+			defer wg.Done()
+
+			health := BackendHealth{
+				Name:    nb.config.Name,
+				Kind:    nb.config.Kind,
+				BaseURL: nb.config.BaseURL,
+				Status:  "ok",
+				State:   r.backendStatus(nb.config.Name).State,
+			}
+
+			start := time.Now()
+			models, err := nb.backend.ListModels(ctx)
+			health.LatencyMs = time.Since(start).Milliseconds()
+			if err != nil {
+				health.Status = "down"
+				health.Error = err.Error()
+			}
+			health.Models = len(models)
+
+			result[i] = health
+		}(i, nb)
+	}
+	wg.Wait()
+
+	return result
+}
+
+// resolve returns the backend for a model name and the name the backend knows
+// the model by. Names are matched by backend prefix first, then by the owner
+// seen in the last listing, falling back to the default backend.
+func (r *Router) resolve(model string) (namedBackend, string) {
+	if prefix, rest, ok := strings.Cut(model, modelSeparator); ok && len(r.backends) > 1 {
+		for _, nb := range r.backends {
+			if nb.config.Name == prefix {
+				return nb, rest
+			}
+		}
+	}
+
+	r.mu.RLock()
+	i, ok := r.owners[model]
+	r.mu.RUnlock()
+	if ok {
+		return r.backends[i], model
+	}
+
+	return r.backends[0], model
+}
@@ -0,0 +1,171 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newModelServer serves a llama.cpp-style model list and records the model
+// requested by chat completions
+func newModelServer(t *testing.T, model string, chatModel *string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/models":
+			fmt.Fprintf(w, `{"object":"list","data":[{"id":%q,"object":"model"}]}`, model)
+		case "/v1/chat/completions":
+			var body map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&body)
+			*chatModel = body["model"].(string)
+			fmt.Fprint(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"ok\"},\"finish_reason\":\"stop\"}]}\n\n")
+			fmt.Fprint(w, "data: [DONE]\n\n")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestNewRouter_Validation(t *testing.T) {
+	_, err := NewRouter(nil)
+	assert.Error(t, err)
+
+	_, err = NewRouter([]BackendConfig{{Name: "bad name"}})
+	assert.Error(t, err)
+
+	_, err = NewRouter([]BackendConfig{{Name: "pi"}, {Name: "pi"}})
+	assert.Error(t, err)
+
+	_, err = NewRouter([]BackendConfig{{Name: "pi", Kind: "unknown"}})
+	assert.Error(t, err)
+}
+
+func TestRouter_ListModelsAndRoute(t *testing.T) {
+	var piModel, desktopModel string
+	pi := newModelServer(t, "qwen2.5-3b", &piModel)
+	defer pi.Close()
+	desktop := newModelServer(t, "qwen2.5-32b", &desktopModel)
+	defer desktop.Close()
+
+	router, err := NewRouter([]BackendConfig{
+		{Name: "pi", Config: Config{BaseURL: pi.URL}},
+		{Name: "desktop", Kind: BackendOpenAI, Config: Config{BaseURL: desktop.URL}},
+	})
+	assert.NoError(t, err)
+
+	models, err := router.ListModels(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, models, 2)
+	assert.Equal(t, "pi/qwen2.5-3b", models[0].Name)
+	assert.Equal(t, "pi", models[0].Backend)
+	assert.Equal(t, "desktop/qwen2.5-32b", models[1].Name)
+
+	stream, err := router.ChatStream(context.Background(), ChatRequest{Model: "desktop/qwen2.5-32b"})
+	assert.NoError(t, err)
+	for range stream {
+	}
+	assert.Equal(t, "qwen2.5-32b", desktopModel)
+	assert.Empty(t, piModel)
+
+	// Unprefixed names are routed to the backend that listed them
+	stream, err = router.ChatStream(context.Background(), ChatRequest{Model: "qwen2.5-3b"})
+	assert.NoError(t, err)
+	for range stream {
+	}
+	assert.Equal(t, "qwen2.5-3b", piModel)
+}
+
+func TestRouter_SingleBackendKeepsNames(t *testing.T) {
+	var chatModel string
+	server := newModelServer(t, "org/model.gguf", &chatModel)
+	defer server.Close()
+
+	router, err := NewRouter([]BackendConfig{{Name: "default", Config: Config{BaseURL: server.URL}}})
+	assert.NoError(t, err)
+
+	models, err := router.ListModels(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "org/model.gguf", models[0].Name)
+	assert.Empty(t, models[0].Backend)
+
+	stream, err := router.ChatStream(context.Background(), ChatRequest{Model: "org/model.gguf"})
+	assert.NoError(t, err)
+	for range stream {
+	}
+	assert.Equal(t, "org/model.gguf", chatModel)
+}
+
+func TestRouter_Health(t *testing.T) {
+	var chatModel string
+	up := newModelServer(t, "m", &chatModel)
+	defer up.Close()
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+
+	router, _ := NewRouter([]BackendConfig{
+		{Name: "up", Config: Config{BaseURL: up.URL}},
+		{Name: "down", Config: Config{BaseURL: down.URL}},
+	})
+
+	// A failing backend does not hide the models of the others
+	models, err := router.ListModels(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, models, 1)
+
+	health := router.Health(context.Background())
+	assert.Len(t, health, 2)
+	assert.Equal(t, "ok", health[0].Status)
+	assert.Equal(t, 1, health[0].Models)
+	assert.Equal(t, "down", health[1].Status)
+	assert.NotEmpty(t, health[1].Error)
+}
+
+func TestRouter_ModelContextSize(t *testing.T) {
+	var shown string
+	ollama := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/tags":
+			fmt.Fprint(w, `{"models":[{"name":"small:latest"},{"name":"large:latest"}]}`)
+		case "/api/ps":
+			fmt.Fprint(w, `{"models":[{"name":"small:latest","context_length":2048}]}`)
+		case "/api/show":
+			var body map[string]string
+			json.NewDecoder(r.Body).Decode(&body)
+			shown = body["model"]
+			fmt.Fprint(w, `{"parameters":"num_ctx 8192","model_info":{"qwen2.context_length":32768}}`)
+		}
+	}))
+	defer ollama.Close()
+	var chatModel string
+	pi := newModelServer(t, "m", &chatModel)
+	defer pi.Close()
+
+	router, err := NewRouter([]BackendConfig{
+		{Name: "pi", Config: Config{BaseURL: pi.URL, ContextSize: 4096}},
+		{Name: "desktop", Kind: BackendOllama, Config: Config{BaseURL: ollama.URL}},
+	})
+	assert.NoError(t, err)
+
+	// The size is of the requested model, not of another one that is loaded
+	n, fixed, err := router.ModelContextSize(context.Background(), "desktop/large:latest", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 8192, n)
+	assert.False(t, fixed, "not loaded yet")
+	assert.Equal(t, "large:latest", shown)
+
+	n, fixed, err = router.ModelContextSize(context.Background(), "desktop/small:latest", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 2048, n)
+	assert.True(t, fixed)
+
+	n, fixed, err = router.ModelContextSize(context.Background(), "pi/m", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 4096, n)
+	assert.True(t, fixed)
+}
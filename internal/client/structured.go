@@ -0,0 +1,45 @@
+package client
+
+import "fmt"
+
+// Response format types understood by llama.cpp's OpenAI endpoint
+const (
+	ResponseFormatJSONObject = "json_object"
+	ResponseFormatJSONSchema = "json_schema"
+)
+
+// ResponseFormat constrains the model's output to JSON
+type ResponseFormat struct {
+	Type       string          `json:"type"`
+	JSONSchema *JSONSchemaSpec `json:"json_schema,omitempty"`
+}
+
+// JSONSchemaSpec names a JSON schema the output must follow
+type JSONSchemaSpec struct {
+	Name   string                 `json:"name,omitempty"`
+	Schema map[string]interface{} `json:"schema"`
+	Strict bool                   `json:"strict,omitempty"`
+}
+
+// Validate checks that the response format is well formed
+func (f *ResponseFormat) Validate() error {
+	switch f.Type {
+	case ResponseFormatJSONObject:
+		return nil
+	case ResponseFormatJSONSchema:
+		if f.JSONSchema == nil || f.JSONSchema.Schema == nil {
+			return fmt.Errorf("json_schema.schema is required for type json_schema")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported response_format type: %q", f.Type)
+	}
+}
+
+// Schema returns the JSON schema to validate against, or nil when any JSON is accepted
+func (f *ResponseFormat) Schema() map[string]interface{} {
+	if f.JSONSchema == nil {
+		return nil
+	}
+	return f.JSONSchema.Schema
+}
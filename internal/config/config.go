@@ -0,0 +1,387 @@
+// Package config loads the server configuration from a YAML file, GOLLAMA_*
+// environment variables and command-line flags, in increasing precedence
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/aristath/gollama-ui/internal/client"
+)
+
+// EnvPrefix starts the environment variables that override the config file,
+// such as GOLLAMA_SERVER_PORT for server.port
+const EnvPrefix = "GOLLAMA_"
+
+// Config is the configuration of the server
+type Config struct {
+	Server   ServerConfig   `yaml:"server"`
+	Backend  BackendConfig  `yaml:"backend"`
+	Services ServicesConfig `yaml:"services"`
+	Chat     ChatConfig     `yaml:"chat"`
+	Models   ModelsConfig   `yaml:"models"`
+	Tools    ToolsConfig    `yaml:"tools"`
+	Auth     AuthConfig     `yaml:"auth"`
+	Logging  LoggingConfig  `yaml:"logging"`
+}
+
+// ServerConfig configures the HTTP server
+type ServerConfig struct {
+	Host           string        `yaml:"host"`
+	Port           string        `yaml:"port"`
+	StaticDir      string        `yaml:"static_dir"` // Overrides the embedded web UI
+	AllowedOrigins []string      `yaml:"allowed_origins"`
+	RateLimitsFile string        `yaml:"rate_limits_file"`
+	DrainTimeout   time.Duration `yaml:"drain_timeout"`
+	TLS            TLSConfig     `yaml:"tls"`
+}
+
+// TLSConfig configures HTTPS
+type TLSConfig struct {
+	Cert         string `yaml:"cert"`
+	Key          string `yaml:"key"`
+	SelfSigned   bool   `yaml:"self_signed"`
+	HTTPRedirect string `yaml:"http_redirect"`
+}
+
+// BackendConfig configures the inference backend
+type BackendConfig struct {
+	Kind         string        `yaml:"kind"`
+	URL          string        `yaml:"url"`
+	APIKey       string        `yaml:"api_key"`
+	ConfigFile   string        `yaml:"config_file"`   // JSON file with connection settings
+	BackendsFile string        `yaml:"backends_file"` // JSON file listing named backends
+	Wait         time.Duration `yaml:"wait"`
+}
+
+// ServicesConfig configures the services tools depend on
+type ServicesConfig struct {
+	DDGSURL        string        `yaml:"ddgs_url"`
+	SentinelURL    string        `yaml:"sentinel_url"`
+	HealthInterval time.Duration `yaml:"health_interval"`
+}
+
+// ChatConfig configures chat generation
+type ChatConfig struct {
+	Timeout          time.Duration `yaml:"timeout"`
+	Slots            int           `yaml:"slots"`
+	ResumeGrace      time.Duration `yaml:"resume_grace"`
+	NotifyURL        string        `yaml:"notify_url"`
+	NotifyFormat     string        `yaml:"notify_format"`
+	NotifyHosts      []string      `yaml:"notify_hosts"`
+	ContextStrategy  string        `yaml:"context_strategy"`
+	ContextKeepTurns int           `yaml:"context_keep_turns"`
+	ContextReserve   int           `yaml:"context_reserve"`
+}
+
+// ModelsConfig locates the models the model manager can load
+type ModelsConfig struct {
+	Dir          string `yaml:"dir"`
+	ServerConfig string `yaml:"server_config"` // llama-server configuration file
+}
+
+// ToolsConfig holds the global tool settings, which users can override
+type ToolsConfig struct {
+	WebSearch bool `yaml:"web_search"`
+	Feeds     bool `yaml:"feeds"`
+	Sentinel  bool `yaml:"sentinel"`
+
+	// CustomFeeds maps news topics to RSS feed URLs, added to the built-in feeds
+	CustomFeeds map[string]string `yaml:"custom_feeds"`
+}
+
+// AuthConfig configures authentication
+type AuthConfig struct {
+	ConfigFile string `yaml:"config_file"` // JSON file with tokens, users and proxy settings
+}
+
+// LoggingConfig configures logging
+type LoggingConfig struct {
+	Level   string `yaml:"level"`
+	Format  string `yaml:"format"`
+	Content bool   `yaml:"content"`
+}
+
+// Default returns the configuration used without a config file
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Host:         "0.0.0.0",
+			Port:         "3000",
+			DrainTimeout: 30 * time.Second,
+		},
+		Backend: BackendConfig{
+			Kind: client.BackendLlamaCpp,
+			URL:  "http://localhost:8080",
+			Wait: 2 * time.Minute,
+		},
+		Services: ServicesConfig{
+			DDGSURL:        "http://localhost:8000",
+			SentinelURL:    "http://localhost:8081",
+			HealthInterval: 5 * time.Second,
+		},
+		Chat: ChatConfig{
+			Timeout:          24 * time.Hour,
+			Slots:            1,
+			ResumeGrace:      2 * time.Minute,
+			NotifyFormat:     "json",
+			ContextStrategy:  "truncate",
+			ContextKeepTurns: 2,
+			ContextReserve:   512,
+		},
+		Models: ModelsConfig{
+			Dir:          "/mnt/nvme/llm/models",
+			ServerConfig: "/mnt/nvme/llm/config/llama-server.conf",
+		},
+		Logging: LoggingConfig{
+			Level:  "info",
+			Format: "text",
+		},
+	}
+}
+
+// Validate checks the configuration, reporting every invalid setting
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	port, err := strconv.Atoi(c.Server.Port)
+	check(err == nil && port > 0 && port < 65536, "server.port: %q is not a port number", c.Server.Port)
+	check(c.Server.DrainTimeout >= 0, "server.drain_timeout: must not be negative")
+	check((c.Server.TLS.Cert == "") == (c.Server.TLS.Key == "") || c.Server.TLS.SelfSigned,
+		"server.tls: cert and key must be set together")
+	check(c.Server.TLS.HTTPRedirect == "" || c.Server.TLS.Cert != "" || c.Server.TLS.SelfSigned,
+		"server.tls.http_redirect: needs a certificate or self_signed")
+
+	switch c.Backend.Kind {
+	case client.BackendLlamaCpp, client.BackendOllama, client.BackendOpenAI:
+	default:
+		errs = append(errs, fmt.Errorf("backend.kind: unknown backend %q", c.Backend.Kind))
+	}
+	check(validURL(c.Backend.URL), "backend.url: %q is not an http(s) URL", c.Backend.URL)
+	check(c.Backend.Wait >= 0, "backend.wait: must not be negative")
+
+	check(validURL(c.Services.DDGSURL), "services.ddgs_url: %q is not an http(s) URL", c.Services.DDGSURL)
+	check(validURL(c.Services.SentinelURL), "services.sentinel_url: %q is not an http(s) URL", c.Services.SentinelURL)
+	check(c.Services.HealthInterval > 0, "services.health_interval: must be positive")
+
+	check(c.Chat.Timeout > 0, "chat.timeout: must be positive")
+	check(c.Chat.Slots >= 1, "chat.slots: must be at least 1")
+	check(c.Chat.ResumeGrace >= 0, "chat.resume_grace: must not be negative")
+	check(c.Chat.ContextKeepTurns >= 0, "chat.context_keep_turns: must not be negative")
+	check(c.Chat.ContextReserve >= 0, "chat.context_reserve: must not be negative")
+
+	for topic, feedURL := range c.Tools.CustomFeeds {
+		check(validURL(feedURL), "tools.custom_feeds: %q of %s is not an http(s) URL", feedURL, topic)
+	}
+
+	switch strings.ToLower(c.Logging.Level) {
+	case "debug", "info", "warn", "error":
+	default:
+		errs = append(errs, fmt.Errorf("logging.level: unknown level %q", c.Logging.Level))
+	}
+	format := strings.ToLower(c.Logging.Format)
+	check(format == "text" || format == "json", "logging.format: unknown format %q", c.Logging.Format)
+
+	return errors.Join(errs...)
+}
+
+// validURL reports whether s is an absolute http(s) URL
+func validURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// Loader loads the configuration again on every reload, keeping the
+// command-line flags that were set
+type Loader struct {
+	path      string
+	flags     map[string]string
+	lookupEnv func(string) (string, bool)
+}
+
+// NewLoader creates a loader for the config file at path, which doesn't need
+// to exist. Flags set on fs take precedence over the file and environment.
+func NewLoader(path string, fs *flag.FlagSet, lookupEnv func(string) (string, bool)) *Loader {
+	l := &Loader{
+		path:      path,
+		flags:     make(map[string]string),
+		lookupEnv: lookupEnv,
+	}
+	fs.Visit(func(f *flag.Flag) {
+		l.flags[f.Name] = f.Value.String()
+	})
+	return l
+}
+
+// Path returns the path of the config file
+func (l *Loader) Path() string {
+	return l.path
+}
+
+// Load reads the configuration and validates it
+func (l *Loader) Load() (*Config, error) {
+	cfg := Default()
+
+	data, err := os.ReadFile(l.path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+	if err == nil {
+		if err := decode(data, cfg); err != nil {
+			return nil, fmt.Errorf("%s: %w", l.path, err)
+		}
+	}
+
+	if err := applyEnv(cfg, l.lookupEnv); err != nil {
+		return nil, err
+	}
+
+	fs := flag.NewFlagSet("config", flag.ContinueOnError)
+	BindFlags(fs, cfg)
+	for name, value := range l.flags {
+		if fs.Lookup(name) == nil {
+			continue // Not a setting, such as -config-file
+		}
+		if err := fs.Set(name, value); err != nil {
+			return nil, fmt.Errorf("-%s: %w", name, err)
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// decode reads YAML into cfg, rejecting unknown settings so typos don't go
+// unnoticed
+func decode(data []byte, cfg *Config) error {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && err != io.EOF {
+		return err
+	}
+	return nil
+}
+
+// applyEnv overrides the settings of cfg that have a GOLLAMA_* environment
+// variable. GOLLAMA_API_KEY is kept for backend.api_key.
+func applyEnv(cfg *Config, lookupEnv func(string) (string, bool)) error {
+	if key, ok := lookupEnv(EnvPrefix + "API_KEY"); ok {
+		cfg.Backend.APIKey = key
+	}
+
+	var errs []error
+	walk(reflect.ValueOf(cfg).Elem(), "", func(name string, v reflect.Value) {
+		env := EnvPrefix + strings.ToUpper(strings.ReplaceAll(name, ".", "_"))
+		value, ok := lookupEnv(env)
+		if !ok {
+			return
+		}
+		if err := setValue(v, value); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", env, err))
+		}
+	})
+	return errors.Join(errs...)
+}
+
+// walk calls fn with the dotted YAML name of every setting in v
+func walk(v reflect.Value, prefix string, fn func(name string, v reflect.Value)) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0]
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+		field := v.Field(i)
+		if field.Kind() == reflect.Struct {
+			walk(field, name, fn)
+			continue
+		}
+		fn(name, field)
+	}
+}
+
+// setValue parses s into a setting
+func setValue(v reflect.Value, s string) error {
+	switch {
+	case v.Type() == reflect.TypeOf(time.Duration(0)):
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+	case v.Kind() == reflect.String:
+		v.SetString(s)
+	case v.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case v.Kind() == reflect.Int:
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(n))
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.String:
+		v.Set(reflect.ValueOf(splitList(s)))
+	case v.Type() == reflect.TypeOf(map[string]string(nil)):
+		m := make(map[string]string)
+		for _, item := range splitList(s) {
+			key, value, ok := strings.Cut(item, "=")
+			if !ok {
+				return fmt.Errorf("%q is not a key=value pair", item)
+			}
+			m[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+		v.Set(reflect.ValueOf(m))
+	default:
+		return fmt.Errorf("unsupported setting type %s", v.Type())
+	}
+	return nil
+}
+
+// splitList splits a comma-separated value, dropping empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Changed returns the dotted names of the settings that differ between a and b
+func Changed(a, b *Config) []string {
+	values := make(map[string]reflect.Value)
+	walk(reflect.ValueOf(a).Elem(), "", func(name string, v reflect.Value) {
+		values[name] = v
+	})
+
+	var changed []string
+	walk(reflect.ValueOf(b).Elem(), "", func(name string, v reflect.Value) {
+		if !reflect.DeepEqual(values[name].Interface(), v.Interface()) {
+			changed = append(changed, name)
+		}
+	})
+	return changed
+}
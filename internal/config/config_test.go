@@ -0,0 +1,276 @@
+package config
+
+import (
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// env returns a lookup function over vars
+func env(vars map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		v, ok := vars[key]
+		return v, ok
+	}
+}
+
+// newLoader returns a loader for path with the flags in args set
+func newLoader(t *testing.T, path string, args []string, vars map[string]string) *Loader {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	BindFlags(fs, Default())
+	fs.String("config-file", "", "")
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	return NewLoader(path, fs, env(vars))
+}
+
+func writeFile(t *testing.T, path, content string) {
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoad_WithoutFile(t *testing.T) {
+	cfg, err := newLoader(t, filepath.Join(t.TempDir(), "config.yaml"), nil, nil).Load()
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, Default(), cfg)
+}
+
+func TestLoad_Precedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeFile(t, path, `
+server:
+  port: "4000"
+  host: 127.0.0.1
+chat:
+  timeout: 1h
+  slots: 2
+tools:
+  web_search: true
+`)
+
+	l := newLoader(t, path, []string{"-port", "6000", "-config-file", path}, map[string]string{
+		"GOLLAMA_SERVER_PORT":            "5000",
+		"GOLLAMA_CHAT_SLOTS":             "3",
+		"GOLLAMA_SERVER_ALLOWED_ORIGINS": "https://a.example, https://b.example",
+		"GOLLAMA_SERVER_TLS_SELF_SIGNED": "true",
+		"GOLLAMA_API_KEY":                "secret",
+		"GOLLAMA_TOOLS_CUSTOM_FEEDS":     "tech=https://example.com/tech.rss, local=https://example.com/local.rss",
+	})
+	cfg, err := l.Load()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, "6000", cfg.Server.Port, "flags override the environment")
+	assert.Equal(t, 3, cfg.Chat.Slots, "the environment overrides the file")
+	assert.Equal(t, "127.0.0.1", cfg.Server.Host)
+	assert.Equal(t, time.Hour, cfg.Chat.Timeout)
+	assert.True(t, cfg.Tools.WebSearch)
+	assert.Equal(t, []string{"https://a.example", "https://b.example"}, cfg.Server.AllowedOrigins)
+	assert.True(t, cfg.Server.TLS.SelfSigned)
+	assert.Equal(t, "secret", cfg.Backend.APIKey)
+	assert.Equal(t, map[string]string{"tech": "https://example.com/tech.rss", "local": "https://example.com/local.rss"}, cfg.Tools.CustomFeeds)
+	assert.Equal(t, 512, cfg.Chat.ContextReserve, "unset settings keep their defaults")
+}
+
+func TestLoad_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		vars    map[string]string
+		wantErr []string
+	}{
+		{
+			name:    "unknown setting",
+			file:    "chat:\n  timout: 1h\n",
+			wantErr: []string{"timout"},
+		},
+		{
+			name:    "wrong type",
+			file:    "chat:\n  slots: many\n",
+			wantErr: []string{"line 2", "many"},
+		},
+		{
+			name: "invalid values",
+			file: "server:\n  port: \"0\"\nbackend:\n  kind: llamafile\nlogging:\n  level: verbose\n",
+			wantErr: []string{
+				`server.port: "0" is not a port number`,
+				`backend.kind: unknown backend "llamafile"`,
+				`logging.level: unknown level "verbose"`,
+			},
+		},
+		{
+			name:    "invalid environment variable",
+			vars:    map[string]string{"GOLLAMA_CHAT_TIMEOUT": "forever"},
+			wantErr: []string{"GOLLAMA_CHAT_TIMEOUT"},
+		},
+		{
+			name:    "invalid custom feed",
+			file:    "tools:\n  custom_feeds:\n    tech: ftp://example.com/tech.rss\n",
+			wantErr: []string{`tools.custom_feeds: "ftp://example.com/tech.rss" of tech is not an http(s) URL`},
+		},
+		{
+			name:    "custom feed without topic",
+			vars:    map[string]string{"GOLLAMA_TOOLS_CUSTOM_FEEDS": "https://example.com/tech.rss"},
+			wantErr: []string{"GOLLAMA_TOOLS_CUSTOM_FEEDS", "key=value"},
+		},
+		{
+			name:    "tls key without certificate",
+			vars:    map[string]string{"GOLLAMA_SERVER_TLS_KEY": "key.pem"},
+			wantErr: []string{"server.tls: cert and key must be set together"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			writeFile(t, path, tt.file)
+
+			_, err := newLoader(t, path, nil, tt.vars).Load()
+			if !assert.Error(t, err) {
+				return
+			}
+			for _, want := range tt.wantErr {
+				assert.Contains(t, err.Error(), want)
+			}
+		})
+	}
+}
+
+func TestUpdate_KeepsComments(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeFile(t, path, `# Server settings
+server:
+  port: "4000" # Behind the proxy
+tools:
+  web_search: false
+`)
+
+	err := Update(path, map[string]interface{}{
+		"tools.web_search": true,
+		"tools.feeds":      true,
+		"chat.timeout":     "2h0m0s",
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	data, err := os.ReadFile(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Contains(t, string(data), "# Server settings")
+	assert.Contains(t, string(data), "# Behind the proxy")
+
+	cfg, err := newLoader(t, path, nil, nil).Load()
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "4000", cfg.Server.Port)
+	assert.True(t, cfg.Tools.WebSearch)
+	assert.True(t, cfg.Tools.Feeds)
+	assert.Equal(t, 2*time.Hour, cfg.Chat.Timeout)
+}
+
+func TestMigrate(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	writeFile(t, filepath.Join(dir, "tool-settings.json"), `{"enable_web_search": true, "enable_feeds": false, "enable_sentinel": true}`)
+
+	if !assert.NoError(t, Migrate(path, dir)) {
+		return
+	}
+
+	cfg, err := newLoader(t, path, nil, nil).Load()
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, ToolsConfig{WebSearch: true, Sentinel: true}, cfg.Tools)
+
+	// The old file is kept aside and not imported again
+	assert.NoFileExists(t, filepath.Join(dir, "tool-settings.json"))
+	assert.FileExists(t, filepath.Join(dir, "tool-settings.json.migrated"))
+	assert.NoError(t, Migrate(path, dir))
+}
+
+func TestMigrate_CustomFeeds(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	writeFile(t, path, "tools:\n  feeds: true\n")
+	writeFile(t, filepath.Join(dir, "custom-feeds.json"), `{"tech": "https://example.com/tech.rss"}`)
+
+	if !assert.NoError(t, Migrate(path, dir)) {
+		return
+	}
+
+	cfg, err := newLoader(t, path, nil, nil).Load()
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, ToolsConfig{Feeds: true, CustomFeeds: map[string]string{"tech": "https://example.com/tech.rss"}}, cfg.Tools)
+	assert.NoFileExists(t, filepath.Join(dir, "custom-feeds.json"))
+	assert.FileExists(t, filepath.Join(dir, "custom-feeds.json.migrated"))
+}
+
+func TestChanged(t *testing.T) {
+	a, b := Default(), Default()
+	assert.Empty(t, Changed(a, b))
+
+	b.Server.Port = "4000"
+	b.Server.AllowedOrigins = []string{"https://a.example"}
+	b.Tools.Feeds = true
+	assert.Equal(t, []string{"server.port", "server.allowed_origins", "tools.feeds"}, Changed(a, b))
+}
+
+func TestWatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeFile(t, path, "chat:\n  timeout: 1h\n")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	reload := make(chan os.Signal, 1)
+	applied := make(chan *Config, 10)
+	go newLoader(t, path, nil, nil).Watch(ctx, 10*time.Millisecond, reload, func(cfg *Config) {
+		applied <- cfg
+	})
+
+	next := func() *Config {
+		select {
+		case cfg := <-applied:
+			return cfg
+		case <-time.After(5 * time.Second):
+			t.Fatal("configuration was not reloaded")
+			return nil
+		}
+	}
+
+	// A changed file is reloaded
+	time.Sleep(50 * time.Millisecond)
+	writeFile(t, path, "chat:\n  timeout: 2h\n")
+	assert.Equal(t, 2*time.Hour, next().Chat.Timeout)
+
+	// An invalid file is not applied
+	writeFile(t, path, "chat:\n  timeout: -1h\n")
+	time.Sleep(100 * time.Millisecond)
+	select {
+	case cfg := <-applied:
+		t.Fatalf("invalid configuration applied: %+v", cfg.Chat)
+	default:
+	}
+
+	// A signal reloads the file
+	writeFile(t, path, "chat:\n  timeout: 3h\n")
+	reload <- syscall.SIGHUP
+	assert.Equal(t, 3*time.Hour, next().Chat.Timeout)
+}
@@ -0,0 +1,66 @@
+package config
+
+import (
+	"flag"
+	"strings"
+)
+
+// BindFlags defines the command-line flags of the settings on fs, storing
+// their values in cfg
+func BindFlags(fs *flag.FlagSet, cfg *Config) {
+	fs.StringVar(&cfg.Server.Host, "host", cfg.Server.Host, "Server host")
+	fs.StringVar(&cfg.Server.Port, "port", cfg.Server.Port, "Server port")
+	fs.StringVar(&cfg.Server.StaticDir, "static", cfg.Server.StaticDir, "Serve the web UI from this directory instead of the files embedded in the binary, for live editing")
+	fs.Var((*listValue)(&cfg.Server.AllowedOrigins), "allowed-origins", "Comma-separated origins, besides the UI, allowed to call the API from a browser, e.g. https://dashboard.example.com")
+	fs.StringVar(&cfg.Server.RateLimitsFile, "rate-limits", cfg.Server.RateLimitsFile, "JSON file with per-client rate limits for chats, tool use and model loads, and the open stream cap")
+	fs.DurationVar(&cfg.Server.DrainTimeout, "drain-timeout", cfg.Server.DrainTimeout, "How long running chats may finish on shutdown before they are cancelled and their partial replies saved")
+	fs.StringVar(&cfg.Server.TLS.Cert, "tls-cert", cfg.Server.TLS.Cert, "TLS certificate file (PEM); serves HTTPS together with -tls-key")
+	fs.StringVar(&cfg.Server.TLS.Key, "tls-key", cfg.Server.TLS.Key, "TLS private key file (PEM)")
+	fs.BoolVar(&cfg.Server.TLS.SelfSigned, "tls-self-signed", cfg.Server.TLS.SelfSigned, "Serve HTTPS with a self-signed certificate, created on first run in <config>/tls unless -tls-cert and -tls-key name other files")
+	fs.StringVar(&cfg.Server.TLS.HTTPRedirect, "http-redirect", cfg.Server.TLS.HTTPRedirect, "Address to listen on for plain HTTP, redirecting to HTTPS, e.g. :80")
+
+	fs.StringVar(&cfg.Backend.Kind, "backend", cfg.Backend.Kind, "Inference backend: llamacpp, ollama or openai (any OpenAI-compatible server)")
+	fs.StringVar(&cfg.Backend.URL, "ollama", cfg.Backend.URL, "Backend server URL (llama.cpp, or Ollama with -backend ollama, e.g. http://localhost:11434)")
+	fs.StringVar(&cfg.Backend.APIKey, "api-key", cfg.Backend.APIKey, "Bearer token for the backend (default from GOLLAMA_API_KEY)")
+	fs.StringVar(&cfg.Backend.ConfigFile, "backend-config", cfg.Backend.ConfigFile, "JSON file with backend settings (base_url, api_key, headers, tls, paths)")
+	fs.StringVar(&cfg.Backend.BackendsFile, "backends", cfg.Backend.BackendsFile, "JSON file listing named backends; models are routed to the backend that serves them")
+	fs.DurationVar(&cfg.Backend.Wait, "backend-wait", cfg.Backend.Wait, "How long chats wait for a loading or restarting backend")
+
+	fs.StringVar(&cfg.Services.DDGSURL, "ddgs", cfg.Services.DDGSURL, "ddgs search service URL")
+	fs.StringVar(&cfg.Services.SentinelURL, "sentinel", cfg.Services.SentinelURL, "Sentinel portfolio API URL")
+	fs.DurationVar(&cfg.Services.HealthInterval, "health-interval", cfg.Services.HealthInterval, "How often the health of backends and of the ddgs and Sentinel services is checked")
+
+	fs.DurationVar(&cfg.Chat.Timeout, "chat-timeout", cfg.Chat.Timeout, "Chat request timeout (e.g., 1h, 24h, 48h) - default 24h for slow hardware like RPi")
+	fs.IntVar(&cfg.Chat.Slots, "slots", cfg.Chat.Slots, "Number of chats generated concurrently; further chats wait in a queue")
+	fs.DurationVar(&cfg.Chat.ResumeGrace, "resume-grace", cfg.Chat.ResumeGrace, "How long a chat keeps generating after its client disconnects, waiting for it to resume")
+	fs.StringVar(&cfg.Chat.NotifyURL, "notify-url", cfg.Chat.NotifyURL, "URL called when a detached chat finishes, e.g. an ntfy topic")
+	fs.StringVar(&cfg.Chat.NotifyFormat, "notify-format", cfg.Chat.NotifyFormat, "Notification body: json (the job) or ntfy (plain text with a Title header)")
+	fs.Var((*listValue)(&cfg.Chat.NotifyHosts), "notify-hosts", "Comma-separated hosts, e.g. ntfy.sh, that requests may send their own notify_url to; otherwise only -notify-url is allowed")
+	fs.StringVar(&cfg.Chat.ContextStrategy, "context-strategy", cfg.Chat.ContextStrategy, "How to fit long conversations into the context window (none, truncate, summarize)")
+	fs.IntVar(&cfg.Chat.ContextKeepTurns, "context-keep-turns", cfg.Chat.ContextKeepTurns, "Number of most recent turns never dropped from the history")
+	fs.IntVar(&cfg.Chat.ContextReserve, "context-reserve", cfg.Chat.ContextReserve, "Tokens of the context window reserved for the response")
+
+	fs.StringVar(&cfg.Models.Dir, "models-dir", cfg.Models.Dir, "Directory of the models the model manager can load")
+	fs.StringVar(&cfg.Models.ServerConfig, "llama-server-config", cfg.Models.ServerConfig, "llama-server configuration file the model manager rewrites to switch models")
+
+	fs.StringVar(&cfg.Auth.ConfigFile, "auth-config", cfg.Auth.ConfigFile, "JSON file with API tokens, users and proxy settings; authentication is disabled without it")
+
+	fs.StringVar(&cfg.Logging.Level, "log-level", cfg.Logging.Level, "Log level: debug, info, warn or error")
+	fs.StringVar(&cfg.Logging.Format, "log-format", cfg.Logging.Format, "Log format: text or json")
+	fs.BoolVar(&cfg.Logging.Content, "log-content", cfg.Logging.Content, "Log message content, tool arguments and request bodies instead of redacting them")
+}
+
+// listValue is a flag holding a comma-separated list
+type listValue []string
+
+func (l *listValue) String() string {
+	if l == nil {
+		return ""
+	}
+	return strings.Join(*l, ",")
+}
+
+func (l *listValue) Set(value string) error {
+	*l = splitList(value)
+	return nil
+}
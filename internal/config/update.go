@@ -0,0 +1,170 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// updateMu serializes writes to config files
+var updateMu sync.Mutex
+
+// Update sets settings in the config file at path, keyed by their dotted
+// names such as tools.web_search. Comments and other settings in the file are
+// kept; the file is created when it doesn't exist.
+func Update(path string, values map[string]interface{}) error {
+	updateMu.Lock()
+	defer updateMu.Unlock()
+
+	var doc yaml.Node
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read config: %w", err)
+	}
+	if len(bytes.TrimSpace(data)) > 0 {
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return fmt.Errorf("failed to parse config: %w", err)
+		}
+	}
+	if doc.Kind == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return fmt.Errorf("failed to update config: %s is not a mapping", path)
+	}
+
+	for name, value := range values {
+		var node yaml.Node
+		if err := node.Encode(value); err != nil {
+			return fmt.Errorf("failed to encode %s: %w", name, err)
+		}
+		if err := setNode(root, strings.Split(name, "."), &node); err != nil {
+			return fmt.Errorf("failed to set %s: %w", name, err)
+		}
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return fmt.Errorf("failed to encode config: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return fmt.Errorf("failed to encode config: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	// Write a temporary file and rename it, so a reload never reads half a file
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	return nil
+}
+
+// setNode sets the value at keys in the mapping m, adding missing mappings
+func setNode(m *yaml.Node, keys []string, value *yaml.Node) error {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value != keys[0] {
+			continue
+		}
+		if len(keys) == 1 {
+			// Keep the comments of the replaced value
+			value.LineComment = m.Content[i+1].LineComment
+			m.Content[i+1] = value
+			return nil
+		}
+		if m.Content[i+1].Kind != yaml.MappingNode {
+			return fmt.Errorf("%s is not a mapping", keys[0])
+		}
+		return setNode(m.Content[i+1], keys[1:], value)
+	}
+
+	key := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: keys[0]}
+	if len(keys) == 1 {
+		m.Content = append(m.Content, key, value)
+		return nil
+	}
+	child := &yaml.Node{Kind: yaml.MappingNode}
+	m.Content = append(m.Content, key, child)
+	return setNode(child, keys[1:], value)
+}
+
+// Migrate imports the tool settings and custom feeds files of earlier
+// versions from configDir into the config file at path
+func Migrate(path, configDir string) error {
+	toolsPath := filepath.Join(configDir, "tool-settings.json")
+	var tools struct {
+		EnableWebSearch bool `json:"enable_web_search"`
+		EnableFeeds     bool `json:"enable_feeds"`
+		EnableSentinel  bool `json:"enable_sentinel"`
+	}
+	ok, err := readJSON(toolsPath, &tools)
+	if err != nil {
+		return err
+	}
+	if ok {
+		err := MigrateFile(path, toolsPath, map[string]interface{}{
+			"tools.web_search": tools.EnableWebSearch,
+			"tools.feeds":      tools.EnableFeeds,
+			"tools.sentinel":   tools.EnableSentinel,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	feedsPath := filepath.Join(configDir, "custom-feeds.json")
+	var feeds map[string]string
+	ok, err = readJSON(feedsPath, &feeds)
+	if err != nil || !ok {
+		return err
+	}
+
+	return MigrateFile(path, feedsPath, map[string]interface{}{
+		"tools.custom_feeds": feeds,
+	})
+}
+
+// MigrateFile sets values read from the settings file legacyPath in the
+// config file at path, then renames legacyPath with a .migrated suffix so it
+// is imported only once
+func MigrateFile(path, legacyPath string, values map[string]interface{}) error {
+	if err := Update(path, values); err != nil {
+		return err
+	}
+	if err := os.Rename(legacyPath, legacyPath+".migrated"); err != nil {
+		return fmt.Errorf("failed to rename migrated settings: %w", err)
+	}
+	slog.Info("Migrated settings into the config file", "from", legacyPath, "config", path)
+	return nil
+}
+
+// readJSON reads the JSON file at path into v. It reports false when the file
+// doesn't exist.
+func readJSON(path string, v interface{}) (bool, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return false, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return true, nil
+}
@@ -0,0 +1,57 @@
+package config
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"time"
+)
+
+// Watch reloads the configuration when the config file changes, checking
+// every interval, or when a signal arrives on reload, such as SIGHUP. Each
+// valid configuration is passed to apply; an invalid one is logged and the
+// current configuration kept.
+func (l *Loader) Watch(ctx context.Context, interval time.Duration, reload <-chan os.Signal, apply func(*Config)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	last := l.stat()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-reload:
+			last = l.stat()
+			slog.Info("Reloading configuration", "path", l.path, "trigger", "signal")
+		case <-ticker.C:
+			current := l.stat()
+			if current == last {
+				continue
+			}
+			last = current
+			slog.Info("Reloading configuration", "path", l.path, "trigger", "file change")
+		}
+
+		cfg, err := l.Load()
+		if err != nil {
+			slog.Error("Invalid configuration, keeping the current one", "path", l.path, "error", err)
+			continue
+		}
+		apply(cfg)
+	}
+}
+
+// fileState identifies a version of the config file
+type fileState struct {
+	exists  bool
+	size    int64
+	modTime time.Time
+}
+
+func (l *Loader) stat() fileState {
+	info, err := os.Stat(l.path)
+	if err != nil {
+		return fileState{}
+	}
+	return fileState{exists: true, size: info.Size(), modTime: info.ModTime()}
+}
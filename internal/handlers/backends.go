@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/aristath/gollama-ui/internal/client"
+)
+
+// statusKeepAlive is how often an idle status stream sends a comment so
+// proxies keep the connection open
+const statusKeepAlive = 30 * time.Second
+
+// BackendsHandler reports the health of the configured backends
+type BackendsHandler struct {
+	router BackendHealthInterface
+}
+
+// BackendHealthInterface defines the interface for backend health checks
+type BackendHealthInterface interface {
+	Health(ctx context.Context) []client.BackendHealth
+	Statuses() []client.BackendStatus
+	Subscribe() (<-chan client.BackendStatus, func())
+}
+
+// NewBackendsHandler creates a new backends handler
+func NewBackendsHandler(router BackendHealthInterface) *BackendsHandler {
+	return &BackendsHandler{
+		router: router,
+	}
+}
+
+// Status handles GET /api/backends
+func (h *BackendsHandler) Status(w http.ResponseWriter, r *http.Request) {
+	backends := h.router.Health(r.Context())
+
+	healthy := 0
+	for _, b := range backends {
+		if b.Status == "ok" {
+			healthy++
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"backends": backends,
+		"healthy":  healthy,
+		"total":    len(backends),
+	}); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to encode response: %v", err), http.StatusInternalServerError)
+		return
+	}
+}
+
+// Events handles GET /api/backends/events, streaming backend state
+// transitions as Server-Sent Events. The current state of every backend is
+// sent first.
+func (h *BackendsHandler) Events(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	updates, unsubscribe := h.router.Subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	if r.ProtoMajor == 1 {
+		w.Header().Set("Connection", "keep-alive") // HTTP/2 forbids connection headers
+	}
+	w.Header().Set("X-Accel-Buffering", "no") // Disable nginx buffering
+
+	for _, status := range h.router.Statuses() {
+		writeEvent(w, flusher, "status", status)
+	}
+
+	keepAlive := time.NewTicker(statusKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case status := <-updates:
+			writeEvent(w, flusher, "status", status)
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+		}
+	}
+}
@@ -3,18 +3,51 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
+	"math"
 	"net/http"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/go-chi/chi/v5"
+
 	"github.com/aristath/gollama-ui/internal/client"
+	"github.com/aristath/gollama-ui/internal/ratelimit"
+	"github.com/aristath/gollama-ui/internal/schema"
 )
 
+// toolClientKey holds the rate limit key of the client a chat's tool calls
+// are charged to
+type toolClientKey struct{}
+
 // ChatHandler handles chat-related requests
 type ChatHandler struct {
 	ollamaClient  ChatClientInterface
 	toolExecutor  *ToolExecutor
-	chatTimeout   time.Duration
+	chatTimeout   time.Duration // Guarded by mu, as it changes on config reload
+	contextWindow *ContextWindow
+	presets       *ModelPresets
+	personas      *PersonaStore
+	stats         *StatsStore
+	userStats     *UserStats
+	metrics       *Metrics
+	toolLimiter   *ratelimit.Limiter
+
+	resumeGrace time.Duration
+
+	jobs          *JobStore
+	conversations *ConversationStore
+	notifyURL     string
+	notifyFormat  string
+	notifyHosts   map[string]bool // Hosts requests may send their own notify_url to
+
+	mu           sync.Mutex
+	generations  map[string]*generation // Running and recently finished chats by ID
+	shuttingDown bool                   // Set by Shutdown; no new chats are started
+	running      sync.WaitGroup         // Generations that haven't saved their result yet
 }
 
 // ChatClientInterface defines the interface for chat operations
@@ -22,6 +55,33 @@ type ChatClientInterface interface {
 	ChatStream(ctx context.Context, req client.ChatRequest) (<-chan client.ChatResponse, error)
 }
 
+// BackendStateInterface is implemented by clients that track whether the
+// backend serving a model is ready
+type BackendStateInterface interface {
+	ModelState(model string) string
+}
+
+// ChatStreamRequest is the body of POST /api/chat
+type ChatStreamRequest struct {
+	client.ChatRequest
+	ConversationID string `json:"conversation_id,omitempty"`
+	PersonaID      string `json:"persona_id,omitempty"`
+
+	// ValidationRetry asks for one automatic retry when structured output fails validation
+	ValidationRetry bool `json:"validation_retry,omitempty"`
+
+	// Detach runs the generation as a background job and returns its ID at once
+	Detach    bool   `json:"detach,omitempty"`
+	NotifyURL string `json:"notify_url,omitempty"` // Called when a detached generation finishes
+}
+
+// ValidationResult reports whether structured output matched the requested format
+type ValidationResult struct {
+	Valid   bool     `json:"valid"`
+	Errors  []string `json:"errors,omitempty"`
+	Attempt int      `json:"attempt"`
+}
+
 // NewChatHandler creates a new chat handler
 func NewChatHandler(client ChatClientInterface, toolExecutor *ToolExecutor) *ChatHandler {
 	return NewChatHandlerWithTimeout(client, toolExecutor, 24*time.Hour)
@@ -33,61 +93,300 @@ func NewChatHandlerWithTimeout(client ChatClientInterface, toolExecutor *ToolExe
 		ollamaClient: client,
 		toolExecutor: toolExecutor,
 		chatTimeout:  timeout,
+		stats:        NewStatsStore(),
+		userStats:    NewUserStats(),
+		resumeGrace:  defaultResumeGrace,
+		jobs:         NewJobStore(),
+		notifyFormat: NotifyFormatJSON,
+		generations:  make(map[string]*generation),
 	}
 }
 
+// SetChatTimeout changes how long new chats may run
+func (h *ChatHandler) SetChatTimeout(timeout time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.chatTimeout = timeout
+}
+
+// ChatTimeout returns how long new chats may run
+func (h *ChatHandler) ChatTimeout() time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.chatTimeout
+}
+
+// SetContextWindow sets the context window manager used to trim history
+func (h *ChatHandler) SetContextWindow(cw *ContextWindow) {
+	h.contextWindow = cw
+}
+
+// SetModelPresets sets the per-model default generation options
+func (h *ChatHandler) SetModelPresets(presets *ModelPresets) {
+	h.presets = presets
+}
+
+// SetMetrics sets the instruments chats are recorded to
+func (h *ChatHandler) SetMetrics(m *Metrics) {
+	h.metrics = m
+}
+
+// SetToolLimiter sets the rate limit of tool calls, which also load the
+// services behind them. Each call the model makes is charged to the client
+// of the chat.
+func (h *ChatHandler) SetToolLimiter(l *ratelimit.Limiter) {
+	h.toolLimiter = l
+}
+
+// SetPersonaStore sets the personas library used to resolve persona_id
+func (h *ChatHandler) SetPersonaStore(personas *PersonaStore) {
+	h.personas = personas
+}
+
 // Stream handles POST /api/chat with streaming support and function calling
 func (h *ChatHandler) Stream(w http.ResponseWriter, r *http.Request) {
-	var req client.ChatRequest
+	var req ChatStreamRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
 		return
 	}
 
+	if len(req.Messages) == 0 {
+		http.Error(w, "messages array is required", http.StatusBadRequest)
+		return
+	}
+
+	// Apply the persona first so it can pick the model
+	var toolNames []string
+	if req.PersonaID != "" {
+		if h.personas == nil {
+			http.Error(w, "personas are not available", http.StatusBadRequest)
+			return
+		}
+		persona, ok := h.personas.ForContext(r.Context()).Get(req.PersonaID)
+		if !ok {
+			http.Error(w, fmt.Sprintf("unknown persona: %s", req.PersonaID), http.StatusBadRequest)
+			return
+		}
+		applyPersona(&req.ChatRequest, persona, time.Now())
+		toolNames = persona.Tools
+	}
+
 	if req.Model == "" {
 		http.Error(w, "model is required", http.StatusBadRequest)
 		return
 	}
 
-	if len(req.Messages) == 0 {
-		http.Error(w, "messages array is required", http.StatusBadRequest)
+	if req.ConversationID != "" && !ValidConversationID(req.ConversationID) {
+		http.Error(w, "invalid conversation_id", http.StatusBadRequest)
 		return
 	}
 
-	// Create context with timeout
-	ctx, cancel := context.WithTimeout(r.Context(), h.chatTimeout)
-	defer cancel()
+	// Layer the request's generation options over the model's preset
+	if h.presets != nil {
+		req.Options = h.presets.Resolve(req.Model, req.Options)
+	}
+	if req.Options != nil {
+		if err := req.Options.Validate(); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid options: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if req.NotifyURL != "" {
+		if !req.Detach {
+			http.Error(w, "notify_url requires detach", http.StatusBadRequest)
+			return
+		}
+		if err := h.checkNotifyURL(req.NotifyURL); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if req.ResponseFormat != nil {
+		if req.Grammar != "" {
+			http.Error(w, "response_format and grammar cannot be combined", http.StatusBadRequest)
+			return
+		}
+		if err := req.ResponseFormat.Validate(); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid response_format: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Add tool definitions to request
+	if h.toolExecutor != nil {
+		user := userFromContext(r.Context())
+		if toolNames != nil {
+			req.Tools = h.toolExecutor.GetAvailableToolsByName(user, toolNames)
+		} else {
+			req.Tools = h.toolExecutor.GetAvailableToolsForUser(user)
+		}
+	}
+
+	// The generation outlives the request so a client that loses its
+	// connection can resume the stream. Its tool calls are charged to the
+	// client of the request.
+	ctx := context.WithValue(context.WithoutCancel(r.Context()), toolClientKey{}, ratelimit.ClientKey(r))
+	g, ctx, err := h.startGeneration(ctx)
+	if errors.Is(err, errShuttingDown) {
+		w.Header().Set("Retry-After", "30")
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
-	// Set up Server-Sent Events
-	w.Header().Set("Content-Type", "text/event-stream")
-	w.Header().Set("Cache-Control", "no-cache")
-	w.Header().Set("Connection", "keep-alive")
-	w.Header().Set("X-Accel-Buffering", "no") // Disable nginx buffering
+	// The tool loop and context fitting rewrite the messages, so keep the
+	// history as the client sent it
+	history := append([]client.ChatMessage(nil), req.Messages...)
 
-	flusher, ok := w.(http.Flusher)
-	if !ok {
-		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+	if req.Detach {
+		job := h.startJob(ctx, g, &req, history)
+		writeJSON(w, http.StatusAccepted, job)
 		return
 	}
 
+	go func() {
+		defer h.running.Done()
+
+		_, completed := h.generate(ctx, g, &req)
+		h.endGeneration(g)
+
+		// The client keeps the history of interactive chats, unless the
+		// server shuts down before the reply is complete
+		if !completed && req.ConversationID != "" && h.isShuttingDown() {
+			if content := g.partialContent(); content != "" {
+				h.saveReply(ctx, g, req.ConversationID, history, content)
+			}
+		}
+	}()
+
+	h.follow(w, r, g, 0)
+}
+
+// generate runs a chat, writing its Server-Sent Events to g. It returns the
+// final assistant content and whether the generation completed.
+func (h *ChatHandler) generate(ctx context.Context, g *generation, req *ChatStreamRequest) (string, bool) {
+	writeEvent(g, g, "start", map[string]string{"id": g.id})
+
+	h.metrics.streamStarted()
+	defer h.metrics.streamEnded()
+
+	// Report the queue position while waiting for a generation slot
+	ctx = WithQueueListener(ctx, func(position int) {
+		writeEvent(g, g, "queue", map[string]int{"position": position})
+	})
+
+	// Let the client know when the request will wait for or fail over from a
+	// backend that is still loading a model or restarting
+	if bs, ok := h.ollamaClient.(BackendStateInterface); ok {
+		if state := bs.ModelState(req.Model); state == client.StateLoading || state == client.StateDown {
+			writeEvent(g, g, "backend", map[string]string{"state": state})
+		}
+	}
+
 	// Function calling loop - may need multiple rounds if tool calls are made
-	h.streamWithFunctionCalling(ctx, w, flusher, &req)
+	content, completed := h.streamWithFunctionCalling(ctx, g, g, &req.ChatRequest, req.ConversationID)
+
+	if completed && req.ResponseFormat != nil {
+		h.validateStructuredOutput(ctx, g, g, req, content)
+	}
+
+	return content, completed
 }
 
-// streamWithFunctionCalling handles the function calling loop
-func (h *ChatHandler) streamWithFunctionCalling(ctx context.Context, w http.ResponseWriter, flusher http.Flusher, req *client.ChatRequest) {
-	// Add tool definitions to request
-	if h.toolExecutor != nil {
-		req.Tools = h.toolExecutor.GetAvailableTools()
+// Cancel handles POST /api/chat/{id}/cancel, stopping a running generation
+// for clients that cannot abort the request
+func (h *ChatHandler) Cancel(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	g, ok := h.getGeneration(r.Context(), id)
+	if !ok || g.finished() {
+		http.Error(w, fmt.Sprintf("no running chat with id %s", id), http.StatusNotFound)
+		return
+	}
+
+	g.cancel()
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"id":      id,
+	})
+}
+
+// applyPersona prepends the persona's system prompt and fills in its defaults.
+// Options sent with the request take precedence over the persona's.
+func applyPersona(req *client.ChatRequest, persona Persona, now time.Time) {
+	if req.Model == "" {
+		req.Model = persona.Model
+	}
+
+	if persona.Options != nil {
+		merged := persona.Options.Merge(req.Options)
+		req.Options = &merged
+	}
+
+	if persona.SystemPrompt != "" {
+		system := client.ChatMessage{
+			Role:    "system",
+			Content: ExpandTemplate(persona.SystemPrompt, req.Model, now),
+		}
+		req.Messages = append([]client.ChatMessage{system}, req.Messages...)
+	}
+}
+
+// validateStructuredOutput checks the assembled content against the requested
+// response format, emits a validation event, and retries once if asked to
+func (h *ChatHandler) validateStructuredOutput(ctx context.Context, w http.ResponseWriter, flusher http.Flusher, req *ChatStreamRequest, content string) {
+	for attempt := 1; ; attempt++ {
+		errs := schema.ValidateJSON(req.ResponseFormat.Schema(), content)
+		writeEvent(w, flusher, "validation", ValidationResult{
+			Valid:   len(errs) == 0,
+			Errors:  errs,
+			Attempt: attempt,
+		})
+
+		if len(errs) == 0 || !req.ValidationRetry || attempt > 1 {
+			return
+		}
+
+		// Show the model its invalid reply together with what was wrong
+		req.Messages = append(req.Messages,
+			client.ChatMessage{Role: "assistant", Content: content},
+			client.ChatMessage{Role: "user", Content: fmt.Sprintf(
+				"Your reply did not match the required JSON format:\n- %s\nReply again with only the corrected JSON.",
+				strings.Join(errs, "\n- "))},
+		)
+		writeEvent(w, flusher, "retry", map[string]interface{}{
+			"reason":  "validation_failed",
+			"attempt": attempt + 1,
+		})
+
+		var completed bool
+		content, completed = h.streamWithFunctionCalling(ctx, w, flusher, &req.ChatRequest, "")
+		if !completed {
+			return
+		}
 	}
+}
+
+// streamWithFunctionCalling handles the function calling loop. It returns the
+// final assistant content and whether the generation completed.
+func (h *ChatHandler) streamWithFunctionCalling(ctx context.Context, w http.ResponseWriter, flusher http.Flusher, req *client.ChatRequest, conversationID string) (string, bool) {
+	// Make sure the history fits into the model's context window
+	h.fitContext(ctx, w, flusher, req, conversationID)
 
 	// Start streaming from llama.cpp
 	stream, err := h.ollamaClient.ChatStream(ctx, *req)
 	if err != nil {
 		fmt.Fprintf(w, "data: %s\n\n", `{"done": true, "error": "Failed to start chat"}`)
 		flusher.Flush()
-		return
+		return "", false
 	}
+	timer := h.metrics.startResponse(req.Model)
 
 	// Collect response data
 	var assistantContent string
@@ -100,9 +399,15 @@ func (h *ChatHandler) streamWithFunctionCalling(ctx context.Context, w http.Resp
 		case <-ctx.Done():
 			fmt.Fprintf(w, "data: %s\n\n", `{"done": true, "error": "context cancelled"}`)
 			flusher.Flush()
-			return
+			return "", false
 
 		case response, ok := <-stream:
+			if !ok && ctx.Err() != nil {
+				// The stream was closed because the chat was cancelled
+				fmt.Fprintf(w, "data: %s\n\n", `{"done": true, "error": "context cancelled"}`)
+				flusher.Flush()
+				return "", false
+			}
 			if !ok {
 				// Stream closed, check if we need to handle tool calls
 				if len(toolCallsMap) > 0 {
@@ -116,12 +421,13 @@ func (h *ChatHandler) streamWithFunctionCalling(ctx context.Context, w http.Resp
 					}
 					if len(toolCalls) > 0 {
 						// Execute tool calls and loop back
-						h.executeAndContinue(ctx, w, flusher, req, assistantContent, toolCalls)
-						return
+						return h.executeAndContinue(ctx, w, flusher, req, assistantContent, toolCalls)
 					}
 				}
 				// No tool calls, we're done
-				return
+				fmt.Fprintf(w, "data: %s\n\n", `{"done": true}`)
+				flusher.Flush()
+				return assistantContent, true
 			}
 
 			// Collect tool calls - merge partial updates from streaming
@@ -160,26 +466,32 @@ func (h *ChatHandler) streamWithFunctionCalling(ctx context.Context, w http.Resp
 			if response.Message.Content != "" {
 				assistantContent += response.Message.Content
 			}
+			if response.Message.Content != "" || len(response.Message.ToolCalls) > 0 {
+				timer.token()
+			}
 
 			// Collect finish reason
 			if response.DoneReason != "" {
 				finishReason = response.DoneReason
 			}
 
-			// Forward content chunks to frontend
-			if response.Message.Content != "" || len(response.Message.ToolCalls) > 0 {
-				data, err := json.Marshal(response)
-				if err != nil {
-					fmt.Fprintf(w, "data: %s\n\n", `{"done": true, "error": "failed to marshal response"}`)
-					flusher.Flush()
-					return
+			// Forward content chunks and errors to frontend
+			forwarded := false
+			if response.Message.Content != "" || len(response.Message.ToolCalls) > 0 || response.Error != "" {
+				if !writeResponse(w, flusher, response) {
+					return "", false
 				}
-				fmt.Fprintf(w, "data: %s\n\n", string(data))
-				flusher.Flush()
+				forwarded = true
 			}
 
 			// Check if stream is done
 			if response.Done {
+				if response.Error != "" {
+					return "", false
+				}
+				h.recordStats(ctx, req.Model, response.Usage, response.Timings)
+				timer.done(response)
+
 				// If we have tool calls, execute them and continue
 				if len(toolCallsMap) > 0 && finishReason == "tool_calls" {
 					// Convert map back to slice, filtering out incomplete tool calls
@@ -190,24 +502,24 @@ func (h *ChatHandler) streamWithFunctionCalling(ctx context.Context, w http.Resp
 						}
 					}
 					if len(toolCalls) > 0 {
-						// Debug logging
-						for _, tc := range toolCalls {
-							fmt.Printf("  Tool: %s, Args: %s\n", tc.Function.Name, tc.Function.Arguments)
-						}
-						h.executeAndContinue(ctx, w, flusher, req, assistantContent, toolCalls)
-						return
+						return h.executeAndContinue(ctx, w, flusher, req, assistantContent, toolCalls)
 					}
 				}
-				// No tool calls, we're truly done
-				return
+				// No tool calls, we're truly done. Send the done response,
+				// which carries the usage and timings, if it had no content.
+				if !forwarded && !writeResponse(w, flusher, response) {
+					return "", false
+				}
+				return assistantContent, true
 			}
 		}
 	}
 }
 
-// executeAndContinue executes tool calls and gets final response
+// executeAndContinue executes tool calls and gets final response. It returns the
+// final assistant content and whether the generation completed.
 func (h *ChatHandler) executeAndContinue(ctx context.Context, w http.ResponseWriter, flusher http.Flusher,
-	req *client.ChatRequest, assistantContent string, toolCalls []client.ToolCall) {
+	req *client.ChatRequest, assistantContent string, toolCalls []client.ToolCall) (string, bool) {
 
 	// Add assistant message with tool calls to history
 	req.Messages = append(req.Messages, client.ChatMessage{
@@ -217,7 +529,16 @@ func (h *ChatHandler) executeAndContinue(ctx context.Context, w http.ResponseWri
 	})
 
 	// Execute each tool call and add results
+	clientKey, _ := ctx.Value(toolClientKey{}).(string)
 	for _, toolCall := range toolCalls {
+		if ok, retryAfter := h.toolLimiter.Allow(clientKey); !ok {
+			seconds := int(math.Ceil(retryAfter.Seconds()))
+			slog.WarnContext(ctx, "Tool call rate limited", "client", clientKey, "tool", toolCall.Function.Name, "retry_after", seconds)
+			fmt.Fprintf(w, "data: %s\n\n", fmt.Sprintf(`{"done": true, "error": "tool rate limit exceeded, retry in %d seconds"}`, max(seconds, 1)))
+			flusher.Flush()
+			return "", false
+		}
+
 		result, err := h.toolExecutor.ExecuteToolCall(ctx, toolCall.Function.Name, toolCall.Function.Arguments)
 		if err != nil {
 			result = fmt.Sprintf("Error executing tool %s: %v", toolCall.Function.Name, err)
@@ -232,40 +553,101 @@ func (h *ChatHandler) executeAndContinue(ctx context.Context, w http.ResponseWri
 		})
 	}
 
+	// Tool results may have pushed the history past the context window.
+	// The history no longer matches the client's, so summaries aren't cached.
+	h.fitContext(ctx, w, flusher, req, "")
+
 	// Get final response from llama.cpp with tool results
 	stream, err := h.ollamaClient.ChatStream(ctx, *req)
 	if err != nil {
 		fmt.Fprintf(w, "data: %s\n\n", fmt.Sprintf(`{"done": true, "error": "Failed to get final response: %v"}`, err))
 		flusher.Flush()
-		return
+		return "", false
 	}
+	timer := h.metrics.startResponse(req.Model)
 
 	// Stream final response
+	var finalContent string
 	for {
 		select {
 		case <-ctx.Done():
 			fmt.Fprintf(w, "data: %s\n\n", `{"done": true, "error": "context cancelled"}`)
 			flusher.Flush()
-			return
+			return "", false
 
 		case response, ok := <-stream:
+			if !ok && ctx.Err() != nil {
+				fmt.Fprintf(w, "data: %s\n\n", `{"done": true, "error": "context cancelled"}`)
+				flusher.Flush()
+				return "", false
+			}
 			if !ok {
-				return
+				// The backend ended the stream without a final response
+				fmt.Fprintf(w, "data: %s\n\n", `{"done": true, "error": "stream ended before the response was complete"}`)
+				flusher.Flush()
+				return "", false
 			}
 
-			data, err := json.Marshal(response)
-			if err != nil {
-				fmt.Fprintf(w, "data: %s\n\n", `{"done": true, "error": "failed to marshal response"}`)
-				flusher.Flush()
-				return
+			finalContent += response.Message.Content
+			if response.Message.Content != "" {
+				timer.token()
 			}
 
-			fmt.Fprintf(w, "data: %s\n\n", string(data))
-			flusher.Flush()
+			if !writeResponse(w, flusher, response) {
+				return "", false
+			}
 
 			if response.Done {
-				return
+				if response.Error != "" {
+					return "", false
+				}
+				h.recordStats(ctx, req.Model, response.Usage, response.Timings)
+				timer.done(response)
+				return finalContent, true
 			}
 		}
 	}
 }
+
+// fitContext trims req.Messages to the context window and reports what was dropped
+func (h *ChatHandler) fitContext(ctx context.Context, w http.ResponseWriter, flusher http.Flusher, req *client.ChatRequest, conversationID string) {
+	if h.contextWindow == nil {
+		return
+	}
+
+	messages, report, err := h.contextWindow.Fit(ctx, req, conversationID)
+	if err != nil {
+		slog.WarnContext(ctx, "Context window check skipped", "error", err)
+		return
+	}
+
+	req.Messages = messages
+	if report != nil {
+		writeEvent(w, flusher, "metadata", map[string]interface{}{
+			"context": report,
+		})
+	}
+}
+
+// writeResponse forwards a response chunk as an unnamed Server-Sent Event
+func writeResponse(w http.ResponseWriter, flusher http.Flusher, response client.ChatResponse) bool {
+	data, err := json.Marshal(response)
+	if err != nil {
+		fmt.Fprintf(w, "data: %s\n\n", `{"done": true, "error": "failed to marshal response"}`)
+		flusher.Flush()
+		return false
+	}
+	fmt.Fprintf(w, "data: %s\n\n", string(data))
+	flusher.Flush()
+	return true
+}
+
+// writeEvent writes a named Server-Sent Event with a JSON payload
+func writeEvent(w http.ResponseWriter, flusher http.Flusher, event string, payload interface{}) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, string(data))
+	flusher.Flush()
+}
@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aristath/gollama-ui/internal/client"
+	"github.com/aristath/gollama-ui/internal/ratelimit"
+)
+
+// endlessChatClient streams content until the request is cancelled
+type endlessChatClient struct{}
+
+func (f *endlessChatClient) ChatStream(ctx context.Context, req client.ChatRequest) (<-chan client.ChatResponse, error) {
+	ch := make(chan client.ChatResponse)
+	go func() {
+		defer close(ch)
+		for {
+			select {
+			case ch <- client.ChatResponse{Model: req.Model, Message: client.ChatMessage{Content: "x"}}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// activeChatID waits for a running generation and returns its ID
+func activeChatID(t *testing.T, h *ChatHandler) string {
+	t.Helper()
+	var id string
+	assert.Eventually(t, func() bool {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		for k := range h.generations {
+			id = k
+		}
+		return id != ""
+	}, time.Second, time.Millisecond)
+	return id
+}
+
+func cancelRequest(id string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/api/chat/"+id+"/cancel", nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", id)
+	return req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+}
+
+func TestChatHandler_Cancel(t *testing.T) {
+	baseline := runtime.NumGoroutine()
+	h := NewChatHandler(&endlessChatClient{}, nil)
+
+	rec := httptest.NewRecorder()
+	body := strings.NewReader(`{"model":"m","messages":[{"role":"user","content":"hi"}]}`)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		h.Stream(rec, httptest.NewRequest(http.MethodPost, "/api/chat", body))
+	}()
+
+	id := activeChatID(t, h)
+
+	cancelRec := httptest.NewRecorder()
+	h.Cancel(cancelRec, cancelRequest(id))
+	assert.Equal(t, http.StatusOK, cancelRec.Code)
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("stream did not stop after cancel")
+	}
+
+	assert.Contains(t, rec.Body.String(), `"id":"`+id+`"`)
+	assert.Contains(t, rec.Body.String(), "context cancelled")
+	assert.Equal(t, id, rec.Header().Get("X-Chat-ID"))
+
+	// The generation is gone once the stream has ended
+	cancelRec = httptest.NewRecorder()
+	h.Cancel(cancelRec, cancelRequest(id))
+	assert.Equal(t, http.StatusNotFound, cancelRec.Code)
+
+	assertNoGoroutineLeak(t, baseline)
+}
+
+func TestChatHandler_ClientDisconnect(t *testing.T) {
+	baseline := runtime.NumGoroutine()
+	s, _ := NewScheduler(&endlessChatClient{}, 1)
+	h := NewChatHandler(s, nil)
+	h.SetResumeGrace(10 * time.Millisecond)
+
+	ctx, disconnect := context.WithCancel(context.Background())
+	body := strings.NewReader(`{"model":"m","messages":[{"role":"user","content":"hi"}]}`)
+	req := httptest.NewRequest(http.MethodPost, "/api/chat", body).WithContext(ctx)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		h.Stream(httptest.NewRecorder(), req)
+	}()
+
+	activeChatID(t, h)
+	disconnect()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("stream did not stop after the client disconnected")
+	}
+
+	assertNoGoroutineLeak(t, baseline)
+}
+
+// assertNoGoroutineLeak waits for the goroutine count to return to baseline
+func assertNoGoroutineLeak(t *testing.T, baseline int) {
+	t.Helper()
+	// Polled by hand: assert.Eventually runs its condition in a goroutine
+	deadline := time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > baseline {
+		if time.Now().After(deadline) {
+			t.Fatalf("goroutines leaked: %d running, %d before", runtime.NumGoroutine(), baseline)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestChatHandler_ToolLimiter(t *testing.T) {
+	limiter := ratelimit.NewLimiter(ratelimit.Limit{PerMinute: 1, Burst: 1})
+	settings := NewToolSettings("")
+	settings.EnableWebSearch = true
+
+	detach := func(h *ChatHandler) Job {
+		rec := httptest.NewRecorder()
+		body := strings.NewReader(`{"model":"m","detach":true,"messages":[{"role":"user","content":"hi"}]}`)
+		h.Stream(rec, httptest.NewRequest(http.MethodPost, "/api/chat", body))
+		assert.Equal(t, http.StatusAccepted, rec.Code)
+
+		var started Job
+		json.NewDecoder(rec.Body).Decode(&started)
+		return waitForJob(t, h, started.ID)
+	}
+
+	// Chats that are offered tools but don't call them aren't charged
+	fake := &gatedChatClient{gate: make(chan struct{})}
+	close(fake.gate)
+	h := NewChatHandler(fake, NewToolExecutor(nil, nil, nil, settings))
+	h.SetToolLimiter(limiter)
+	assert.Equal(t, JobCompleted, detach(h).Status)
+	assert.Equal(t, JobCompleted, detach(h).Status)
+
+	// Every tool call is charged, and the chat fails once over the limit
+	final := []client.ChatResponse{{Message: client.ChatMessage{Content: "done"}, Done: true}}
+	h = NewChatHandler(&toolThenChatClient{final: final}, NewToolExecutor(nil, nil, nil, settings))
+	h.SetToolLimiter(limiter)
+	assert.Equal(t, JobCompleted, detach(h).Status)
+
+	job := detach(h)
+	assert.Equal(t, JobFailed, job.Status)
+	assert.Equal(t, "tool rate limit exceeded, retry in 60 seconds", job.Error)
+}
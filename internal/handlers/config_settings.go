@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// ChatTimeoutHandler serves the chat timeout setting. Changes apply to new
+// chats at once and are saved with a saver, such as into the config file.
+type ChatTimeoutHandler struct {
+	chat *ChatHandler
+	save func(timeout time.Duration) error
+}
+
+// ChatTimeoutRequest is the body of POST /api/settings/chat-timeout, and of
+// its responses
+type ChatTimeoutRequest struct {
+	TimeoutSeconds int `json:"timeout_seconds"`
+}
+
+// NewChatTimeoutHandler creates a handler changing the timeout of chat,
+// saving it with save
+func NewChatTimeoutHandler(chat *ChatHandler, save func(timeout time.Duration) error) *ChatTimeoutHandler {
+	return &ChatTimeoutHandler{chat: chat, save: save}
+}
+
+// Get handles GET /api/settings/chat-timeout, returning the timeout in effect
+func (h *ChatTimeoutHandler) Get(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, ChatTimeoutRequest{TimeoutSeconds: int(h.chat.ChatTimeout().Seconds())})
+}
+
+// Update handles POST /api/settings/chat-timeout
+func (h *ChatTimeoutHandler) Update(w http.ResponseWriter, r *http.Request) {
+	var req ChatTimeoutRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	if req.TimeoutSeconds <= 0 {
+		http.Error(w, "timeout_seconds must be positive", http.StatusBadRequest)
+		return
+	}
+
+	timeout := time.Duration(req.TimeoutSeconds) * time.Second
+	if err := h.save(timeout); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to save chat timeout: %v", err), http.StatusInternalServerError)
+		return
+	}
+	h.chat.SetChatTimeout(timeout)
+
+	writeJSON(w, http.StatusOK, req)
+}
+
+// FeedSettings saves the custom feeds changed through the API with a saver,
+// such as into the config file, so they survive a restart
+type FeedSettings struct {
+	save func(feeds map[string]string) error
+}
+
+// NewFeedSettings creates feed settings saved with save
+func NewFeedSettings(save func(feeds map[string]string) error) *FeedSettings {
+	return &FeedSettings{save: save}
+}
+
+// SaveUpdates wraps the handler of POST /api/settings/feeds, saving the feeds
+// of the request before next applies them. A nil FeedSettings saves nothing.
+func (f *FeedSettings) SaveUpdates(next http.Handler) http.Handler {
+	if f == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		var req struct {
+			Feeds map[string]string `json:"feeds"`
+		}
+		if err := json.Unmarshal(body, &req); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+			return
+		}
+		for topic, feedURL := range req.Feeds {
+			if u, err := url.Parse(feedURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				http.Error(w, fmt.Sprintf("feed %q of %s is not an http(s) URL", feedURL, topic), http.StatusBadRequest)
+				return
+			}
+		}
+
+		if err := f.save(req.Feeds); err != nil {
+			http.Error(w, fmt.Sprintf("Failed to save feeds: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChatTimeoutHandler(t *testing.T) {
+	chat := NewChatHandlerWithTimeout(&endlessChatClient{}, nil, time.Hour)
+	var saved time.Duration
+	var saveErr error
+	h := NewChatTimeoutHandler(chat, func(timeout time.Duration) error {
+		if saveErr == nil {
+			saved = timeout
+		}
+		return saveErr
+	})
+
+	rec := httptest.NewRecorder()
+	h.Get(rec, httptest.NewRequest(http.MethodGet, "/api/settings/chat-timeout", nil))
+	assert.JSONEq(t, `{"timeout_seconds":3600}`, rec.Body.String(), "the timeout in effect")
+
+	update := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.Update(rec, httptest.NewRequest(http.MethodPost, "/api/settings/chat-timeout", strings.NewReader(body)))
+		return rec
+	}
+
+	rec = update(`{"timeout_seconds":300}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"timeout_seconds":300}`, rec.Body.String())
+	assert.Equal(t, 5*time.Minute, saved)
+	assert.Equal(t, 5*time.Minute, chat.ChatTimeout(), "applies to new chats at once")
+
+	assert.Equal(t, http.StatusBadRequest, update(`{"timeout_seconds":0}`).Code)
+	assert.Equal(t, http.StatusBadRequest, update(`nope`).Code)
+
+	// A timeout that can't be saved isn't applied
+	saveErr = fmt.Errorf("read-only")
+	assert.Equal(t, http.StatusInternalServerError, update(`{"timeout_seconds":60}`).Code)
+	assert.Equal(t, 5*time.Minute, chat.ChatTimeout())
+}
+
+func TestFeedSettings_SaveUpdates(t *testing.T) {
+	var saved map[string]string
+	f := NewFeedSettings(func(feeds map[string]string) error {
+		saved = feeds
+		return nil
+	})
+	var applied string
+	apply := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		applied = string(body)
+	})
+	handler := f.SaveUpdates(apply)
+
+	post := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/settings/feeds", strings.NewReader(body)))
+		return rec
+	}
+
+	body := `{"feeds":{"tech":"https://example.com/tech.rss"}}`
+	assert.Equal(t, http.StatusOK, post(body).Code)
+	assert.Equal(t, map[string]string{"tech": "https://example.com/tech.rss"}, saved)
+	assert.Equal(t, body, applied, "the next handler reads the same body")
+
+	saved, applied = nil, ""
+	assert.Equal(t, http.StatusBadRequest, post(`{"feeds":{"tech":"file:///etc/passwd"}}`).Code)
+	assert.Nil(t, saved)
+	assert.Empty(t, applied)
+
+	// Without feed settings, requests go straight to the handler
+	var none *FeedSettings
+	rec := httptest.NewRecorder()
+	none.SaveUpdates(apply).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+	assert.Equal(t, body, applied)
+}
@@ -0,0 +1,411 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/aristath/gollama-ui/internal/client"
+)
+
+// Context window strategies
+const (
+	ContextStrategyNone      = "none"      // Send history as-is
+	ContextStrategyTruncate  = "truncate"  // Drop the oldest turns until the history fits
+	ContextStrategySummarize = "summarize" // Condense the oldest turns into a summary
+)
+
+// messageOverheadTokens approximates the chat template tokens added per message
+const messageOverheadTokens = 4
+
+// maxCachedTokenCounts bounds the token count cache
+const maxCachedTokenCounts = 2048
+
+// TokenCounterInterface defines the interface for context window queries
+type TokenCounterInterface interface {
+	CountTokens(ctx context.Context, text string) (int, error)
+	ContextSize(ctx context.Context) (int, error)
+}
+
+// ModelTokenCounterInterface is implemented by clients that serve models from
+// several backends, so counts come from the backend that owns the model
+type ModelTokenCounterInterface interface {
+	CountModelTokens(ctx context.Context, model, text string) (int, error)
+	ModelContextSize(ctx context.Context, model string, opts *client.Options) (int, bool, error)
+}
+
+// ContextConfig configures how chat history is fitted into the context window
+type ContextConfig struct {
+	Strategy      string // One of the ContextStrategy* constants
+	KeepTurns     int    // Number of most recent turns that are never dropped
+	ReserveTokens int    // Tokens left free for the model's response
+	SummaryTokens int    // Tokens set aside for the summary message
+}
+
+// DroppedMessage describes a message removed from the history
+type DroppedMessage struct {
+	Index  int    `json:"index"`
+	Role   string `json:"role"`
+	Tokens int    `json:"tokens"`
+}
+
+// ContextReport describes how the history was fitted into the context window
+type ContextReport struct {
+	Strategy        string           `json:"strategy"`
+	ContextSize     int              `json:"context_size"`
+	PromptTokens    int              `json:"prompt_tokens"`
+	DroppedMessages []DroppedMessage `json:"dropped_messages,omitempty"`
+	DroppedTokens   int              `json:"dropped_tokens"`
+	Summarized      bool             `json:"summarized,omitempty"`
+	SummaryTokens   int              `json:"summary_tokens,omitempty"`
+	OverBudget      bool             `json:"over_budget,omitempty"`
+}
+
+// ContextWindow fits chat history into the backend's context window
+type ContextWindow struct {
+	counter     TokenCounterInterface
+	config      ContextConfig
+	summarizer  *Summarizer
+	mu          sync.Mutex
+	tokenCounts map[[sha256.Size]byte]int
+	countOrder  [][sha256.Size]byte // Cached keys, oldest first
+	contextSize map[string]int      // Context size per model
+}
+
+// NewContextWindow creates a new context window manager
+func NewContextWindow(counter TokenCounterInterface, config ContextConfig) *ContextWindow {
+	if config.Strategy == "" {
+		config.Strategy = ContextStrategyTruncate
+	}
+	if config.KeepTurns < 1 {
+		config.KeepTurns = 1
+	}
+	if config.ReserveTokens < 0 {
+		config.ReserveTokens = 0
+	}
+	if config.SummaryTokens <= 0 {
+		config.SummaryTokens = 256
+	}
+
+	return &ContextWindow{
+		counter:     counter,
+		config:      config,
+		tokenCounts: make(map[[sha256.Size]byte]int),
+		contextSize: make(map[string]int),
+	}
+}
+
+// ValidateContextStrategy checks that strategy names a known strategy
+func ValidateContextStrategy(strategy string) error {
+	switch strategy {
+	case ContextStrategyNone, ContextStrategyTruncate, ContextStrategySummarize:
+		return nil
+	default:
+		return fmt.Errorf("unknown context strategy: %s", strategy)
+	}
+}
+
+// SetSummarizer sets the summarizer used by the summarize strategy
+func (cw *ContextWindow) SetSummarizer(s *Summarizer) {
+	cw.summarizer = s
+}
+
+// Fit returns the messages of req that fit into the model's context window.
+// conversationID identifies the conversation for cached summaries and may be
+// empty, in which case the summarize strategy falls back to truncation.
+// The returned report is nil when nothing had to be done.
+func (cw *ContextWindow) Fit(ctx context.Context, req *client.ChatRequest, conversationID string) ([]client.ChatMessage, *ContextReport, error) {
+	if cw.config.Strategy == ContextStrategyNone {
+		return req.Messages, nil, nil
+	}
+
+	contextSize, err := cw.getContextSize(ctx, req)
+	if err != nil {
+		return req.Messages, nil, err
+	}
+
+	counts := make([]int, len(req.Messages))
+	total := 0
+	for i, msg := range req.Messages {
+		n, err := cw.countMessage(ctx, req.Model, msg)
+		if err != nil {
+			return req.Messages, nil, err
+		}
+		counts[i] = n
+		total += n
+	}
+
+	budget := contextSize - cw.config.ReserveTokens
+	if len(req.Tools) > 0 {
+		toolsJSON, err := json.Marshal(req.Tools)
+		if err == nil {
+			toolTokens, err := cw.countText(ctx, req.Model, string(toolsJSON))
+			if err != nil {
+				return req.Messages, nil, err
+			}
+			budget -= toolTokens
+		}
+	}
+
+	if total <= budget {
+		return req.Messages, nil, nil
+	}
+
+	report := &ContextReport{
+		Strategy:    cw.config.Strategy,
+		ContextSize: contextSize,
+	}
+
+	if cw.config.Strategy == ContextStrategySummarize && cw.summarizer != nil && conversationID != "" {
+		fitted, err := cw.summarize(ctx, req, conversationID, counts, total, budget, report)
+		if err == nil {
+			return fitted, report, nil
+		}
+		slog.WarnContext(ctx, "Summarizing conversation failed, truncating instead", "conversation", conversationID, "error", err)
+		report = &ContextReport{
+			Strategy:    ContextStrategyTruncate,
+			ContextSize: contextSize,
+		}
+	}
+
+	dropped, _ := selectDropped(req.Messages, counts, total, budget, cw.config.KeepTurns)
+
+	fitted := make([]client.ChatMessage, 0, len(req.Messages))
+	for i, msg := range req.Messages {
+		if dropped[i] {
+			total -= counts[i]
+			report.DroppedTokens += counts[i]
+			report.DroppedMessages = append(report.DroppedMessages, DroppedMessage{
+				Index:  i,
+				Role:   msg.Role,
+				Tokens: counts[i],
+			})
+			continue
+		}
+		fitted = append(fitted, msg)
+	}
+
+	report.PromptTokens = total
+	report.OverBudget = total > budget
+
+	return fitted, report, nil
+}
+
+// summarize replaces the oldest turns with a summary system message
+func (cw *ContextWindow) summarize(ctx context.Context, req *client.ChatRequest, conversationID string,
+	counts []int, total, budget int, report *ContextReport) ([]client.ChatMessage, error) {
+
+	_, cutoff := selectDropped(req.Messages, counts, total, budget-cw.config.SummaryTokens, cw.config.KeepTurns)
+	if cutoff == 0 {
+		return nil, fmt.Errorf("no turns can be summarized")
+	}
+
+	summary, covered, err := cw.summarizer.Summarize(ctx, req.Model, conversationID, req.Messages, cutoff)
+	if err != nil {
+		return nil, err
+	}
+
+	summaryMsg := client.ChatMessage{
+		Role:    "system",
+		Content: "Summary of the earlier conversation:\n" + summary,
+	}
+	summaryTokens, err := cw.countMessage(ctx, req.Model, summaryMsg)
+	if err != nil {
+		return nil, err
+	}
+
+	fitted := make([]client.ChatMessage, 0, len(req.Messages)-covered+1)
+	inserted := false
+	for i, msg := range req.Messages {
+		if i < covered && msg.Role != "system" {
+			total -= counts[i]
+			report.DroppedTokens += counts[i]
+			report.DroppedMessages = append(report.DroppedMessages, DroppedMessage{
+				Index:  i,
+				Role:   msg.Role,
+				Tokens: counts[i],
+			})
+			continue
+		}
+		if !inserted && i >= covered {
+			fitted = append(fitted, summaryMsg)
+			inserted = true
+		}
+		fitted = append(fitted, msg)
+	}
+	if !inserted {
+		fitted = append(fitted, summaryMsg)
+	}
+
+	total += summaryTokens
+	report.Summarized = true
+	report.SummaryTokens = summaryTokens
+	report.PromptTokens = total
+	report.OverBudget = total > budget
+
+	return fitted, nil
+}
+
+// selectDropped marks the oldest droppable turns until the history fits into
+// budget. It returns the marks and the index just past the last dropped turn.
+func selectDropped(messages []client.ChatMessage, counts []int, total, budget, keepTurns int) ([]bool, int) {
+	dropped := make([]bool, len(messages))
+	cutoff := 0
+	for _, turn := range droppableTurns(messages, keepTurns) {
+		if total <= budget {
+			break
+		}
+		for i := turn[0]; i < turn[1]; i++ {
+			dropped[i] = true
+			total -= counts[i]
+		}
+		cutoff = turn[1]
+	}
+	return dropped, cutoff
+}
+
+// droppableTurns returns the [start, end) ranges of turns that may be dropped,
+// oldest first. A turn starts at a user message and runs until the next one, so
+// assistant tool calls always stay together with their tool results. System
+// messages and the last keepTurns turns are never included.
+func droppableTurns(messages []client.ChatMessage, keepTurns int) [][2]int {
+	var turns [][2]int
+	start := -1
+	for i, msg := range messages {
+		if msg.Role == "user" {
+			if start >= 0 {
+				turns = append(turns, [2]int{start, i})
+			}
+			start = i
+		} else if start < 0 && msg.Role != "system" {
+			// Assistant or tool messages before the first user message
+			start = i
+		}
+	}
+	if start >= 0 {
+		turns = append(turns, [2]int{start, len(messages)})
+	}
+
+	if len(turns) <= keepTurns {
+		return nil
+	}
+	turns = turns[:len(turns)-keepTurns]
+
+	// Split out system messages that sit inside a droppable turn
+	result := make([][2]int, 0, len(turns))
+	for _, turn := range turns {
+		segStart := turn[0]
+		for i := turn[0]; i < turn[1]; i++ {
+			if messages[i].Role == "system" {
+				if i > segStart {
+					result = append(result, [2]int{segStart, i})
+				}
+				segStart = i + 1
+			}
+		}
+		if segStart < turn[1] {
+			result = append(result, [2]int{segStart, turn[1]})
+		}
+	}
+
+	return result
+}
+
+// countMessage returns the approximate prompt tokens used by a message
+func (cw *ContextWindow) countMessage(ctx context.Context, model string, msg client.ChatMessage) (int, error) {
+	text := msg.Role + "\n" + msg.Content
+	for _, tc := range msg.ToolCalls {
+		text += "\n" + tc.Function.Name + " " + tc.Function.Arguments
+	}
+
+	n, err := cw.countText(ctx, model, text)
+	if err != nil {
+		return 0, err
+	}
+
+	return n + messageOverheadTokens, nil
+}
+
+// countText returns the token count for text, using the cache when possible
+func (cw *ContextWindow) countText(ctx context.Context, model, text string) (int, error) {
+	// Backends tokenize differently, so counts are cached per model. The key
+	// is hashed so the cache doesn't keep whole messages alive.
+	h := sha256.New()
+	h.Write([]byte(model))
+	h.Write([]byte{0})
+	h.Write([]byte(text))
+	var key [sha256.Size]byte
+	h.Sum(key[:0])
+
+	cw.mu.Lock()
+	n, ok := cw.tokenCounts[key]
+	cw.mu.Unlock()
+	if ok {
+		return n, nil
+	}
+
+	var err error
+	if mc, ok := cw.counter.(ModelTokenCounterInterface); ok {
+		n, err = mc.CountModelTokens(ctx, model, text)
+	} else {
+		n, err = cw.counter.CountTokens(ctx, text)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to count tokens: %w", err)
+	}
+
+	cw.mu.Lock()
+	if _, ok := cw.tokenCounts[key]; !ok {
+		// Evict the oldest counts rather than emptying the cache
+		for len(cw.countOrder) >= maxCachedTokenCounts {
+			delete(cw.tokenCounts, cw.countOrder[0])
+			cw.countOrder = cw.countOrder[1:]
+		}
+		cw.countOrder = append(cw.countOrder, key)
+	}
+	cw.tokenCounts[key] = n
+	cw.mu.Unlock()
+
+	return n, nil
+}
+
+// getContextSize returns the context size for the model of req. Sizes the
+// backend reports as fixed are cached per model, unless the request asks for
+// its own. Counters that can't look up a model report whichever model is
+// loaded, so their answer isn't cached.
+func (cw *ContextWindow) getContextSize(ctx context.Context, req *client.ChatRequest) (int, error) {
+	mc, ok := cw.counter.(ModelTokenCounterInterface)
+	if !ok {
+		n, err := cw.counter.ContextSize(ctx)
+		if err != nil {
+			return 0, fmt.Errorf("failed to get context size: %w", err)
+		}
+		return n, nil
+	}
+
+	cacheable := req.Options == nil || req.Options.NumCtx == nil
+	if cacheable {
+		cw.mu.Lock()
+		n, ok := cw.contextSize[req.Model]
+		cw.mu.Unlock()
+		if ok {
+			return n, nil
+		}
+	}
+
+	n, fixed, err := mc.ModelContextSize(ctx, req.Model, req.Options)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get context size: %w", err)
+	}
+
+	if cacheable && fixed {
+		cw.mu.Lock()
+		cw.contextSize[req.Model] = n
+		cw.mu.Unlock()
+	}
+
+	return n, nil
+}
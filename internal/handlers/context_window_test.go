@@ -0,0 +1,300 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/aristath/gollama-ui/internal/client"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeTokenCounter counts one token per word
+type fakeTokenCounter struct {
+	contextSize int
+}
+
+func (f *fakeTokenCounter) CountTokens(ctx context.Context, text string) (int, error) {
+	return len(strings.Fields(text)), nil
+}
+
+func (f *fakeTokenCounter) ContextSize(ctx context.Context) (int, error) {
+	return f.contextSize, nil
+}
+
+func TestContextWindow_Fit_NoTruncationNeeded(t *testing.T) {
+	cw := NewContextWindow(&fakeTokenCounter{contextSize: 1000}, ContextConfig{KeepTurns: 1})
+
+	req := &client.ChatRequest{
+		Model: "test",
+		Messages: []client.ChatMessage{
+			{Role: "system", Content: "be nice"},
+			{Role: "user", Content: "hello"},
+		},
+	}
+
+	messages, report, err := cw.Fit(context.Background(), req, "")
+	assert.NoError(t, err)
+	assert.Nil(t, report)
+	assert.Len(t, messages, 2)
+}
+
+func TestContextWindow_Fit_DropsOldestTurns(t *testing.T) {
+	cw := NewContextWindow(&fakeTokenCounter{contextSize: 40}, ContextConfig{KeepTurns: 1})
+
+	long := strings.Repeat("word ", 10)
+	req := &client.ChatRequest{
+		Model: "test",
+		Messages: []client.ChatMessage{
+			{Role: "system", Content: "be nice"},
+			{Role: "user", Content: long},
+			{Role: "assistant", Content: long},
+			{Role: "user", Content: "latest question"},
+		},
+	}
+
+	messages, report, err := cw.Fit(context.Background(), req, "")
+	assert.NoError(t, err)
+	assert.NotNil(t, report)
+	assert.Len(t, messages, 2)
+	assert.Equal(t, "system", messages[0].Role)
+	assert.Equal(t, "latest question", messages[1].Content)
+	assert.Len(t, report.DroppedMessages, 2)
+	assert.False(t, report.OverBudget)
+}
+
+func TestContextWindow_Fit_KeepsToolCallsWithResults(t *testing.T) {
+	cw := NewContextWindow(&fakeTokenCounter{contextSize: 60}, ContextConfig{KeepTurns: 1})
+
+	long := strings.Repeat("word ", 10)
+	req := &client.ChatRequest{
+		Model: "test",
+		Messages: []client.ChatMessage{
+			{Role: "user", Content: "first"},
+			{Role: "assistant", ToolCalls: []client.ToolCall{{ID: "1", Function: client.FunctionCall{Name: "web_search", Arguments: "{}"}}}},
+			{Role: "tool", ToolCallID: "1", Content: long},
+			{Role: "assistant", Content: long},
+			{Role: "user", Content: "second"},
+			{Role: "assistant", ToolCalls: []client.ToolCall{{ID: "2", Function: client.FunctionCall{Name: "web_search", Arguments: "{}"}}}},
+			{Role: "tool", ToolCallID: "2", Content: "result"},
+		},
+	}
+
+	messages, report, err := cw.Fit(context.Background(), req, "")
+	assert.NoError(t, err)
+	assert.NotNil(t, report)
+	assert.Len(t, messages, 3)
+	assert.Equal(t, "second", messages[0].Content)
+	assert.Equal(t, "2", messages[2].ToolCallID)
+}
+
+func TestContextWindow_Fit_StrategyNone(t *testing.T) {
+	cw := NewContextWindow(&fakeTokenCounter{contextSize: 1}, ContextConfig{Strategy: ContextStrategyNone})
+
+	req := &client.ChatRequest{
+		Model:    "test",
+		Messages: []client.ChatMessage{{Role: "user", Content: "a b c d e f"}},
+	}
+
+	messages, report, err := cw.Fit(context.Background(), req, "")
+	assert.NoError(t, err)
+	assert.Nil(t, report)
+	assert.Len(t, messages, 1)
+}
+
+// fakeSummaryClient answers every chat request with a fixed summary
+type fakeSummaryClient struct {
+	calls int
+}
+
+func (f *fakeSummaryClient) ChatStream(ctx context.Context, req client.ChatRequest) (<-chan client.ChatResponse, error) {
+	f.calls++
+	ch := make(chan client.ChatResponse, 1)
+	ch <- client.ChatResponse{Model: req.Model, Message: client.ChatMessage{Role: "assistant", Content: "short summary"}, Done: true}
+	close(ch)
+	return ch, nil
+}
+
+func TestContextWindow_Fit_SummarizesAndCaches(t *testing.T) {
+	chatClient := &fakeSummaryClient{}
+	store := NewConversationStore(t.TempDir())
+	cw := NewContextWindow(&fakeTokenCounter{contextSize: 40}, ContextConfig{
+		Strategy:      ContextStrategySummarize,
+		KeepTurns:     1,
+		SummaryTokens: 10,
+	})
+	cw.SetSummarizer(NewSummarizer(chatClient, store))
+
+	long := strings.Repeat("word ", 10)
+	history := []client.ChatMessage{
+		{Role: "system", Content: "be nice"},
+		{Role: "user", Content: long},
+		{Role: "assistant", Content: long},
+		{Role: "user", Content: "latest question"},
+	}
+
+	messages, report, err := cw.Fit(context.Background(), &client.ChatRequest{Model: "test", Messages: history}, "conv-1")
+	assert.NoError(t, err)
+	assert.NotNil(t, report)
+	assert.True(t, report.Summarized)
+	assert.Len(t, messages, 3)
+	assert.Equal(t, "be nice", messages[0].Content)
+	assert.Contains(t, messages[1].Content, "short summary")
+	assert.Equal(t, "latest question", messages[2].Content)
+	assert.Equal(t, 1, chatClient.calls)
+
+	conv, err := store.Get("conv-1")
+	assert.NoError(t, err)
+	assert.Equal(t, "short summary", conv.Summary)
+	assert.Equal(t, 3, conv.SummarizedCount)
+
+	// The next turn still fits with the cached summary, so no new summary is generated
+	history = append(history, client.ChatMessage{Role: "assistant", Content: "answer"}, client.ChatMessage{Role: "user", Content: "follow up"})
+	messages, report, err = cw.Fit(context.Background(), &client.ChatRequest{Model: "test", Messages: history}, "conv-1")
+	assert.NoError(t, err)
+	assert.True(t, report.Summarized)
+	assert.Len(t, messages, 5)
+	assert.Equal(t, 1, chatClient.calls)
+}
+
+// fakeModelTokenCounter reports a context size per model, counting lookups.
+// Models in unloaded report a size that isn't fixed.
+type fakeModelTokenCounter struct {
+	fakeTokenCounter
+	sizes    map[string]int
+	unloaded map[string]bool
+	lookups  int
+}
+
+func (f *fakeModelTokenCounter) CountModelTokens(ctx context.Context, model, text string) (int, error) {
+	return f.CountTokens(ctx, text)
+}
+
+func (f *fakeModelTokenCounter) ModelContextSize(ctx context.Context, model string, opts *client.Options) (int, bool, error) {
+	f.lookups++
+	if opts != nil && opts.NumCtx != nil {
+		return *opts.NumCtx, false, nil
+	}
+	return f.sizes[model], !f.unloaded[model], nil
+}
+
+// countingTokenCounter counts one token per word per model, counting calls
+type countingTokenCounter struct {
+	fakeTokenCounter
+	calls int
+}
+
+func (f *countingTokenCounter) CountModelTokens(ctx context.Context, model, text string) (int, error) {
+	f.calls++
+	return len(strings.Fields(text)), nil
+}
+
+func (f *countingTokenCounter) ModelContextSize(ctx context.Context, model string, opts *client.Options) (int, bool, error) {
+	return f.contextSize, true, nil
+}
+
+func TestContextWindow_TokenCountCache(t *testing.T) {
+	counter := &countingTokenCounter{}
+	cw := NewContextWindow(counter, ContextConfig{})
+	ctx := context.Background()
+
+	cw.countText(ctx, "a", "hello world")
+	cw.countText(ctx, "a", "hello world")
+	cw.countText(ctx, "b", "hello world")
+	assert.Equal(t, 2, counter.calls, "counts are cached per model")
+
+	// A full cache evicts the oldest counts, keeping the recent ones
+	for i := 0; i < maxCachedTokenCounts; i++ {
+		cw.countText(ctx, "a", fmt.Sprintf("text %d", i))
+	}
+	assert.Len(t, cw.tokenCounts, maxCachedTokenCounts)
+	assert.Len(t, cw.countOrder, maxCachedTokenCounts)
+	counter.calls = 0
+	cw.countText(ctx, "a", "text 0")
+	assert.Equal(t, 0, counter.calls, "recent counts stay cached")
+	n, err := cw.countText(ctx, "a", "hello world")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, 1, counter.calls, "the oldest count is evicted")
+}
+
+func TestContextWindow_ContextSizePerModel(t *testing.T) {
+	counter := &fakeModelTokenCounter{sizes: map[string]int{"small": 2048, "large": 32768}}
+	cw := NewContextWindow(counter, ContextConfig{})
+
+	for _, model := range []string{"small", "large", "small", "large"} {
+		n, err := cw.getContextSize(context.Background(), &client.ChatRequest{Model: model})
+		assert.NoError(t, err)
+		assert.Equal(t, counter.sizes[model], n, model)
+	}
+	assert.Equal(t, 2, counter.lookups, "each model is looked up once")
+
+	// A request's own context size isn't cached, nor does it use the cache
+	numCtx := 8192
+	n, _ := cw.getContextSize(context.Background(), &client.ChatRequest{Model: "small", Options: &client.Options{NumCtx: &numCtx}})
+	assert.Equal(t, 8192, n)
+	n, _ = cw.getContextSize(context.Background(), &client.ChatRequest{Model: "small"})
+	assert.Equal(t, 2048, n)
+
+	// A counter that only knows the loaded model is asked every time
+	single := &fakeTokenCounter{contextSize: 2048}
+	cw = NewContextWindow(single, ContextConfig{})
+	cw.getContextSize(context.Background(), &client.ChatRequest{Model: "small"})
+	single.contextSize = 4096
+	n, _ = cw.getContextSize(context.Background(), &client.ChatRequest{Model: "large"})
+	assert.Equal(t, 4096, n)
+}
+
+func TestContextWindow_ContextSizeOfUnloadedModel(t *testing.T) {
+	counter := &fakeModelTokenCounter{sizes: map[string]int{"m": 4096}, unloaded: map[string]bool{"m": true}}
+	cw := NewContextWindow(counter, ContextConfig{})
+	req := &client.ChatRequest{Model: "m"}
+
+	cw.getContextSize(context.Background(), req)
+	cw.getContextSize(context.Background(), req)
+	assert.Equal(t, 2, counter.lookups, "looked up until the model is loaded")
+
+	// Once loaded, the size it runs with is cached
+	counter.unloaded["m"] = false
+	counter.sizes["m"] = 8192
+	n, _ := cw.getContextSize(context.Background(), req)
+	assert.Equal(t, 8192, n)
+	cw.getContextSize(context.Background(), req)
+	assert.Equal(t, 3, counter.lookups)
+}
+
+// racingSummaryClient saves a reply to the conversation while it summarizes,
+// like a job finishing at the same time
+type racingSummaryClient struct {
+	store *ConversationStore
+}
+
+func (f *racingSummaryClient) ChatStream(ctx context.Context, req client.ChatRequest) (<-chan client.ChatResponse, error) {
+	f.store.Update("conv-1", func(conv *Conversation) {
+		conv.Messages = append(conv.Messages, client.ChatMessage{Role: "assistant", Content: "saved meanwhile"})
+	})
+	return (&fakeSummaryClient{}).ChatStream(ctx, req)
+}
+
+func TestSummarizer_KeepsConcurrentSaves(t *testing.T) {
+	store := NewConversationStore(t.TempDir())
+	s := NewSummarizer(&racingSummaryClient{store: store}, store)
+
+	history := []client.ChatMessage{
+		{Role: "user", Content: "first"},
+		{Role: "assistant", Content: "answer"},
+		{Role: "user", Content: "second"},
+	}
+	summary, covered, err := s.Summarize(context.Background(), "test", "conv-1", history, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, "short summary", summary)
+	assert.Equal(t, 2, covered)
+
+	conv, err := store.Get("conv-1")
+	if !assert.NoError(t, err) || !assert.NotNil(t, conv) {
+		return
+	}
+	assert.Equal(t, "short summary", conv.Summary)
+	assert.Len(t, conv.Messages, 1, "the reply saved during the summary is kept")
+}
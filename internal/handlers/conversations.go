@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/aristath/gollama-ui/internal/client"
+)
+
+// conversationIDPattern restricts conversation IDs to safe file names
+var conversationIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// Conversation holds the server-side state of a conversation
+type Conversation struct {
+	ID              string    `json:"id"`
+	Summary         string    `json:"summary,omitempty"`
+	SummarizedCount int       `json:"summarized_count,omitempty"` // Number of leading messages covered by Summary
+	SummaryHash     string    `json:"summary_hash,omitempty"`     // Hash of the messages covered by Summary
+	UpdatedAt       time.Time `json:"updated_at"`
+
+	// Messages is the full history, stored when a detached generation completes
+	Messages []client.ChatMessage `json:"messages,omitempty"`
+}
+
+// ConversationStore persists conversations as JSON files in a directory
+type ConversationStore struct {
+	dir string
+	mu  sync.Mutex
+
+	usersDir string
+	usersMu  sync.Mutex
+	users    map[string]*ConversationStore
+}
+
+// NewConversationStore creates a new conversation store
+func NewConversationStore(dir string) *ConversationStore {
+	return &ConversationStore{
+		dir: dir,
+	}
+}
+
+// SetUsersDir gives every authenticated user their own conversations under
+// <dir>/<user>/conversations. Without it, or without authentication, all
+// clients share the store.
+func (s *ConversationStore) SetUsersDir(dir string) {
+	s.usersDir = dir
+}
+
+// ForContext returns the conversations of the user of ctx
+func (s *ConversationStore) ForContext(ctx context.Context) *ConversationStore {
+	user := userFromContext(ctx)
+	if s.usersDir == "" || user == "" {
+		return s
+	}
+
+	s.usersMu.Lock()
+	defer s.usersMu.Unlock()
+
+	if s.users == nil {
+		s.users = make(map[string]*ConversationStore)
+	}
+	store, ok := s.users[user]
+	if !ok {
+		store = NewConversationStore(filepath.Join(s.usersDir, user, "conversations"))
+		s.users[user] = store
+	}
+	return store
+}
+
+// ValidConversationID reports whether id can be used as a conversation ID
+func ValidConversationID(id string) bool {
+	return conversationIDPattern.MatchString(id)
+}
+
+// Get loads a conversation, returning nil if it does not exist yet
+func (s *ConversationStore) Get(id string) (*Conversation, error) {
+	if !ValidConversationID(id) {
+		return nil, fmt.Errorf("invalid conversation id: %q", id)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.load(id)
+}
+
+// Save persists a conversation
+func (s *ConversationStore) Save(conv *Conversation) error {
+	if !ValidConversationID(conv.ID) {
+		return fmt.Errorf("invalid conversation id: %q", conv.ID)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.write(conv)
+}
+
+// Update loads a conversation, creating it if needed, applies fn and saves
+// the result without other writers interleaving
+func (s *ConversationStore) Update(id string, fn func(conv *Conversation)) error {
+	if !ValidConversationID(id) {
+		return fmt.Errorf("invalid conversation id: %q", id)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	conv, err := s.load(id)
+	if err != nil {
+		return err
+	}
+	if conv == nil {
+		conv = &Conversation{ID: id}
+	}
+
+	fn(conv)
+	return s.write(conv)
+}
+
+// load reads a conversation file. s.mu must be held.
+func (s *ConversationStore) load(id string) (*Conversation, error) {
+	data, err := os.ReadFile(s.path(id))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read conversation: %w", err)
+	}
+
+	var conv Conversation
+	if err := json.Unmarshal(data, &conv); err != nil {
+		return nil, fmt.Errorf("failed to parse conversation: %w", err)
+	}
+
+	return &conv, nil
+}
+
+// write stores a conversation file. s.mu must be held.
+func (s *ConversationStore) write(conv *Conversation) error {
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create conversations directory: %w", err)
+	}
+
+	conv.UpdatedAt = time.Now()
+	data, err := json.MarshalIndent(conv, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal conversation: %w", err)
+	}
+
+	if err := os.WriteFile(s.path(conv.ID), data, 0644); err != nil {
+		return fmt.Errorf("failed to write conversation: %w", err)
+	}
+
+	return nil
+}
+
+// path returns the file path for a conversation
+func (s *ConversationStore) path(id string) string {
+	return filepath.Join(s.dir, id+".json")
+}
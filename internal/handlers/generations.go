@@ -0,0 +1,369 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/aristath/gollama-ui/internal/client"
+)
+
+// defaultResumeGrace is how long a generation keeps running without a
+// connected client, and how long its events are kept once it has finished
+const defaultResumeGrace = 2 * time.Minute
+
+// maxBufferedEvents bounds the events kept per generation; the oldest are
+// dropped first
+const maxBufferedEvents = 20000
+
+// sseEvent is one buffered Server-Sent Event
+type sseEvent struct {
+	id    int
+	block []byte // The event's lines without the terminating blank line
+}
+
+// generation is a running chat whose events are buffered so a client can
+// reconnect and resume the stream. It implements http.ResponseWriter and
+// http.Flusher so the chat handler can write SSE to it directly.
+type generation struct {
+	id     string
+	owner  string // User who started the generation
+	cancel context.CancelFunc
+	grace  time.Duration
+	header http.Header
+
+	mu         sync.Mutex
+	events     []sseEvent
+	nextID     int
+	partial    []byte // Bytes of an event that is still being written
+	done       bool
+	detached   bool // Runs as a job, so it isn't cancelled when its clients leave
+	followers  int
+	changed    chan struct{} // Closed and replaced when events are added or the generation ends
+	graceTimer *time.Timer
+}
+
+func newGeneration(id string, cancel context.CancelFunc, grace time.Duration) *generation {
+	return &generation{
+		id:      id,
+		cancel:  cancel,
+		grace:   grace,
+		header:  make(http.Header),
+		nextID:  1,
+		changed: make(chan struct{}),
+	}
+}
+
+// Header implements http.ResponseWriter. Headers of a generation are never sent.
+func (g *generation) Header() http.Header {
+	return g.header
+}
+
+// WriteHeader implements http.ResponseWriter
+func (g *generation) WriteHeader(statusCode int) {}
+
+// Write buffers complete events, each terminated by a blank line
+func (g *generation) Write(p []byte) (int, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.partial = append(g.partial, p...)
+	added := false
+	for {
+		i := bytes.Index(g.partial, []byte("\n\n"))
+		if i < 0 {
+			break
+		}
+		g.events = append(g.events, sseEvent{id: g.nextID, block: append([]byte(nil), g.partial[:i]...)})
+		g.nextID++
+		g.partial = g.partial[i+2:]
+		added = true
+	}
+
+	if len(g.events) > maxBufferedEvents {
+		g.events = append([]sseEvent(nil), g.events[len(g.events)-maxBufferedEvents:]...)
+	}
+	if added {
+		g.notifyLocked()
+	}
+
+	return len(p), nil
+}
+
+// Flush implements http.Flusher. Events are delivered as soon as they are complete.
+func (g *generation) Flush() {}
+
+// finish marks the generation as ended
+func (g *generation) finish() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.done = true
+	if g.graceTimer != nil {
+		g.graceTimer.Stop()
+	}
+	g.notifyLocked()
+}
+
+// finished reports whether the generation has ended
+func (g *generation) finished() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.done
+}
+
+// lastError returns the error of the last event that reported one
+func (g *generation) lastError() string {
+	var payload struct {
+		Error string `json:"error"`
+	}
+	if g.lastData(func(data []byte) bool {
+		return json.Unmarshal(data, &payload) == nil && payload.Error != ""
+	}) {
+		return payload.Error
+	}
+
+	return "generation did not complete"
+}
+
+// partialContent returns the assistant content streamed so far, across
+// every round of the tool loop
+func (g *generation) partialContent() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var content strings.Builder
+	for _, e := range g.events {
+		// Named events report progress rather than content
+		if bytes.HasPrefix(e.block, []byte("event: ")) {
+			continue
+		}
+		data, ok := bytes.CutPrefix(e.block, []byte("data: "))
+		if !ok {
+			continue
+		}
+		var response client.ChatResponse
+		if json.Unmarshal(data, &response) == nil {
+			content.WriteString(response.Message.Content)
+		}
+	}
+
+	return content.String()
+}
+
+// stats returns the usage and timings of the last response that reported them
+func (g *generation) stats() (*client.Usage, *client.Timings) {
+	var response client.ChatResponse
+	g.lastData(func(data []byte) bool {
+		return json.Unmarshal(data, &response) == nil && (response.Usage != nil || response.Timings != nil)
+	})
+	return response.Usage, response.Timings
+}
+
+// lastData calls match with the data of each event, newest first, until it
+// returns true. It reports whether an event matched.
+func (g *generation) lastData(match func(data []byte) bool) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for i := len(g.events) - 1; i >= 0; i-- {
+		for _, line := range bytes.Split(g.events[i].block, []byte("\n")) {
+			data, ok := bytes.CutPrefix(line, []byte("data: "))
+			if ok && match(data) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// since returns the events after lastID, whether the generation has ended,
+// and a channel closed on the next change
+func (g *generation) since(lastID int) ([]sseEvent, bool, <-chan struct{}) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var result []sseEvent
+	for i, e := range g.events {
+		if e.id > lastID {
+			result = g.events[i:]
+			break
+		}
+	}
+
+	return result, g.done, g.changed
+}
+
+// attach registers a connected client, stopping a pending grace timer
+func (g *generation) attach() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.followers++
+	if g.graceTimer != nil {
+		g.graceTimer.Stop()
+		g.graceTimer = nil
+	}
+}
+
+// detach unregisters a client. When the last client leaves a running
+// generation, it is cancelled unless a client reconnects within the grace
+// period, including while it is still queued or reading the prompt. Jobs
+// keep running.
+func (g *generation) detach() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.followers--
+	if g.followers == 0 && !g.done && !g.detached {
+		g.graceTimer = time.AfterFunc(g.grace, g.cancel)
+	}
+}
+
+// runDetached marks the generation as a job that runs without a client
+func (g *generation) runDetached() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.detached = true
+}
+
+// notifyLocked wakes every follower. g.mu must be held.
+func (g *generation) notifyLocked() {
+	close(g.changed)
+	g.changed = make(chan struct{})
+}
+
+// SetResumeGrace sets how long a chat keeps generating after its client
+// disconnects, waiting for the client to resume the stream
+func (h *ChatHandler) SetResumeGrace(grace time.Duration) {
+	h.resumeGrace = grace
+}
+
+// startGeneration registers a new generation running under ctx. The
+// generation is forgotten a grace period after it finishes. The caller must
+// call h.running.Done once the generation's result is saved.
+func (h *ChatHandler) startGeneration(ctx context.Context) (*generation, context.Context, error) {
+	id, err := newID()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.shuttingDown {
+		return nil, nil, errShuttingDown
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, h.chatTimeout)
+	g := newGeneration(id, cancel, h.resumeGrace)
+	g.owner = userFromContext(ctx)
+	h.generations[id] = g
+	h.running.Add(1)
+
+	return g, ctx, nil
+}
+
+// endGeneration finishes g and schedules its removal
+func (h *ChatHandler) endGeneration(g *generation) {
+	g.finish()
+	g.cancel()
+
+	time.AfterFunc(h.resumeGrace, func() {
+		h.mu.Lock()
+		delete(h.generations, g.id)
+		h.mu.Unlock()
+	})
+}
+
+// getGeneration returns the generation with the given ID if the client of
+// ctx may access it
+func (h *ChatHandler) getGeneration(ctx context.Context, id string) (*generation, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	g, ok := h.generations[id]
+	if !ok || !canAccess(ctx, g.owner) {
+		return nil, false
+	}
+	return g, true
+}
+
+// follow streams the events of g after lastID to the client until the
+// generation ends or the client disconnects
+func (h *ChatHandler) follow(w http.ResponseWriter, r *http.Request, g *generation, lastID int) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("X-Chat-ID", g.id)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	if r.ProtoMajor == 1 {
+		w.Header().Set("Connection", "keep-alive") // HTTP/2 forbids connection headers
+	}
+	w.Header().Set("X-Accel-Buffering", "no") // Disable nginx buffering
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	g.attach()
+	defer g.detach()
+
+	for {
+		events, done, changed := g.since(lastID)
+		for _, e := range events {
+			fmt.Fprintf(w, "id: %d\n%s\n\n", e.id, e.block)
+			lastID = e.id
+		}
+		if len(events) > 0 {
+			flusher.Flush()
+		}
+		if done {
+			return
+		}
+
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// Resume handles GET /api/chat/{id}/stream, replaying the events after the
+// Last-Event-ID header (or last_event_id query parameter) and following the
+// generation until it ends
+func (h *ChatHandler) Resume(w http.ResponseWriter, r *http.Request) {
+	g, ok := h.getGeneration(r.Context(), chi.URLParam(r, "id"))
+	if !ok {
+		http.Error(w, "unknown or expired chat id", http.StatusNotFound)
+		return
+	}
+
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("last_event_id")
+	}
+
+	lastID := 0
+	if lastEventID != "" {
+		n, err := strconv.Atoi(lastEventID)
+		if err != nil || n < 0 {
+			http.Error(w, "invalid Last-Event-ID", http.StatusBadRequest)
+			return
+		}
+		lastID = n
+	}
+
+	h.follow(w, r, g, lastID)
+}
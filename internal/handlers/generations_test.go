@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aristath/gollama-ui/internal/client"
+)
+
+// gatedChatClient streams "a", waits for the gate, then streams "b" and ends
+type gatedChatClient struct {
+	gate chan struct{}
+}
+
+func (f *gatedChatClient) ChatStream(ctx context.Context, req client.ChatRequest) (<-chan client.ChatResponse, error) {
+	ch := make(chan client.ChatResponse)
+	go func() {
+		defer close(ch)
+		ch <- client.ChatResponse{Message: client.ChatMessage{Role: "assistant", Content: "a"}}
+		select {
+		case <-f.gate:
+		case <-ctx.Done():
+			return
+		}
+		ch <- client.ChatResponse{Message: client.ChatMessage{Role: "assistant", Content: "b"}, Done: true, DoneReason: "stop"}
+	}()
+	return ch, nil
+}
+
+func resumeRequest(id, lastEventID string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/api/chat/"+id+"/stream", nil)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", id)
+	return req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+}
+
+func TestGeneration_BuffersEvents(t *testing.T) {
+	g := newGeneration("x", func() {}, time.Minute)
+
+	fmt.Fprint(g, "data: one\n\nevent: meta\ndata: t")
+	fmt.Fprint(g, "wo\n\n")
+
+	events, done, _ := g.since(0)
+	assert.False(t, done)
+	assert.Len(t, events, 2)
+	assert.Equal(t, 1, events[0].id)
+	assert.Equal(t, "data: one", string(events[0].block))
+	assert.Equal(t, "event: meta\ndata: two", string(events[1].block))
+
+	events, _, _ = g.since(1)
+	assert.Len(t, events, 1)
+	assert.Equal(t, 2, events[0].id)
+}
+
+func TestChatHandler_ResumeAfterDisconnect(t *testing.T) {
+	fake := &gatedChatClient{gate: make(chan struct{})}
+	h := NewChatHandler(fake, nil)
+
+	// The first connection drops after the first chunk
+	ctx, disconnect := context.WithCancel(context.Background())
+	body := strings.NewReader(`{"model":"m","messages":[{"role":"user","content":"hi"}]}`)
+	req := httptest.NewRequest(http.MethodPost, "/api/chat", body).WithContext(ctx)
+	rec := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		h.Stream(rec, req)
+	}()
+
+	id := activeChatID(t, h)
+	g, _ := h.getGeneration(context.Background(), id)
+	assert.Eventually(t, func() bool {
+		events, _, _ := g.since(0)
+		return len(events) == 2
+	}, time.Second, time.Millisecond)
+	disconnect()
+	<-done
+	assert.Contains(t, rec.Body.String(), "id: 1\nevent: start\n")
+	assert.Contains(t, rec.Body.String(), "id: 2\ndata: ")
+
+	// Generation continues while no client is connected
+	close(fake.gate)
+
+	resumed := httptest.NewRecorder()
+	h.Resume(resumed, resumeRequest(id, "2"))
+
+	assert.NotContains(t, resumed.Body.String(), "event: start")
+	assert.NotContains(t, resumed.Body.String(), `"content":"a"`)
+	assert.Contains(t, resumed.Body.String(), "id: 3\ndata: ")
+	assert.Contains(t, resumed.Body.String(), `"content":"b"`)
+}
+
+func TestChatHandler_ResumeUnknownID(t *testing.T) {
+	h := NewChatHandler(&gatedChatClient{}, nil)
+
+	rec := httptest.NewRecorder()
+	h.Resume(rec, resumeRequest("missing", ""))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestChatHandler_GracePeriodCancels(t *testing.T) {
+	fake := &gatedChatClient{gate: make(chan struct{})}
+	h := NewChatHandler(fake, nil)
+	h.SetResumeGrace(20 * time.Millisecond)
+
+	ctx, disconnect := context.WithCancel(context.Background())
+	body := strings.NewReader(`{"model":"m","messages":[{"role":"user","content":"hi"}]}`)
+	req := httptest.NewRequest(http.MethodPost, "/api/chat", body).WithContext(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		h.Stream(httptest.NewRecorder(), req)
+	}()
+
+	// Disconnect once the reply has started
+	id := activeChatID(t, h)
+	g, _ := h.getGeneration(context.Background(), id)
+	assert.Eventually(t, func() bool {
+		events, _, _ := g.since(0)
+		return len(events) == 2
+	}, time.Second, time.Millisecond)
+	disconnect()
+	<-done
+
+	assert.False(t, g.finished(), "the chat waits for the client to resume")
+	assert.Eventually(t, g.finished, time.Second, time.Millisecond)
+
+	events, _, _ := g.since(0)
+	assert.Contains(t, string(events[len(events)-1].block), "context cancelled")
+}
+
+// stalledChatClient never replies, like a backend still reading a long prompt
+type stalledChatClient struct{}
+
+func (f *stalledChatClient) ChatStream(ctx context.Context, req client.ChatRequest) (<-chan client.ChatResponse, error) {
+	ch := make(chan client.ChatResponse)
+	go func() {
+		defer close(ch)
+		<-ctx.Done()
+	}()
+	return ch, nil
+}
+
+func TestChatHandler_DisconnectBeforeReplyWaits(t *testing.T) {
+	tests := []struct {
+		name   string
+		client func(t *testing.T) ChatClientInterface
+	}{
+		{name: "reading the prompt", client: func(t *testing.T) ChatClientInterface {
+			return &stalledChatClient{}
+		}},
+		{name: "queued", client: func(t *testing.T) ChatClientInterface {
+			// Another chat holds the only slot
+			s, _ := NewScheduler(&stalledChatClient{}, 1)
+			busy, stop := context.WithCancel(context.Background())
+			t.Cleanup(stop)
+			s.ChatStream(busy, client.ChatRequest{Model: "m"})
+			return s
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewChatHandler(tt.client(t), nil)
+			h.SetResumeGrace(50 * time.Millisecond)
+
+			ctx, disconnect := context.WithCancel(context.Background())
+			body := strings.NewReader(`{"model":"m","messages":[{"role":"user","content":"hi"}]}`)
+			req := httptest.NewRequest(http.MethodPost, "/api/chat", body).WithContext(ctx)
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				h.Stream(httptest.NewRecorder(), req)
+			}()
+
+			id := activeChatID(t, h)
+			g, _ := h.getGeneration(context.Background(), id)
+			disconnect()
+			<-done
+
+			// A phone that drops its connection while waiting can still resume
+			assert.False(t, g.finished(), "the chat waits for the client to resume")
+			assert.Eventually(t, g.finished, time.Second, time.Millisecond)
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/aristath/gollama-ui/internal/client"
+)
+
+// feedsRecheckInterval is how long the news feeds are considered healthy
+// after they were fetched. Fetching them downloads every feed, so they are
+// checked less often than other services while they work.
+const feedsRecheckInterval = 10 * time.Minute
+
+// Readiness states reported by GET /readyz
+const (
+	ReadinessReady       = "ready"       // Everything works
+	ReadinessDegraded    = "degraded"    // Chats work, but some tools or backends don't
+	ReadinessUnavailable = "unavailable" // Chats can't be served
+)
+
+// DirChecker checks that a directory, such as the config directory, is
+// writable
+type DirChecker string
+
+// HealthCheck creates and removes a temporary file in the directory
+func (d DirChecker) HealthCheck(ctx context.Context) error {
+	f, err := os.CreateTemp(string(d), ".health-*")
+	if err != nil {
+		return err
+	}
+	name := f.Name()
+	if err := f.Close(); err != nil {
+		os.Remove(name)
+		return err
+	}
+	return os.Remove(name)
+}
+
+// NewsFetcher fetches articles from the news feeds
+type NewsFetcher interface {
+	FetchNews(ctx context.Context, topic string, maxArticles int) ([]client.Article, error)
+}
+
+// FeedsChecker checks that the news feeds can be read
+type FeedsChecker struct {
+	news NewsFetcher
+
+	mu     sync.Mutex
+	passed time.Time // When the last successful check was
+}
+
+// NewFeedsChecker creates a checker of the feeds read by news
+func NewFeedsChecker(news NewsFetcher) *FeedsChecker {
+	return &FeedsChecker{news: news}
+}
+
+// HealthCheck fetches one article, unless the feeds were read successfully
+// recently
+func (c *FeedsChecker) HealthCheck(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.passed.IsZero() && time.Since(c.passed) < feedsRecheckInterval {
+		return nil
+	}
+
+	articles, err := c.news.FetchNews(ctx, "world", 1)
+	if err != nil {
+		return err
+	}
+	if len(articles) == 0 {
+		return errors.New("no articles in the feeds")
+	}
+	c.passed = time.Now()
+	return nil
+}
+
+// BackendStatusInterface reports the monitored state of the backends
+type BackendStatusInterface interface {
+	Statuses() []client.BackendStatus
+}
+
+// Readiness is the body of GET /readyz
+type Readiness struct {
+	Status   string                 `json:"status"`
+	Problems []string               `json:"problems,omitempty"`
+	Backends []client.BackendStatus `json:"backends"`
+	Services []ServiceStatus        `json:"services"`
+	Tools    []ToolStatus           `json:"tools,omitempty"`
+}
+
+// HealthHandler serves the liveness and readiness probes
+type HealthHandler struct {
+	backends BackendStatusInterface
+	services *ServiceMonitor
+	tools    *ToolExecutor
+	required map[string]bool
+}
+
+// NewHealthHandler creates a health handler reporting the state of backends
+// and of the services checked by services
+func NewHealthHandler(backends BackendStatusInterface, services *ServiceMonitor) *HealthHandler {
+	return &HealthHandler{
+		backends: backends,
+		services: services,
+		required: make(map[string]bool),
+	}
+}
+
+// SetToolExecutor sets the executor whose tools are reported
+func (h *HealthHandler) SetToolExecutor(e *ToolExecutor) {
+	h.tools = e
+}
+
+// SetRequiredServices sets the services without which the server is not
+// ready. Other services only disable the tools that need them.
+func (h *HealthHandler) SetRequiredServices(names ...string) {
+	for _, name := range names {
+		h.required[name] = true
+	}
+}
+
+// Live handles GET /healthz. The server is alive as long as it responds.
+func (h *HealthHandler) Live(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// Ready handles GET /readyz, reporting every dependency. It responds 503
+// when no backend is ready or a required service is down, so chats can't be
+// served.
+func (h *HealthHandler) Ready(w http.ResponseWriter, r *http.Request) {
+	readiness := h.readiness()
+
+	status := http.StatusOK
+	if readiness.Status == ReadinessUnavailable {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, readiness)
+}
+
+// readiness collects the state of every dependency
+func (h *HealthHandler) readiness() Readiness {
+	r := Readiness{
+		Status:   ReadinessReady,
+		Backends: h.backends.Statuses(),
+		Services: h.services.Statuses(),
+	}
+	if h.tools != nil {
+		r.Tools = h.tools.ToolStatuses("")
+	}
+
+	unavailable := false
+	ready := 0
+	for _, b := range r.Backends {
+		if b.State == client.StateReady {
+			ready++
+		} else {
+			r.Problems = append(r.Problems, fmt.Sprintf("backend %s is %s", b.Name, b.State))
+		}
+	}
+	if ready == 0 {
+		unavailable = true
+	}
+
+	checked := make(map[string]bool)
+	for _, s := range r.Services {
+		checked[s.Name] = true
+		if s.Healthy {
+			continue
+		}
+		r.Problems = append(r.Problems, fmt.Sprintf("%s is unavailable: %s", s.Name, s.Error))
+		if h.required[s.Name] {
+			unavailable = true
+		}
+	}
+	for name := range h.required {
+		if !checked[name] {
+			r.Problems = append(r.Problems, fmt.Sprintf("%s has not been checked yet", name))
+			unavailable = true
+		}
+	}
+
+	switch {
+	case unavailable:
+		r.Status = ReadinessUnavailable
+	case len(r.Problems) > 0:
+		r.Status = ReadinessDegraded
+	}
+	return r
+}
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aristath/gollama-ui/internal/client"
+)
+
+// fakeNews returns articles until err is set, counting fetches
+type fakeNews struct {
+	err     error
+	fetches int
+}
+
+func (f *fakeNews) FetchNews(ctx context.Context, topic string, maxArticles int) ([]client.Article, error) {
+	f.fetches++
+	if f.err != nil {
+		return nil, f.err
+	}
+	return []client.Article{{Title: "Headline"}}, nil
+}
+
+func TestHealthHandler_Live(t *testing.T) {
+	h := NewHealthHandler(&fakeBackendHealth{}, NewServiceMonitor(nil))
+
+	rec := httptest.NewRecorder()
+	h.Live(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"status":"ok"}`, rec.Body.String())
+}
+
+func TestHealthHandler_Ready(t *testing.T) {
+	ready := []client.BackendStatus{{Name: "default", State: client.StateReady}}
+	down := errors.New("connection refused")
+
+	tests := []struct {
+		name      string
+		backends  []client.BackendStatus
+		ddgs      error
+		configDir error
+		unchecked bool
+		code      int
+		status    string
+		problem   string
+	}{
+		{name: "ready", backends: ready, code: http.StatusOK, status: ReadinessReady},
+		{name: "tool service down", backends: ready, ddgs: down, code: http.StatusOK, status: ReadinessDegraded, problem: "ddgs is unavailable: connection refused"},
+		{name: "no backend ready", backends: []client.BackendStatus{{Name: "default", State: client.StateLoading}}, code: http.StatusServiceUnavailable, status: ReadinessUnavailable, problem: "backend default is loading"},
+		{name: "config dir not writable", backends: ready, configDir: down, code: http.StatusServiceUnavailable, status: ReadinessUnavailable, problem: "config_dir is unavailable: connection refused"},
+		{name: "not checked yet", backends: ready, unchecked: true, code: http.StatusServiceUnavailable, status: ReadinessUnavailable, problem: "config_dir has not been checked yet"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			services := NewServiceMonitor(map[string]ServiceChecker{
+				ServiceDDGS:      &fakeService{err: tt.ddgs},
+				ServiceConfigDir: &fakeService{err: tt.configDir},
+			})
+			if !tt.unchecked {
+				services.CheckAll(context.Background())
+			}
+
+			settings := NewToolSettings("")
+			settings.EnableWebSearch = true
+			executor := NewToolExecutor(nil, nil, nil, settings)
+			executor.SetServiceMonitor(services)
+			h := NewHealthHandler(&fakeBackendHealth{statuses: tt.backends}, services)
+			h.SetToolExecutor(executor)
+			h.SetRequiredServices(ServiceConfigDir)
+
+			rec := httptest.NewRecorder()
+			h.Ready(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+			assert.Equal(t, tt.code, rec.Code)
+
+			var body Readiness
+			if !assert.NoError(t, json.NewDecoder(rec.Body).Decode(&body)) {
+				return
+			}
+			assert.Equal(t, tt.status, body.Status)
+			if tt.problem != "" {
+				assert.Contains(t, body.Problems, tt.problem)
+			} else {
+				assert.Empty(t, body.Problems)
+			}
+			assert.Len(t, body.Tools, 3)
+			assert.Equal(t, tt.ddgs == nil || tt.unchecked, body.Tools[0].Available)
+		})
+	}
+}
+
+func TestToolExecutor_DisablesToolsOfDownServices(t *testing.T) {
+	ddgs := &fakeService{err: errors.New("connection refused")}
+	services := NewServiceMonitor(map[string]ServiceChecker{
+		ServiceDDGS:     ddgs,
+		ServiceSentinel: &fakeService{},
+	})
+	services.CheckAll(context.Background())
+
+	global := createTestToolSettings(true, false, true)
+	defer cleanupTestSettings(global)
+	executor := NewToolExecutor(nil, nil, nil, global)
+	executor.SetServiceMonitor(services)
+
+	names := func(tools []client.Tool) []string {
+		var result []string
+		for _, tool := range tools {
+			result = append(result, tool.Function.Name)
+		}
+		return result
+	}
+	assert.Equal(t, []string{"analyze_portfolio"}, names(executor.GetAvailableTools()))
+	assert.Equal(t, []string{"analyze_portfolio"}, names(executor.GetAvailableToolsByName("", []string{"web_search", "analyze_portfolio"})))
+
+	statuses := executor.ToolStatuses("")
+	assert.Equal(t, ToolStatus{Name: "web_search", Enabled: true, Reason: "ddgs is unavailable: connection refused"}, statuses[0])
+	assert.Equal(t, ToolStatus{Name: "get_news", Available: true}, statuses[1])
+
+	// Calls fail at once instead of waiting for the service
+	_, err := executor.ExecuteToolCall(context.Background(), "web_search", `{"query":"go"}`)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "tool disabled, ddgs is unavailable")
+	}
+
+	// The settings API reports why
+	h := NewUserSettingsHandler(NewUserToolSettings(t.TempDir(), global))
+	h.SetToolExecutor(executor)
+	rec := httptest.NewRecorder()
+	h.GetTools(rec, httptest.NewRequest(http.MethodGet, "/api/me/tools", nil))
+	var body struct {
+		Tools []ToolStatus `json:"tools"`
+	}
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&body))
+	assert.Equal(t, statuses, body.Tools)
+
+	// Tools come back with their service
+	ddgs.err = nil
+	services.CheckAll(context.Background())
+	assert.Equal(t, []string{"web_search", "analyze_portfolio"}, names(executor.GetAvailableTools()))
+}
+
+func TestFeedsChecker(t *testing.T) {
+	news := &fakeNews{err: errors.New("feeds unreachable")}
+	c := NewFeedsChecker(news)
+
+	// Failures are checked again every time
+	assert.Error(t, c.HealthCheck(context.Background()))
+	assert.Error(t, c.HealthCheck(context.Background()))
+	assert.Equal(t, 2, news.fetches)
+
+	// Working feeds aren't downloaded on every check
+	news.err = nil
+	assert.NoError(t, c.HealthCheck(context.Background()))
+	assert.NoError(t, c.HealthCheck(context.Background()))
+	assert.Equal(t, 3, news.fetches)
+}
+
+func TestDirChecker(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, DirChecker(dir).HealthCheck(context.Background()))
+
+	matches, _ := filepath.Glob(filepath.Join(dir, "*"))
+	assert.Empty(t, matches, "the test file is removed")
+
+	assert.Error(t, DirChecker(filepath.Join(dir, "missing")).HealthCheck(context.Background()))
+}
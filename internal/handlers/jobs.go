@@ -0,0 +1,356 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/aristath/gollama-ui/internal/client"
+)
+
+// Job statuses
+const (
+	JobRunning   = "running"
+	JobCompleted = "completed"
+	JobFailed    = "failed"
+	JobCancelled = "cancelled"
+)
+
+// Notification formats
+const (
+	NotifyFormatJSON = "json" // POST the job as JSON
+	NotifyFormatNtfy = "ntfy" // POST a plain-text message with a Title header, as ntfy expects
+)
+
+// maxJobs bounds the number of jobs kept; the oldest finished jobs are dropped first
+const maxJobs = 200
+
+// notifyTimeout bounds a completion notification
+const notifyTimeout = 10 * time.Second
+
+// ntfyPreviewLength is the number of characters of the result sent to ntfy
+const ntfyPreviewLength = 280
+
+// notifyClient posts completion notifications. Redirects aren't followed, so
+// a notification can't be sent on to a host that isn't allowed.
+var notifyClient = &http.Client{
+	Timeout: notifyTimeout,
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// Job is a detached generation that runs without a connected client
+type Job struct {
+	ID             string          `json:"id"`
+	User           string          `json:"user,omitempty"`
+	Status         string          `json:"status"`
+	Model          string          `json:"model"`
+	ConversationID string          `json:"conversation_id"`
+	Content        string          `json:"content,omitempty"`
+	Error          string          `json:"error,omitempty"`
+	Usage          *client.Usage   `json:"usage,omitempty"`
+	Timings        *client.Timings `json:"timings,omitempty"`
+	NotifyURL      string          `json:"notify_url,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	FinishedAt     *time.Time      `json:"finished_at,omitempty"`
+}
+
+// JobStore keeps recent jobs in memory
+type JobStore struct {
+	mu   sync.Mutex
+	jobs map[string]*Job
+}
+
+// NewJobStore creates an empty job store
+func NewJobStore() *JobStore {
+	return &JobStore{
+		jobs: make(map[string]*Job),
+	}
+}
+
+// Add registers a running job
+func (s *JobStore) Add(job Job) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.jobs) >= maxJobs {
+		s.pruneLocked()
+	}
+	s.jobs[job.ID] = &job
+}
+
+// Get returns a copy of a job
+func (s *JobStore) Get(id string) (Job, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return *job, true
+}
+
+// List returns all jobs, newest first
+func (s *JobStore) List() []Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := make([]Job, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		result = append(result, *job)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.After(result[j].CreatedAt)
+	})
+	return result
+}
+
+// finish records the outcome of a job and returns a copy of it
+func (s *JobStore) finish(id, status, content, errMsg string, usage *client.Usage, timings *client.Timings) Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job := s.jobs[id]
+	now := time.Now()
+	job.Status = status
+	job.Content = content
+	job.Error = errMsg
+	job.Usage = usage
+	job.Timings = timings
+	job.FinishedAt = &now
+	return *job
+}
+
+// pruneLocked drops the oldest finished job. s.mu must be held.
+func (s *JobStore) pruneLocked() {
+	var oldest *Job
+	for _, job := range s.jobs {
+		if job.Status == JobRunning {
+			continue
+		}
+		if oldest == nil || job.CreatedAt.Before(oldest.CreatedAt) {
+			oldest = job
+		}
+	}
+	if oldest != nil {
+		delete(s.jobs, oldest.ID)
+	}
+}
+
+// SetConversationStore sets the store that detached generations write their
+// results to
+func (h *ChatHandler) SetConversationStore(store *ConversationStore) {
+	h.conversations = store
+}
+
+// SetNotifier sets the default URL and format of job completion notifications
+// and the hosts, besides the default URL's, that requests may send their own
+// notify_url to. Without allowed hosts, requests can only use the default URL.
+func (h *ChatHandler) SetNotifier(notifyURL, format string, allowedHosts ...string) error {
+	if format != NotifyFormatJSON && format != NotifyFormatNtfy {
+		return fmt.Errorf("unknown notification format: %s", format)
+	}
+	if notifyURL != "" {
+		if err := validateNotifyURL(notifyURL); err != nil {
+			return err
+		}
+	}
+	h.notifyURL = notifyURL
+	h.notifyFormat = format
+	h.notifyHosts = make(map[string]bool)
+	for _, host := range allowedHosts {
+		h.notifyHosts[strings.ToLower(host)] = true
+	}
+	return nil
+}
+
+// checkNotifyURL checks that a request may be notified at notifyURL. The
+// server posts the chat's content there, so only the configured URL and
+// allowed hosts are accepted.
+func (h *ChatHandler) checkNotifyURL(notifyURL string) error {
+	if err := validateNotifyURL(notifyURL); err != nil {
+		return err
+	}
+	if notifyURL == h.notifyURL {
+		return nil
+	}
+	u, _ := url.Parse(notifyURL)
+	if !h.notifyHosts[strings.ToLower(u.Host)] && !h.notifyHosts[strings.ToLower(u.Hostname())] {
+		return fmt.Errorf("notify_url host is not allowed: %s", u.Host)
+	}
+	return nil
+}
+
+// startJob runs a detached generation and records it as a job. history is
+// the conversation as the client sent it.
+func (h *ChatHandler) startJob(ctx context.Context, g *generation, req *ChatStreamRequest, history []client.ChatMessage) Job {
+	conversationID := req.ConversationID
+	if conversationID == "" {
+		conversationID = g.id
+	}
+	notifyURL := req.NotifyURL
+	if notifyURL == "" {
+		notifyURL = h.notifyURL
+	}
+
+	job := Job{
+		ID:             g.id,
+		User:           g.owner,
+		Status:         JobRunning,
+		Model:          req.Model,
+		ConversationID: conversationID,
+		NotifyURL:      notifyURL,
+		CreatedAt:      time.Now(),
+	}
+	h.jobs.Add(job)
+	g.runDetached()
+
+	go func() {
+		defer h.running.Done()
+
+		content, completed := h.generate(ctx, g, req)
+
+		status, errMsg := JobCompleted, ""
+		if !completed {
+			switch ctx.Err() {
+			case context.Canceled:
+				status, errMsg = JobCancelled, "cancelled"
+			case context.DeadlineExceeded:
+				status, errMsg = JobFailed, "timed out"
+			default:
+				status, errMsg = JobFailed, g.lastError()
+			}
+		}
+		h.endGeneration(g)
+
+		// Keep what was generated when the server shuts down
+		partial := !completed && h.isShuttingDown()
+		if partial {
+			content = g.partialContent()
+			errMsg = "server shut down"
+		}
+
+		if completed || (partial && content != "") {
+			h.saveReply(ctx, g, conversationID, history, content)
+		}
+
+		usage, timings := g.stats()
+		finished := h.jobs.finish(g.id, status, content, errMsg, usage, timings)
+		if finished.NotifyURL != "" {
+			if err := h.notify(finished); err != nil {
+				slog.WarnContext(ctx, "Failed to send job notification", "job", g.id, "error", err)
+			}
+		}
+	}()
+
+	return job
+}
+
+// saveReply saves history and the assistant's reply to a conversation
+func (h *ChatHandler) saveReply(ctx context.Context, g *generation, conversationID string, history []client.ChatMessage, content string) {
+	if h.conversations == nil {
+		return
+	}
+
+	messages := append(history, client.ChatMessage{Role: "assistant", Content: content})
+	if err := h.conversations.ForContext(ctx).Update(conversationID, func(conv *Conversation) {
+		conv.Messages = messages
+	}); err != nil {
+		slog.ErrorContext(ctx, "Failed to save chat reply", "chat", g.id, "error", err)
+	}
+}
+
+// notify posts a job completion notification
+func (h *ChatHandler) notify(job Job) error {
+	var body []byte
+	contentType := "application/json"
+	title := fmt.Sprintf("Chat %s %s", job.ID, job.Status)
+
+	if h.notifyFormat == NotifyFormatNtfy {
+		message := job.Content
+		if job.Error != "" {
+			message = job.Error
+		}
+		if runes := []rune(message); len(runes) > ntfyPreviewLength {
+			message = string(runes[:ntfyPreviewLength]) + "…"
+		}
+		body = []byte(message)
+		contentType = "text/plain; charset=utf-8"
+	} else {
+		data, err := json.Marshal(job)
+		if err != nil {
+			return err
+		}
+		body = data
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", job.NotifyURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if h.notifyFormat == NotifyFormatNtfy {
+		req.Header.Set("Title", title)
+	}
+
+	resp, err := notifyClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// validateNotifyURL checks that a notification URL is an absolute HTTP(S) URL
+func validateNotifyURL(notifyURL string) error {
+	u, err := url.Parse(notifyURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid notify_url: %s", notifyURL)
+	}
+	return nil
+}
+
+// ListJobs handles GET /api/jobs, listing the jobs of the client's user, or
+// every job for admins
+func (h *ChatHandler) ListJobs(w http.ResponseWriter, r *http.Request) {
+	jobs := []Job{}
+	for _, job := range h.jobs.List() {
+		if canAccess(r.Context(), job.User) {
+			jobs = append(jobs, job)
+		}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"jobs": jobs,
+	})
+}
+
+// GetJob handles GET /api/jobs/{id}
+func (h *ChatHandler) GetJob(w http.ResponseWriter, r *http.Request) {
+	job, ok := h.jobs.Get(chi.URLParam(r, "id"))
+	if !ok || !canAccess(r.Context(), job.User) {
+		http.Error(w, "job not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, job)
+}
@@ -0,0 +1,283 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aristath/gollama-ui/internal/client"
+)
+
+// waitForJob waits until a job has finished and returns it
+func waitForJob(t *testing.T, h *ChatHandler, id string) Job {
+	t.Helper()
+	var job Job
+	assert.Eventually(t, func() bool {
+		job, _ = h.jobs.Get(id)
+		return job.Status != JobRunning
+	}, 2*time.Second, 5*time.Millisecond)
+	return job
+}
+
+func TestChatHandler_DetachedJob(t *testing.T) {
+	notified := make(chan Job, 1)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var job Job
+		json.NewDecoder(r.Body).Decode(&job)
+		notified <- job
+	}))
+	defer hook.Close()
+
+	fake := &gatedChatClient{gate: make(chan struct{})}
+	close(fake.gate)
+	store := NewConversationStore(t.TempDir())
+
+	h := NewChatHandler(fake, nil)
+	h.SetConversationStore(store)
+	assert.NoError(t, h.SetNotifier(hook.URL, NotifyFormatJSON))
+
+	rec := httptest.NewRecorder()
+	body := strings.NewReader(`{"model":"m","conversation_id":"conv1","detach":true,"messages":[{"role":"user","content":"hi"}]}`)
+	h.Stream(rec, httptest.NewRequest(http.MethodPost, "/api/chat", body))
+
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	var started Job
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&started))
+	assert.Equal(t, JobRunning, started.Status)
+	assert.Equal(t, "conv1", started.ConversationID)
+
+	job := waitForJob(t, h, started.ID)
+	assert.Equal(t, JobCompleted, job.Status)
+	assert.Equal(t, "ab", job.Content)
+	assert.NotNil(t, job.FinishedAt)
+
+	conv, err := store.Get("conv1")
+	assert.NoError(t, err)
+	assert.Len(t, conv.Messages, 2)
+	assert.Equal(t, "hi", conv.Messages[0].Content)
+	assert.Equal(t, "assistant", conv.Messages[1].Role)
+	assert.Equal(t, "ab", conv.Messages[1].Content)
+
+	select {
+	case n := <-notified:
+		assert.Equal(t, started.ID, n.ID)
+		assert.Equal(t, JobCompleted, n.Status)
+	case <-time.After(2 * time.Second):
+		t.Fatal("notification was not sent")
+	}
+
+	listRec := httptest.NewRecorder()
+	h.ListJobs(listRec, httptest.NewRequest(http.MethodGet, "/api/jobs", nil))
+	assert.Contains(t, listRec.Body.String(), started.ID)
+}
+
+func TestChatHandler_DetachedJobCancelled(t *testing.T) {
+	h := NewChatHandler(&gatedChatClient{gate: make(chan struct{})}, nil)
+
+	rec := httptest.NewRecorder()
+	body := strings.NewReader(`{"model":"m","detach":true,"messages":[{"role":"user","content":"hi"}]}`)
+	h.Stream(rec, httptest.NewRequest(http.MethodPost, "/api/chat", body))
+
+	var started Job
+	json.NewDecoder(rec.Body).Decode(&started)
+
+	cancelRec := httptest.NewRecorder()
+	h.Cancel(cancelRec, cancelRequest(started.ID))
+	assert.Equal(t, http.StatusOK, cancelRec.Code)
+
+	job := waitForJob(t, h, started.ID)
+	assert.Equal(t, JobCancelled, job.Status)
+}
+
+func TestChatHandler_NtfyNotification(t *testing.T) {
+	received := make(chan *http.Request, 1)
+	bodies := make(chan string, 1)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		received <- r
+		bodies <- string(data)
+	}))
+	defer hook.Close()
+
+	h := NewChatHandler(&gatedChatClient{}, nil)
+	assert.NoError(t, h.SetNotifier("", NotifyFormatNtfy))
+
+	err := h.notify(Job{ID: "abc", Status: JobCompleted, Content: "The answer is 42", NotifyURL: hook.URL})
+	assert.NoError(t, err)
+
+	r := <-received
+	assert.Equal(t, "Chat abc completed", r.Header.Get("Title"))
+	assert.Equal(t, "The answer is 42", <-bodies)
+}
+
+func TestChatHandler_NotifyURLValidation(t *testing.T) {
+	h := NewChatHandler(&gatedChatClient{}, nil)
+
+	assert.Error(t, h.SetNotifier("ftp://example.com", NotifyFormatJSON))
+	assert.Error(t, h.SetNotifier("", "xml"))
+
+	rec := httptest.NewRecorder()
+	body := strings.NewReader(`{"model":"m","notify_url":"http://example.com","messages":[{"role":"user","content":"hi"}]}`)
+	h.Stream(rec, httptest.NewRequest(http.MethodPost, "/api/chat", body))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestChatHandler_NotifyURLAllowlist(t *testing.T) {
+	h := NewChatHandler(&gatedChatClient{}, nil)
+	assert.NoError(t, h.SetNotifier("https://ntfy.example/default", NotifyFormatNtfy, "hooks.example", "localhost:9000"))
+
+	tests := []struct {
+		url     string
+		allowed bool
+	}{
+		{"https://ntfy.example/default", true},
+		{"https://hooks.example/job", true},
+		{"http://HOOKS.example:8080/job", true},
+		{"http://localhost:9000/job", true},
+		{"https://ntfy.example/other", false},
+		{"http://localhost:8080/job", false},
+		{"http://169.254.169.254/latest/meta-data", false},
+		{"http://127.0.0.1/job", false},
+	}
+	for _, tt := range tests {
+		err := h.checkNotifyURL(tt.url)
+		assert.Equal(t, tt.allowed, err == nil, tt.url)
+	}
+
+	// Requests can't use any host without an allowlist
+	assert.NoError(t, h.SetNotifier("", NotifyFormatJSON))
+	assert.Error(t, h.checkNotifyURL("https://hooks.example/job"))
+}
+
+func TestChatHandler_NotifyDoesNotFollowRedirects(t *testing.T) {
+	followed := false
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		followed = true
+	}))
+	defer target.Close()
+	hook := httptest.NewServer(http.RedirectHandler(target.URL, http.StatusTemporaryRedirect))
+	defer hook.Close()
+
+	h := NewChatHandler(&gatedChatClient{}, nil)
+	err := h.notify(Job{ID: "abc", Status: JobCompleted, NotifyURL: hook.URL})
+	assert.Error(t, err)
+	assert.False(t, followed)
+}
+
+func TestChatHandler_DetachedJobOutlivesFollowers(t *testing.T) {
+	fake := &gatedChatClient{gate: make(chan struct{})}
+	h := NewChatHandler(fake, nil)
+	h.SetResumeGrace(20 * time.Millisecond)
+
+	rec := httptest.NewRecorder()
+	body := strings.NewReader(`{"model":"m","detach":true,"messages":[{"role":"user","content":"hi"}]}`)
+	h.Stream(rec, httptest.NewRequest(http.MethodPost, "/api/chat", body))
+
+	var started Job
+	json.NewDecoder(rec.Body).Decode(&started)
+
+	// A client follows the job for a while, then leaves
+	req := resumeRequest(started.ID, "")
+	ctx, disconnect := context.WithCancel(req.Context())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		h.Resume(httptest.NewRecorder(), req.WithContext(ctx))
+	}()
+	g, _ := h.getGeneration(context.Background(), started.ID)
+	assert.Eventually(t, func() bool {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		return g.followers == 1
+	}, time.Second, time.Millisecond)
+	disconnect()
+	<-done
+
+	// The job keeps running past the grace period
+	time.Sleep(60 * time.Millisecond)
+	close(fake.gate)
+
+	job := waitForJob(t, h, started.ID)
+	assert.Equal(t, JobCompleted, job.Status)
+	assert.Equal(t, "ab", job.Content)
+}
+
+// toolThenChatClient asks for a tool call, then streams the final responses
+type toolThenChatClient struct {
+	final []client.ChatResponse
+}
+
+func (f *toolThenChatClient) ChatStream(ctx context.Context, req client.ChatRequest) (<-chan client.ChatResponse, error) {
+	responses := f.final
+	if req.Messages[len(req.Messages)-1].Role != "tool" {
+		responses = []client.ChatResponse{{
+			Message: client.ChatMessage{ToolCalls: []client.ToolCall{{
+				ID:       "call1",
+				Type:     "function",
+				Function: client.FunctionCall{Name: "get_news", Arguments: `{}`},
+			}}},
+			Done:       true,
+			DoneReason: "tool_calls",
+		}}
+	}
+
+	ch := make(chan client.ChatResponse, len(responses))
+	for _, r := range responses {
+		ch <- r
+	}
+	close(ch)
+	return ch, nil
+}
+
+func TestChatHandler_DetachedJobFails(t *testing.T) {
+	tests := []struct {
+		name  string
+		final []client.ChatResponse
+		err   string
+	}{
+		{
+			name: "error on done",
+			final: []client.ChatResponse{
+				{Message: client.ChatMessage{Content: "partial"}},
+				{Done: true, Error: "model crashed"},
+			},
+			err: "model crashed",
+		},
+		{
+			name:  "stream ends early",
+			final: []client.ChatResponse{{Message: client.ChatMessage{Content: "partial"}}},
+			err:   "stream ended before the response was complete",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewConversationStore(t.TempDir())
+			h := NewChatHandler(&toolThenChatClient{final: tt.final}, NewToolExecutor(nil, nil, nil, NewToolSettings("")))
+			h.SetConversationStore(store)
+
+			rec := httptest.NewRecorder()
+			body := strings.NewReader(`{"model":"m","conversation_id":"conv1","detach":true,"messages":[{"role":"user","content":"hi"}]}`)
+			h.Stream(rec, httptest.NewRequest(http.MethodPost, "/api/chat", body))
+
+			var started Job
+			json.NewDecoder(rec.Body).Decode(&started)
+
+			job := waitForJob(t, h, started.ID)
+			assert.Equal(t, JobFailed, job.Status)
+			assert.Equal(t, tt.err, job.Error)
+			assert.Empty(t, job.Content)
+
+			conv, err := store.Get("conv1")
+			assert.NoError(t, err)
+			assert.Nil(t, conv, "failed jobs aren't saved")
+		})
+	}
+}
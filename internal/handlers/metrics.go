@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/aristath/gollama-ui/internal/client"
+	"github.com/aristath/gollama-ui/internal/metrics"
+)
+
+// Histogram buckets, sized for small hardware where responses take seconds
+var (
+	timeToFirstTokenBuckets = []float64{0.1, 0.25, 0.5, 1, 2, 5, 10, 20, 30, 60, 120, 300}
+	tokensPerSecondBuckets  = []float64{0.5, 1, 2, 3, 5, 7.5, 10, 15, 20, 30, 50, 100, 200}
+	toolDurationBuckets     = []float64{0.05, 0.1, 0.25, 0.5, 1, 2, 5, 10, 30, 60}
+)
+
+// backendStates are exported for every backend so each state has a series
+var backendStates = []string{client.StateUnknown, client.StateLoading, client.StateReady, client.StateDown}
+
+// Metrics holds the Prometheus instruments recorded by the handlers. A nil
+// *Metrics records nothing.
+type Metrics struct {
+	timeToFirstToken *metrics.HistogramVec
+	tokensPerSecond  *metrics.HistogramVec
+	activeStreams    *metrics.GaugeVec
+	toolCalls        *metrics.CounterVec
+	toolErrors       *metrics.CounterVec
+	toolDuration     *metrics.HistogramVec
+}
+
+// NewMetrics registers the chat and tool metrics
+func NewMetrics(reg *metrics.Registry) *Metrics {
+	return &Metrics{
+		timeToFirstToken: reg.NewHistogramVec("gollama_chat_time_to_first_token_seconds",
+			"Time from the backend accepting a request to its first token, excluding time queued.", timeToFirstTokenBuckets, "model"),
+		tokensPerSecond: reg.NewHistogramVec("gollama_chat_tokens_per_second",
+			"Generation speed of each response.", tokensPerSecondBuckets, "model"),
+		activeStreams: reg.NewGaugeVec("gollama_chat_active_streams",
+			"Chats currently generating, including detached ones."),
+		toolCalls: reg.NewCounterVec("gollama_tool_calls_total",
+			"Tool calls executed.", "tool"),
+		toolErrors: reg.NewCounterVec("gollama_tool_errors_total",
+			"Tool calls that failed.", "tool"),
+		toolDuration: reg.NewHistogramVec("gollama_tool_duration_seconds",
+			"Duration of tool calls.", toolDurationBuckets, "tool"),
+	}
+}
+
+// streamStarted counts a chat that started generating
+func (m *Metrics) streamStarted() {
+	if m != nil {
+		m.activeStreams.Add(1)
+	}
+}
+
+// streamEnded counts a chat that stopped generating
+func (m *Metrics) streamEnded() {
+	if m != nil {
+		m.activeStreams.Add(-1)
+	}
+}
+
+// observeToolCall records one tool call
+func (m *Metrics) observeToolCall(tool string, duration time.Duration, err error) {
+	if m == nil {
+		return
+	}
+	m.toolCalls.Inc(tool)
+	m.toolDuration.Observe(duration.Seconds(), tool)
+	if err != nil {
+		m.toolErrors.Inc(tool)
+	}
+}
+
+// responseTimer measures the latency and speed of one backend response
+type responseTimer struct {
+	metrics *Metrics
+	model   string
+	start   time.Time
+	first   time.Time
+}
+
+// startResponse starts timing a response of model
+func (m *Metrics) startResponse(model string) *responseTimer {
+	return &responseTimer{metrics: m, model: model, start: time.Now()}
+}
+
+// token records generated content, observing the time to the first token
+func (t *responseTimer) token() {
+	if t.metrics == nil || !t.first.IsZero() {
+		return
+	}
+	t.first = time.Now()
+	t.metrics.timeToFirstToken.Observe(t.first.Sub(t.start).Seconds(), t.model)
+}
+
+// done records the generation speed of a finished response. Backends that
+// report no timings are measured from the first token.
+func (t *responseTimer) done(response client.ChatResponse) {
+	if t.metrics == nil {
+		return
+	}
+
+	switch {
+	case response.Timings != nil && response.Timings.PredictedPerSecond > 0:
+		t.metrics.tokensPerSecond.Observe(response.Timings.PredictedPerSecond, t.model)
+	case response.Usage != nil && response.Usage.CompletionTokens > 1 && !t.first.IsZero():
+		// The first token arrives at t.first, so time the ones after it
+		elapsed := time.Since(t.first).Seconds()
+		if elapsed > 0 {
+			t.metrics.tokensPerSecond.Observe(float64(response.Usage.CompletionTokens-1)/elapsed, t.model)
+		}
+	}
+}
+
+// RegisterMetrics exports the queue depth and busy slots of the scheduler
+func (s *Scheduler) RegisterMetrics(reg *metrics.Registry) {
+	reg.NewGaugeFunc("gollama_chat_queue_depth", "Chats waiting for a generation slot.", nil,
+		func(emit func(float64, ...string)) {
+			emit(float64(s.QueueLength()))
+		})
+	reg.NewGaugeFunc("gollama_chat_slots_busy", "Generation slots in use.", nil,
+		func(emit func(float64, ...string)) {
+			s.mu.Lock()
+			active := s.active
+			s.mu.Unlock()
+			emit(float64(active))
+		})
+}
+
+// RegisterMetrics exports the health state of every backend, with one series
+// per state set to 1 for the current state
+func (h *BackendsHandler) RegisterMetrics(reg *metrics.Registry) {
+	reg.NewGaugeFunc("gollama_backend_state", "Health state of each backend.", []string{"backend", "state"},
+		func(emit func(float64, ...string)) {
+			for _, status := range h.router.Statuses() {
+				for _, state := range backendStates {
+					value := 0.0
+					if status.State == state {
+						value = 1
+					}
+					emit(value, status.Name, state)
+				}
+			}
+		})
+}
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aristath/gollama-ui/internal/client"
+	"github.com/aristath/gollama-ui/internal/metrics"
+)
+
+// fakeBackendHealth reports fixed backend statuses
+type fakeBackendHealth struct {
+	statuses []client.BackendStatus
+}
+
+func (f *fakeBackendHealth) Health(ctx context.Context) []client.BackendHealth { return nil }
+func (f *fakeBackendHealth) Statuses() []client.BackendStatus                  { return f.statuses }
+func (f *fakeBackendHealth) Subscribe() (<-chan client.BackendStatus, func()) {
+	return make(chan client.BackendStatus), func() {}
+}
+
+// fakeService fails its health check when err is set
+type fakeService struct {
+	err error
+}
+
+func (f *fakeService) HealthCheck(ctx context.Context) error { return f.err }
+
+func scrape(t *testing.T, reg *metrics.Registry) string {
+	t.Helper()
+	var b strings.Builder
+	assert.NoError(t, reg.Write(&b))
+	return b.String()
+}
+
+func TestChatHandler_RecordsMetrics(t *testing.T) {
+	reg := metrics.NewRegistry()
+	h := NewChatHandler(&timedChatClient{}, nil)
+	h.SetMetrics(NewMetrics(reg))
+
+	body := strings.NewReader(`{"model":"m","messages":[{"role":"user","content":"hi"}]}`)
+	h.Stream(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/api/chat", body))
+
+	out := scrape(t, reg)
+	assert.Contains(t, out, `gollama_chat_time_to_first_token_seconds_count{model="m"} 1`)
+	assert.Contains(t, out, `gollama_chat_tokens_per_second_count{model="m"} 1`)
+	assert.Contains(t, out, "gollama_chat_active_streams 0\n")
+}
+
+func TestResponseTimer_TokensPerSecond(t *testing.T) {
+	reg := metrics.NewRegistry()
+	m := NewMetrics(reg)
+
+	timer := m.startResponse("llama")
+	timer.token()
+	timer.done(client.ChatResponse{Done: true, Timings: &client.Timings{PredictedPerSecond: 7.5}})
+
+	// Without timings the speed is measured from the first token
+	timer = m.startResponse("gpt")
+	timer.token()
+	timer.first = timer.first.Add(-2 * time.Second)
+	timer.done(client.ChatResponse{Done: true, Usage: &client.Usage{CompletionTokens: 21}})
+
+	out := scrape(t, reg)
+	assert.Contains(t, out, `gollama_chat_tokens_per_second_bucket{model="llama",le="7.5"} 1`)
+	assert.Contains(t, out, `gollama_chat_tokens_per_second_bucket{model="gpt",le="7.5"} 0`)
+	assert.Contains(t, out, `gollama_chat_tokens_per_second_bucket{model="gpt",le="10"} 1`)
+
+	// A nil *Metrics records nothing
+	var none *Metrics
+	none.streamStarted()
+	none.observeToolCall("web_search", time.Second, nil)
+	timer = none.startResponse("m")
+	timer.token()
+	timer.done(client.ChatResponse{})
+}
+
+func TestToolExecutor_RecordsMetrics(t *testing.T) {
+	reg := metrics.NewRegistry()
+	executor := NewToolExecutor(nil, nil, nil, NewToolSettings(""))
+	executor.SetMetrics(NewMetrics(reg))
+
+	executor.ExecuteToolCall(context.Background(), "web_search", `{}`)
+	executor.ExecuteToolCall(context.Background(), "made_up_tool", `{}`)
+
+	out := scrape(t, reg)
+	assert.Contains(t, out, `gollama_tool_calls_total{tool="web_search"} 1`)
+	assert.Contains(t, out, `gollama_tool_errors_total{tool="web_search"} 1`)
+	assert.Contains(t, out, `gollama_tool_calls_total{tool="unknown"} 1`)
+	assert.NotContains(t, out, "made_up_tool")
+}
+
+func TestRegisterMetrics_Gauges(t *testing.T) {
+	reg := metrics.NewRegistry()
+
+	s, _ := NewScheduler(&blockingChatClient{}, 2)
+	s.RegisterMetrics(reg)
+
+	backends := NewBackendsHandler(&fakeBackendHealth{statuses: []client.BackendStatus{
+		{Name: "pi", State: client.StateReady},
+	}})
+	backends.RegisterMetrics(reg)
+
+	services := NewServiceMonitor(map[string]ServiceChecker{
+		"ddgs":     &fakeService{},
+		"sentinel": &fakeService{err: errors.New("connection refused")},
+	})
+	services.CheckAll(context.Background())
+	services.RegisterMetrics(reg)
+
+	out := scrape(t, reg)
+	assert.Contains(t, out, "gollama_chat_queue_depth 0\n")
+	assert.Contains(t, out, "gollama_chat_slots_busy 0\n")
+	assert.Contains(t, out, `gollama_backend_state{backend="pi",state="ready"} 1`)
+	assert.Contains(t, out, `gollama_backend_state{backend="pi",state="down"} 0`)
+	assert.Contains(t, out, `gollama_service_up{service="ddgs"} 1`)
+	assert.Contains(t, out, `gollama_service_up{service="sentinel"} 0`)
+}
@@ -0,0 +1,233 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/aristath/gollama-ui/internal/client"
+)
+
+// ModelPresets manages per-model default generation options
+type ModelPresets struct {
+	presets    map[string]client.Options
+	configPath string
+	mu         sync.RWMutex
+}
+
+// NewModelPresets creates a new model presets manager
+func NewModelPresets(configPath string) *ModelPresets {
+	mp := &ModelPresets{
+		presets:    make(map[string]client.Options),
+		configPath: configPath,
+	}
+
+	// Load existing presets from file if it exists
+	if err := mp.Load(); err != nil {
+		slog.Warn("Failed to load model presets", "path", configPath, "error", err)
+	}
+
+	return mp
+}
+
+// Load reads model presets from file
+func (mp *ModelPresets) Load() error {
+	if mp.configPath == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(mp.configPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil // File doesn't exist yet, no presets
+		}
+		return fmt.Errorf("failed to read model presets: %w", err)
+	}
+
+	presets := make(map[string]client.Options)
+	if err := json.Unmarshal(data, &presets); err != nil {
+		return fmt.Errorf("failed to parse model presets: %w", err)
+	}
+
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+	mp.presets = presets
+
+	return nil
+}
+
+// Save persists model presets to file
+func (mp *ModelPresets) Save() error {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+	return mp.saveLocked()
+}
+
+// saveLocked writes the presets file. mp.mu must be held, so concurrent
+// changes reach the file in the order they were made.
+func (mp *ModelPresets) saveLocked() error {
+	if mp.configPath == "" {
+		return fmt.Errorf("no config path set for saving presets")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(mp.configPath), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(mp.presets, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal presets: %w", err)
+	}
+
+	if err := os.WriteFile(mp.configPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write presets file: %w", err)
+	}
+
+	return nil
+}
+
+// All returns a copy of all presets
+func (mp *ModelPresets) All() map[string]client.Options {
+	mp.mu.RLock()
+	defer mp.mu.RUnlock()
+
+	result := make(map[string]client.Options, len(mp.presets))
+	for model, opts := range mp.presets {
+		result[model] = opts
+	}
+	return result
+}
+
+// Set stores the preset for a model
+func (mp *ModelPresets) Set(model string, opts client.Options) error {
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	mp.presets[model] = opts
+	return mp.saveLocked()
+}
+
+// Delete removes the preset for a model
+func (mp *ModelPresets) Delete(model string) error {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	delete(mp.presets, model)
+	return mp.saveLocked()
+}
+
+// Resolve returns the model's preset with the request's options layered on top
+func (mp *ModelPresets) Resolve(model string, override *client.Options) *client.Options {
+	mp.mu.RLock()
+	preset, ok := mp.presets[model]
+	mp.mu.RUnlock()
+
+	if !ok && override == nil {
+		return nil
+	}
+
+	merged := preset.Merge(override)
+	return &merged
+}
+
+// PresetsHandler handles model preset requests
+type PresetsHandler struct {
+	presets *ModelPresets
+}
+
+// NewPresetsHandler creates a new presets handler
+func NewPresetsHandler(presets *ModelPresets) *PresetsHandler {
+	return &PresetsHandler{
+		presets: presets,
+	}
+}
+
+// List handles GET /api/settings/presets
+func (h *PresetsHandler) List(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"presets": h.presets.All(),
+	}); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to encode response: %v", err), http.StatusInternalServerError)
+		return
+	}
+}
+
+// presetModel returns the model named by the rest of the request path, which
+// may contain slashes, as in backend/model
+func presetModel(r *http.Request) string {
+	model := chi.URLParam(r, "*")
+	if unescaped, err := url.PathUnescape(model); err == nil {
+		model = unescaped
+	}
+	return model
+}
+
+// Update handles PUT /api/settings/presets/{model}
+func (h *PresetsHandler) Update(w http.ResponseWriter, r *http.Request) {
+	model := presetModel(r)
+	if model == "" {
+		http.Error(w, "model name is required", http.StatusBadRequest)
+		return
+	}
+
+	var opts client.Options
+	if err := json.NewDecoder(r.Body).Decode(&opts); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if err := opts.Validate(); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid options: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.presets.Set(model, opts); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to save preset: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"model":   model,
+		"options": opts,
+	}); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to encode response: %v", err), http.StatusInternalServerError)
+		return
+	}
+}
+
+// Delete handles DELETE /api/settings/presets/{model}
+func (h *PresetsHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	model := presetModel(r)
+	if model == "" {
+		http.Error(w, "model name is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.presets.Delete(model); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to delete preset: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"model":   model,
+	}); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to encode response: %v", err), http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aristath/gollama-ui/internal/client"
+)
+
+func TestModelPresets_Resolve(t *testing.T) {
+	f := func(v float64) *float64 { return &v }
+	n := func(v int) *int { return &v }
+
+	presets := NewModelPresets(filepath.Join(t.TempDir(), "model-presets.json"))
+	assert.NoError(t, presets.Set("qwen", client.Options{Temperature: f(0.3), MaxTokens: n(512)}))
+
+	tests := []struct {
+		name    string
+		model   string
+		request *client.Options
+		want    *client.Options
+	}{
+		{name: "no preset, no options", model: "other", want: nil},
+		{name: "request only", model: "other", request: &client.Options{TopK: n(10)}, want: &client.Options{TopK: n(10)}},
+		{name: "preset only", model: "qwen", want: &client.Options{Temperature: f(0.3), MaxTokens: n(512)}},
+		{name: "request over preset", model: "qwen", request: &client.Options{Temperature: f(1.2)}, want: &client.Options{Temperature: f(1.2), MaxTokens: n(512)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, presets.Resolve(tt.model, tt.request))
+		})
+	}
+
+	// Presets survive a reload from disk
+	reloaded := NewModelPresets(presets.configPath)
+	assert.Equal(t, presets.All(), reloaded.All())
+}
+
+func TestModelPresets_SetRejectsInvalid(t *testing.T) {
+	presets := NewModelPresets(filepath.Join(t.TempDir(), "model-presets.json"))
+	temperature := 9.0
+
+	assert.Error(t, presets.Set("qwen", client.Options{Temperature: &temperature}))
+	assert.Empty(t, presets.All())
+}
+
+func TestModelPresets_ConcurrentSets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "model-presets.json")
+	presets := NewModelPresets(path)
+
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, presets.Set(fmt.Sprintf("model-%d", i), client.Options{}))
+		}()
+	}
+	wg.Wait()
+
+	// The last write holds every preset
+	reloaded := NewModelPresets(path)
+	assert.Len(t, reloaded.All(), 20)
+	assert.Equal(t, presets.All(), reloaded.All())
+}
+
+func TestPresetsHandler(t *testing.T) {
+	presets := NewModelPresets(filepath.Join(t.TempDir(), "model-presets.json"))
+	h := NewPresetsHandler(presets)
+
+	r := chi.NewRouter()
+	r.Get("/api/settings/presets", h.List)
+	r.Put("/api/settings/presets/*", h.Update)
+	r.Delete("/api/settings/presets/*", h.Delete)
+
+	serve := func(method, target, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return rec
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+		code   int
+	}{
+		{name: "plain name", method: http.MethodPut, target: "/api/settings/presets/qwen2.5:3b", body: `{"temperature":0.5}`, code: http.StatusOK},
+		{name: "backend prefix", method: http.MethodPut, target: "/api/settings/presets/desktop/qwen2.5-32b", body: `{"max_tokens":128}`, code: http.StatusOK},
+		{name: "escaped slash", method: http.MethodPut, target: "/api/settings/presets/pi%2Fllama", body: `{"top_k":20}`, code: http.StatusOK},
+		{name: "invalid options", method: http.MethodPut, target: "/api/settings/presets/qwen", body: `{"top_p":2}`, code: http.StatusBadRequest},
+		{name: "invalid body", method: http.MethodPut, target: "/api/settings/presets/qwen", body: `{`, code: http.StatusBadRequest},
+		{name: "no model", method: http.MethodPut, target: "/api/settings/presets/", body: `{}`, code: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(tt.method, tt.target, tt.body)
+			assert.Equal(t, tt.code, rec.Code, rec.Body.String())
+		})
+	}
+
+	all := presets.All()
+	assert.Len(t, all, 3)
+	assert.Equal(t, 128, *all["desktop/qwen2.5-32b"].MaxTokens)
+	assert.Equal(t, 20, *all["pi/llama"].TopK)
+
+	rec := serve(http.MethodDelete, "/api/settings/presets/desktop/qwen2.5-32b", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, presets.All(), "desktop/qwen2.5-32b")
+
+	rec = serve(http.MethodGet, "/api/settings/presets", "")
+	assert.Contains(t, rec.Body.String(), `"qwen2.5:3b"`)
+}
@@ -0,0 +1,363 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/aristath/gollama-ui/internal/client"
+)
+
+// Persona bundles a system prompt with default generation settings
+type Persona struct {
+	ID           string          `json:"id"`
+	Name         string          `json:"name"`
+	SystemPrompt string          `json:"system_prompt"`
+	Options      *client.Options `json:"options,omitempty"`
+	Model        string          `json:"model,omitempty"` // Preferred model, used when the request names none
+	Tools        []string        `json:"tools,omitempty"` // Tool names to offer, among those the tool settings enable; nil offers every enabled tool
+}
+
+// Validate checks that the persona is well formed
+func (p *Persona) Validate() error {
+	if strings.TrimSpace(p.Name) == "" {
+		return fmt.Errorf("name is required")
+	}
+	if p.Options != nil {
+		if err := p.Options.Validate(); err != nil {
+			return fmt.Errorf("invalid options: %w", err)
+		}
+	}
+	return nil
+}
+
+// Persona store errors, mapped to response statuses by the handlers
+var (
+	errInvalidPersona  = errors.New("invalid persona")
+	errPersonaNotFound = errors.New("persona not found")
+)
+
+// PersonaStore manages the personas library
+type PersonaStore struct {
+	personas   map[string]Persona
+	configPath string
+	mu         sync.RWMutex
+
+	usersDir string
+	usersMu  sync.Mutex
+	users    map[string]*PersonaStore
+}
+
+// NewPersonaStore creates a new persona store
+func NewPersonaStore(configPath string) *PersonaStore {
+	ps := &PersonaStore{
+		personas:   make(map[string]Persona),
+		configPath: configPath,
+	}
+
+	// Load existing personas from file if it exists
+	if err := ps.Load(); err != nil {
+		slog.Warn("Failed to load personas", "path", configPath, "error", err)
+	}
+
+	return ps
+}
+
+// SetUsersDir gives every authenticated user their own personas library in
+// <dir>/<user>/personas.json. Without it, or without authentication, all
+// clients share the library.
+func (ps *PersonaStore) SetUsersDir(dir string) {
+	ps.usersDir = dir
+}
+
+// ForContext returns the personas library of the user of ctx
+func (ps *PersonaStore) ForContext(ctx context.Context) *PersonaStore {
+	user := userFromContext(ctx)
+	if ps.usersDir == "" || user == "" {
+		return ps
+	}
+
+	ps.usersMu.Lock()
+	defer ps.usersMu.Unlock()
+
+	if ps.users == nil {
+		ps.users = make(map[string]*PersonaStore)
+	}
+	store, ok := ps.users[user]
+	if !ok {
+		store = NewPersonaStore(filepath.Join(ps.usersDir, user, "personas.json"))
+		ps.users[user] = store
+	}
+	return store
+}
+
+// Load reads personas from file
+func (ps *PersonaStore) Load() error {
+	if ps.configPath == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(ps.configPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil // File doesn't exist yet, no personas
+		}
+		return fmt.Errorf("failed to read personas: %w", err)
+	}
+
+	var personas []Persona
+	if err := json.Unmarshal(data, &personas); err != nil {
+		return fmt.Errorf("failed to parse personas: %w", err)
+	}
+
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	ps.personas = make(map[string]Persona, len(personas))
+	for _, p := range personas {
+		ps.personas[p.ID] = p
+	}
+
+	return nil
+}
+
+// Save persists personas to file
+func (ps *PersonaStore) Save() error {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	return ps.saveLocked()
+}
+
+// saveLocked writes the personas file. ps.mu must be held, so concurrent
+// changes reach the file in the order they were made.
+func (ps *PersonaStore) saveLocked() error {
+	if ps.configPath == "" {
+		return fmt.Errorf("no config path set for saving personas")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(ps.configPath), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(ps.list(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal personas: %w", err)
+	}
+
+	// Write a temporary file and rename it, so a crash never leaves half a file
+	tmp := ps.configPath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write personas file: %w", err)
+	}
+	if err := os.Rename(tmp, ps.configPath); err != nil {
+		return fmt.Errorf("failed to write personas file: %w", err)
+	}
+
+	return nil
+}
+
+// List returns all personas sorted by name
+func (ps *PersonaStore) List() []Persona {
+	ps.mu.RLock()
+	defer ps.mu.RUnlock()
+	return ps.list()
+}
+
+// list returns all personas sorted by name. ps.mu must be held.
+func (ps *PersonaStore) list() []Persona {
+	result := make([]Persona, 0, len(ps.personas))
+	for _, p := range ps.personas {
+		result = append(result, p)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// Get returns the persona with the given ID
+func (ps *PersonaStore) Get(id string) (Persona, bool) {
+	ps.mu.RLock()
+	defer ps.mu.RUnlock()
+	p, ok := ps.personas[id]
+	return p, ok
+}
+
+// Create adds a new persona and assigns it an ID
+func (ps *PersonaStore) Create(p Persona) (Persona, error) {
+	if err := p.Validate(); err != nil {
+		return Persona{}, fmt.Errorf("%w: %v", errInvalidPersona, err)
+	}
+
+	id, err := newID()
+	if err != nil {
+		return Persona{}, err
+	}
+	p.ID = id
+
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	ps.personas[p.ID] = p
+	return p, ps.saveLocked()
+}
+
+// Update replaces an existing persona
+func (ps *PersonaStore) Update(p Persona) error {
+	if err := p.Validate(); err != nil {
+		return fmt.Errorf("%w: %v", errInvalidPersona, err)
+	}
+
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	if _, ok := ps.personas[p.ID]; !ok {
+		return fmt.Errorf("%w: %s", errPersonaNotFound, p.ID)
+	}
+	ps.personas[p.ID] = p
+	return ps.saveLocked()
+}
+
+// Delete removes a persona
+func (ps *PersonaStore) Delete(id string) error {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	if _, ok := ps.personas[id]; !ok {
+		return fmt.Errorf("%w: %s", errPersonaNotFound, id)
+	}
+	delete(ps.personas, id)
+	return ps.saveLocked()
+}
+
+// ExpandTemplate replaces template variables such as {{date}} and {{model}}
+func ExpandTemplate(text, model string, now time.Time) string {
+	return strings.NewReplacer(
+		"{{date}}", now.Format("2006-01-02"),
+		"{{time}}", now.Format("15:04"),
+		"{{datetime}}", now.Format("2006-01-02 15:04"),
+		"{{weekday}}", now.Weekday().String(),
+		"{{model}}", model,
+	).Replace(text)
+}
+
+// newID returns a random identifier
+func newID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate id: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// PersonasHandler handles persona CRUD requests
+type PersonasHandler struct {
+	store *PersonaStore
+}
+
+// NewPersonasHandler creates a new personas handler
+func NewPersonasHandler(store *PersonaStore) *PersonasHandler {
+	return &PersonasHandler{
+		store: store,
+	}
+}
+
+// List handles GET /api/personas
+func (h *PersonasHandler) List(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"personas": h.store.ForContext(r.Context()).List(),
+	})
+}
+
+// Get handles GET /api/personas/{id}
+func (h *PersonasHandler) Get(w http.ResponseWriter, r *http.Request) {
+	p, ok := h.store.ForContext(r.Context()).Get(chi.URLParam(r, "id"))
+	if !ok {
+		http.Error(w, "persona not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, p)
+}
+
+// Create handles POST /api/personas
+func (h *PersonasHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var p Persona
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	created, err := h.store.ForContext(r.Context()).Create(p)
+	if err != nil {
+		writePersonaError(w, "Failed to save persona", err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, created)
+}
+
+// Update handles PUT /api/personas/{id}
+func (h *PersonasHandler) Update(w http.ResponseWriter, r *http.Request) {
+	var p Persona
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	p.ID = chi.URLParam(r, "id")
+
+	if err := h.store.ForContext(r.Context()).Update(p); err != nil {
+		writePersonaError(w, "Failed to save persona", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, p)
+}
+
+// Delete handles DELETE /api/personas/{id}
+func (h *PersonasHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if err := h.store.ForContext(r.Context()).Delete(id); err != nil {
+		writePersonaError(w, "Failed to delete persona", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"id":      id,
+	})
+}
+
+// writePersonaError responds to a failed persona store change: 400 for an
+// invalid persona, 404 for a missing one, and 500 when it couldn't be saved
+func writePersonaError(w http.ResponseWriter, message string, err error) {
+	switch {
+	case errors.Is(err, errInvalidPersona):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, errPersonaNotFound):
+		http.Error(w, "persona not found", http.StatusNotFound)
+	default:
+		http.Error(w, fmt.Sprintf("%s: %v", message, err), http.StatusInternalServerError)
+	}
+}
+
+// writeJSON writes payload as a JSON response with the given status. The
+// status is already sent when encoding fails, so the error is only logged.
+func writeJSON(w http.ResponseWriter, status int, payload interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(payload); err != nil {
+		slog.Error("Failed to encode response", "error", err)
+	}
+}
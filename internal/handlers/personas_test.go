@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aristath/gollama-ui/internal/client"
+)
+
+func TestExpandTemplate(t *testing.T) {
+	now := time.Date(2025, 3, 14, 9, 26, 0, 0, time.UTC)
+
+	result := ExpandTemplate("Today is {{date}} ({{weekday}}), you are {{model}}.", "qwen3", now)
+	assert.Equal(t, "Today is 2025-03-14 (Friday), you are qwen3.", result)
+}
+
+func TestApplyPersona(t *testing.T) {
+	temp := 0.2
+	topK := 20
+	persona := Persona{
+		Name:         "Analyst",
+		SystemPrompt: "You are an analyst using {{model}}.",
+		Model:        "qwen3",
+		Options:      &client.Options{Temperature: &temp, TopK: &topK},
+	}
+
+	override := 0.9
+	req := &client.ChatRequest{
+		Messages: []client.ChatMessage{{Role: "user", Content: "hi"}},
+		Options:  &client.Options{Temperature: &override},
+	}
+
+	applyPersona(req, persona, time.Now())
+
+	assert.Equal(t, "qwen3", req.Model)
+	assert.Len(t, req.Messages, 2)
+	assert.Equal(t, "system", req.Messages[0].Role)
+	assert.Equal(t, "You are an analyst using qwen3.", req.Messages[0].Content)
+	assert.Equal(t, 0.9, *req.Options.Temperature)
+	assert.Equal(t, 20, *req.Options.TopK)
+}
+
+func TestPersonaStore_CRUD(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "personas.json")
+	store := NewPersonaStore(path)
+
+	created, err := store.Create(Persona{Name: "Writer", SystemPrompt: "Write well."})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, created.ID)
+
+	_, err = store.Create(Persona{SystemPrompt: "No name"})
+	assert.Error(t, err)
+
+	created.SystemPrompt = "Write better."
+	assert.NoError(t, store.Update(created))
+
+	// Personas survive a reload from disk
+	reloaded := NewPersonaStore(path)
+	p, ok := reloaded.Get(created.ID)
+	assert.True(t, ok)
+	assert.Equal(t, "Write better.", p.SystemPrompt)
+
+	assert.NoError(t, reloaded.Delete(created.ID))
+	assert.Empty(t, reloaded.List())
+}
+
+func TestPersonaStore_ConcurrentCreates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "personas.json")
+	store := NewPersonaStore(path)
+
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := store.Create(Persona{Name: fmt.Sprintf("Persona %d", i)})
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	// The last write holds every persona
+	reloaded := NewPersonaStore(path)
+	assert.Len(t, reloaded.List(), 20)
+	assert.Equal(t, store.List(), reloaded.List())
+}
+
+func TestPersonasHandler_Errors(t *testing.T) {
+	store := NewPersonaStore(filepath.Join(t.TempDir(), "personas.json"))
+	existing, err := store.Create(Persona{Name: "Writer"})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	h := NewPersonasHandler(store)
+	r := chi.NewRouter()
+	r.Post("/api/personas", h.Create)
+	r.Put("/api/personas/{id}", h.Update)
+	r.Delete("/api/personas/{id}", h.Delete)
+
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return rec
+	}
+
+	rec := do(http.MethodPost, "/api/personas", `{"system_prompt":"No name"}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "invalid persona: name is required\n", rec.Body.String())
+
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPut, "/api/personas/"+existing.ID, `{"name":" "}`).Code)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodPut, "/api/personas/missing", `{"name":"Writer"}`).Code)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodDelete, "/api/personas/missing", "").Code)
+
+	assert.Equal(t, http.StatusOK, do(http.MethodPut, "/api/personas/"+existing.ID, `{"name":"Editor"}`).Code)
+	assert.Equal(t, http.StatusOK, do(http.MethodDelete, "/api/personas/"+existing.ID, "").Code)
+	assert.Empty(t, store.List())
+}
+
+// recordingChatClient answers at once and remembers the last request
+type recordingChatClient struct {
+	mu   sync.Mutex
+	last client.ChatRequest
+}
+
+func (f *recordingChatClient) ChatStream(ctx context.Context, req client.ChatRequest) (<-chan client.ChatResponse, error) {
+	f.mu.Lock()
+	f.last = req
+	f.mu.Unlock()
+
+	ch := make(chan client.ChatResponse, 1)
+	ch <- client.ChatResponse{Message: client.ChatMessage{Content: "ok"}, Done: true, DoneReason: "stop"}
+	close(ch)
+	return ch, nil
+}
+
+func TestChatHandler_PersonaToolsRespectSettings(t *testing.T) {
+	personas := NewPersonaStore(filepath.Join(t.TempDir(), "personas.json"))
+	persona, err := personas.Create(Persona{Name: "Analyst", SystemPrompt: "Analyze.", Tools: []string{"web_search", "analyze_portfolio"}})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// Sentinel is turned off globally
+	settings := NewToolSettings("")
+	settings.EnableWebSearch = true
+	fake := &recordingChatClient{}
+	h := NewChatHandler(fake, NewToolExecutor(nil, nil, nil, settings))
+	h.SetPersonaStore(personas)
+
+	body := strings.NewReader(`{"model":"m","persona_id":"` + persona.ID + `","messages":[{"role":"user","content":"hi"}]}`)
+	h.Stream(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/api/chat", body))
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	var names []string
+	for _, tool := range fake.last.Tools {
+		names = append(names, tool.Function.Name)
+	}
+	assert.Equal(t, []string{"web_search"}, names)
+}
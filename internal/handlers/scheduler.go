@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/aristath/gollama-ui/internal/client"
+)
+
+// Request priorities, lower values are served first
+const (
+	PriorityInteractive = 0 // Chats a user is waiting for
+	PriorityBackground  = 1 // Summaries and other background jobs
+)
+
+type priorityKey struct{}
+type queueListenerKey struct{}
+
+// WithPriority marks the chat requests made with ctx with a priority
+func WithPriority(ctx context.Context, priority int) context.Context {
+	return context.WithValue(ctx, priorityKey{}, priority)
+}
+
+// WithQueueListener registers a function called with the 1-based queue
+// position whenever a request made with ctx waits for a slot
+func WithQueueListener(ctx context.Context, listener func(position int)) context.Context {
+	return context.WithValue(ctx, queueListenerKey{}, listener)
+}
+
+// Scheduler limits the number of concurrent generations. Requests beyond the
+// available slots wait in a FIFO queue ordered by priority.
+type Scheduler struct {
+	client ChatClientInterface
+	slots  int
+
+	mu     sync.Mutex
+	active int
+	queue  []*queuedRequest
+}
+
+// queuedRequest is a request waiting for a slot
+type queuedRequest struct {
+	priority int
+	granted  chan struct{} // Closed when the request is given a slot
+	position chan int      // Receives the latest queue position
+}
+
+// NewScheduler creates a scheduler in front of client with the given number
+// of concurrent slots
+func NewScheduler(client ChatClientInterface, slots int) (*Scheduler, error) {
+	if slots < 1 {
+		return nil, fmt.Errorf("at least one slot is required, got %d", slots)
+	}
+
+	return &Scheduler{
+		client: client,
+		slots:  slots,
+	}, nil
+}
+
+// ChatStream waits for a free slot and starts the chat. The slot is held
+// until the response stream ends.
+func (s *Scheduler) ChatStream(ctx context.Context, req client.ChatRequest) (<-chan client.ChatResponse, error) {
+	priority, _ := ctx.Value(priorityKey{}).(int)
+	listener, _ := ctx.Value(queueListenerKey{}).(func(int))
+
+	release, err := s.acquire(ctx, priority, listener)
+	if err != nil {
+		return nil, err
+	}
+
+	upstream, err := s.client.ChatStream(ctx, req)
+	if err != nil {
+		release()
+		return nil, err
+	}
+
+	responseChan := make(chan client.ChatResponse, 10)
+	go func() {
+		defer release()
+		defer close(responseChan)
+
+		// Keep draining after cancellation so the slot is only freed once
+		// the backend has stopped generating
+		for resp := range upstream {
+			select {
+			case responseChan <- resp:
+			case <-ctx.Done():
+			}
+		}
+	}()
+
+	return responseChan, nil
+}
+
+// ModelState reports the state of the backend serving model when the
+// scheduled client tracks backend readiness
+func (s *Scheduler) ModelState(model string) string {
+	if bs, ok := s.client.(BackendStateInterface); ok {
+		return bs.ModelState(model)
+	}
+	return client.StateUnknown
+}
+
+// QueueLength returns the number of requests waiting for a slot
+func (s *Scheduler) QueueLength() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.queue)
+}
+
+// acquire waits for a slot and returns the function releasing it. A request
+// whose context is cancelled while queued is removed from the queue.
+func (s *Scheduler) acquire(ctx context.Context, priority int, listener func(int)) (func(), error) {
+	s.mu.Lock()
+	if s.active < s.slots && len(s.queue) == 0 {
+		s.active++
+		s.mu.Unlock()
+		return s.releaseFunc(), nil
+	}
+
+	qr := &queuedRequest{
+		priority: priority,
+		granted:  make(chan struct{}),
+		position: make(chan int, 1),
+	}
+
+	// Insert after every request of the same or higher priority
+	i := len(s.queue)
+	for i > 0 && s.queue[i-1].priority > priority {
+		i--
+	}
+	s.queue = append(s.queue, nil)
+	copy(s.queue[i+1:], s.queue[i:])
+	s.queue[i] = qr
+	s.notifyPositionsLocked()
+	s.mu.Unlock()
+
+	for {
+		select {
+		case <-qr.granted:
+			return s.releaseFunc(), nil
+		case position := <-qr.position:
+			if listener != nil {
+				listener(position)
+			}
+		case <-ctx.Done():
+			s.mu.Lock()
+			select {
+			case <-qr.granted:
+				// Granted concurrently with the cancellation; hand the slot on
+				s.mu.Unlock()
+				s.releaseFunc()()
+				return nil, ctx.Err()
+			default:
+			}
+			s.removeLocked(qr)
+			s.notifyPositionsLocked()
+			s.mu.Unlock()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// releaseFunc returns a function that frees a slot once
+func (s *Scheduler) releaseFunc() func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+
+			s.active--
+			for s.active < s.slots && len(s.queue) > 0 {
+				next := s.queue[0]
+				s.queue = s.queue[1:]
+				s.active++
+				close(next.granted)
+			}
+			s.notifyPositionsLocked()
+		})
+	}
+}
+
+// removeLocked removes a request from the queue. s.mu must be held.
+func (s *Scheduler) removeLocked(qr *queuedRequest) {
+	for i, q := range s.queue {
+		if q == qr {
+			s.queue = append(s.queue[:i], s.queue[i+1:]...)
+			return
+		}
+	}
+}
+
+// notifyPositionsLocked sends every queued request its current position,
+// replacing any position it has not read yet. s.mu must be held.
+func (s *Scheduler) notifyPositionsLocked() {
+	for i, q := range s.queue {
+		select {
+		case <-q.position:
+		default:
+		}
+		q.position <- i + 1
+	}
+}
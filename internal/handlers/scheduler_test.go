@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aristath/gollama-ui/internal/client"
+)
+
+// blockingChatClient streams one response per request once released
+type blockingChatClient struct {
+	mu      sync.Mutex
+	started []string
+	release chan struct{}
+}
+
+func (f *blockingChatClient) ChatStream(ctx context.Context, req client.ChatRequest) (<-chan client.ChatResponse, error) {
+	f.mu.Lock()
+	f.started = append(f.started, req.Model)
+	f.mu.Unlock()
+
+	ch := make(chan client.ChatResponse, 1)
+	go func() {
+		defer close(ch)
+		select {
+		case <-f.release:
+		case <-ctx.Done():
+		}
+		ch <- client.ChatResponse{Model: req.Model, Done: true}
+	}()
+	return ch, nil
+}
+
+func (f *blockingChatClient) startedModels() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.started...)
+}
+
+func TestNewScheduler_InvalidSlots(t *testing.T) {
+	_, err := NewScheduler(&blockingChatClient{}, 0)
+	assert.Error(t, err)
+}
+
+func TestScheduler_QueuesByPriority(t *testing.T) {
+	fake := &blockingChatClient{release: make(chan struct{})}
+	s, _ := NewScheduler(fake, 1)
+
+	first, err := s.ChatStream(context.Background(), client.ChatRequest{Model: "first"})
+	assert.NoError(t, err)
+
+	streams := make(chan (<-chan client.ChatResponse), 3)
+	start := func(ctx context.Context, model string) {
+		go func() {
+			stream, err := s.ChatStream(ctx, client.ChatRequest{Model: model})
+			assert.NoError(t, err)
+			streams <- stream
+		}()
+	}
+
+	start(WithPriority(context.Background(), PriorityBackground), "summary")
+	assert.Eventually(t, func() bool { return s.QueueLength() == 1 }, time.Second, time.Millisecond)
+	start(context.Background(), "chat-a")
+	assert.Eventually(t, func() bool { return s.QueueLength() == 2 }, time.Second, time.Millisecond)
+	start(context.Background(), "chat-b")
+	assert.Eventually(t, func() bool { return s.QueueLength() == 3 }, time.Second, time.Millisecond)
+
+	// Finish every stream in turn; each one frees the slot for the next
+	close(fake.release)
+	for range first {
+	}
+	for i := 0; i < 3; i++ {
+		for range <-streams {
+		}
+	}
+
+	assert.Equal(t, []string{"first", "chat-a", "chat-b", "summary"}, fake.startedModels())
+}
+
+func TestScheduler_ReportsQueuePosition(t *testing.T) {
+	fake := &blockingChatClient{release: make(chan struct{})}
+	s, _ := NewScheduler(fake, 1)
+
+	first, _ := s.ChatStream(context.Background(), client.ChatRequest{Model: "first"})
+
+	positions := make(chan int, 10)
+	ctx := WithQueueListener(context.Background(), func(position int) {
+		positions <- position
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		stream, err := s.ChatStream(ctx, client.ChatRequest{Model: "second"})
+		assert.NoError(t, err)
+		for range stream {
+		}
+	}()
+
+	assert.Equal(t, 1, <-positions)
+
+	close(fake.release)
+	for range first {
+	}
+	<-done
+}
+
+func TestScheduler_CancelRemovesFromQueue(t *testing.T) {
+	fake := &blockingChatClient{release: make(chan struct{})}
+	s, _ := NewScheduler(fake, 1)
+
+	first, _ := s.ChatStream(context.Background(), client.ChatRequest{Model: "first"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() {
+		_, err := s.ChatStream(ctx, client.ChatRequest{Model: "cancelled"})
+		errs <- err
+	}()
+	assert.Eventually(t, func() bool { return s.QueueLength() == 1 }, time.Second, time.Millisecond)
+
+	cancel()
+	assert.ErrorIs(t, <-errs, context.Canceled)
+	assert.Equal(t, 0, s.QueueLength())
+
+	// The slot is still usable once the first chat ends
+	close(fake.release)
+	for range first {
+	}
+	stream, err := s.ChatStream(context.Background(), client.ChatRequest{Model: "next"})
+	assert.NoError(t, err)
+	for range stream {
+	}
+
+	assert.Equal(t, []string{"first", "next"}, fake.startedModels())
+}
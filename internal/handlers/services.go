@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aristath/gollama-ui/internal/metrics"
+)
+
+// serviceCheckTimeout bounds a single service health check
+const serviceCheckTimeout = 5 * time.Second
+
+// Names of the services checked by the service monitor
+const (
+	ServiceDDGS      = "ddgs"
+	ServiceSentinel  = "sentinel"
+	ServiceFeeds     = "feeds"
+	ServiceConfigDir = "config_dir"
+)
+
+// ServiceChecker is an external service tools depend on
+type ServiceChecker interface {
+	HealthCheck(ctx context.Context) error
+}
+
+// ServiceStatus is the latest health check result of a service
+type ServiceStatus struct {
+	Name      string    `json:"name"`
+	Healthy   bool      `json:"healthy"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// ServiceMonitor periodically checks the external services used by tools,
+// such as the ddgs search service and Sentinel
+type ServiceMonitor struct {
+	services map[string]ServiceChecker
+
+	mu     sync.Mutex
+	status map[string]ServiceStatus
+}
+
+// NewServiceMonitor creates a monitor for the named services
+func NewServiceMonitor(services map[string]ServiceChecker) *ServiceMonitor {
+	return &ServiceMonitor{
+		services: services,
+		status:   make(map[string]ServiceStatus),
+	}
+}
+
+// Monitor checks every service at the given interval until ctx is cancelled
+func (m *ServiceMonitor) Monitor(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		m.CheckAll(ctx)
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// CheckAll checks every service once, concurrently
+func (m *ServiceMonitor) CheckAll(ctx context.Context) {
+	var wg sync.WaitGroup
+	for name, service := range m.services {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m.check(ctx, name, service)
+		}()
+	}
+	wg.Wait()
+}
+
+// check checks one service and logs changes of its health
+func (m *ServiceMonitor) check(ctx context.Context, name string, service ServiceChecker) {
+	ctx, cancel := context.WithTimeout(ctx, serviceCheckTimeout)
+	defer cancel()
+
+	err := service.HealthCheck(ctx)
+	status := ServiceStatus{Name: name, Healthy: err == nil, CheckedAt: time.Now()}
+	if err != nil {
+		status.Error = err.Error()
+	}
+
+	m.mu.Lock()
+	previous, checked := m.status[name]
+	m.status[name] = status
+	m.mu.Unlock()
+
+	switch {
+	case !status.Healthy && (!checked || previous.Healthy):
+		slog.Warn("Service is unavailable, tools that need it are disabled", "service", name, "error", err)
+	case status.Healthy && checked && !previous.Healthy:
+		slog.Info("Service is available again", "service", name)
+	}
+}
+
+// Status returns the latest status of a service, and false when it hasn't
+// been checked yet. A nil monitor has no statuses.
+func (m *ServiceMonitor) Status(name string) (ServiceStatus, bool) {
+	if m == nil {
+		return ServiceStatus{}, false
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	status, ok := m.status[name]
+	return status, ok
+}
+
+// Statuses returns the latest status of every checked service, sorted by name
+func (m *ServiceMonitor) Statuses() []ServiceStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := make([]ServiceStatus, 0, len(m.status))
+	for _, status := range m.status {
+		result = append(result, status)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// RegisterMetrics exports whether each service passed its last health check
+func (m *ServiceMonitor) RegisterMetrics(reg *metrics.Registry) {
+	reg.NewGaugeFunc("gollama_service_up", "Whether an external service passed its last health check.", []string{"service"},
+		func(emit func(float64, ...string)) {
+			for _, status := range m.Statuses() {
+				value := 0.0
+				if status.Healthy {
+					value = 1
+				}
+				emit(value, status.Name)
+			}
+		})
+}
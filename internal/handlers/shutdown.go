@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// cancelWait bounds how long Shutdown waits for cancelled generations to
+// save their partial replies
+const cancelWait = 5 * time.Second
+
+// errShuttingDown is returned for chats started during shutdown
+var errShuttingDown = errors.New("server is shutting down")
+
+// isShuttingDown reports whether Shutdown has been called
+func (h *ChatHandler) isShuttingDown() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.shuttingDown
+}
+
+// Shutdown stops accepting chats and tells the clients of running ones with a
+// server_shutting_down event. Generations may finish until ctx is done; those
+// still running then are cancelled, and their partial replies are saved to
+// their conversations.
+func (h *ChatHandler) Shutdown(ctx context.Context) {
+	h.mu.Lock()
+	h.shuttingDown = true
+	var running []*generation
+	for _, g := range h.generations {
+		if !g.finished() {
+			running = append(running, g)
+		}
+	}
+	h.mu.Unlock()
+
+	notice := map[string]interface{}{}
+	if deadline, ok := ctx.Deadline(); ok {
+		notice["deadline"] = deadline
+	}
+	for _, g := range running {
+		writeEvent(g, g, "server_shutting_down", notice)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		h.running.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return
+	case <-ctx.Done():
+	}
+
+	slog.Warn("Cancelling chats that did not finish in time", "chats", len(running))
+	for _, g := range running {
+		g.cancel()
+	}
+
+	select {
+	case <-done:
+	case <-time.After(cancelWait):
+		slog.Error("Chats did not stop after being cancelled")
+	}
+}
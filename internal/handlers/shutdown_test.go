@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// startChat starts an interactive chat on conversation conv1 and waits for
+// its first chunk
+func startChat(t *testing.T, h *ChatHandler) (*generation, <-chan struct{}) {
+	t.Helper()
+	body := strings.NewReader(`{"model":"m","conversation_id":"conv1","messages":[{"role":"user","content":"hi"}]}`)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		h.Stream(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/api/chat", body))
+	}()
+
+	g, _ := h.getGeneration(context.Background(), activeChatID(t, h))
+	assert.Eventually(t, func() bool {
+		return g.partialContent() == "a"
+	}, time.Second, time.Millisecond)
+	return g, done
+}
+
+// hasEvent reports whether g has buffered an event with the given name
+func hasEvent(g *generation, name string) bool {
+	events, _, _ := g.since(0)
+	for _, e := range events {
+		if strings.HasPrefix(string(e.block), "event: "+name+"\n") {
+			return true
+		}
+	}
+	return false
+}
+
+func TestChatHandler_ShutdownSavesPartialReply(t *testing.T) {
+	fake := &gatedChatClient{gate: make(chan struct{})}
+	store := NewConversationStore(filepath.Join(t.TempDir(), "conversations"))
+	h := NewChatHandler(fake, nil)
+	h.SetConversationStore(store)
+
+	g, done := startChat(t, h)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	h.Shutdown(ctx)
+	<-done
+
+	assert.True(t, hasEvent(g, "server_shutting_down"))
+	assert.True(t, g.finished())
+
+	conv, err := store.Get("conv1")
+	assert.NoError(t, err)
+	if assert.NotNil(t, conv) && assert.Len(t, conv.Messages, 2) {
+		assert.Equal(t, "assistant", conv.Messages[1].Role)
+		assert.Equal(t, "a", conv.Messages[1].Content)
+	}
+
+	// No new chats are started
+	rec := httptest.NewRecorder()
+	body := strings.NewReader(`{"model":"m","messages":[{"role":"user","content":"hi"}]}`)
+	h.Stream(rec, httptest.NewRequest(http.MethodPost, "/api/chat", body))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.NotEmpty(t, rec.Header().Get("Retry-After"))
+}
+
+func TestChatHandler_ShutdownDrains(t *testing.T) {
+	fake := &gatedChatClient{gate: make(chan struct{})}
+	store := NewConversationStore(filepath.Join(t.TempDir(), "conversations"))
+	h := NewChatHandler(fake, nil)
+	h.SetConversationStore(store)
+
+	g, done := startChat(t, h)
+
+	// The chat finishes within the drain period
+	time.AfterFunc(20*time.Millisecond, func() { close(fake.gate) })
+	start := time.Now()
+	h.Shutdown(context.Background())
+	<-done
+	assert.Less(t, time.Since(start), time.Second)
+
+	assert.Equal(t, "ab", g.partialContent())
+	assert.Equal(t, "generation did not complete", g.lastError(), "no error was reported")
+
+	// Complete replies of interactive chats are kept by the client
+	conv, err := store.Get("conv1")
+	assert.NoError(t, err)
+	assert.Nil(t, conv)
+}
+
+func TestChatHandler_ShutdownJob(t *testing.T) {
+	fake := &gatedChatClient{gate: make(chan struct{})}
+	store := NewConversationStore(filepath.Join(t.TempDir(), "conversations"))
+	h := NewChatHandler(fake, nil)
+	h.SetConversationStore(store)
+
+	rec := httptest.NewRecorder()
+	body := strings.NewReader(`{"model":"m","conversation_id":"conv1","detach":true,"messages":[{"role":"user","content":"hi"}]}`)
+	h.Stream(rec, httptest.NewRequest(http.MethodPost, "/api/chat", body))
+	var started Job
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&started))
+
+	g, _ := h.getGeneration(context.Background(), started.ID)
+	assert.Eventually(t, func() bool {
+		return g.partialContent() == "a"
+	}, time.Second, time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	h.Shutdown(ctx)
+
+	job := waitForJob(t, h, started.ID)
+	assert.Equal(t, JobCancelled, job.Status)
+	assert.Equal(t, "server shut down", job.Error)
+	assert.Equal(t, "a", job.Content)
+
+	conv, err := store.Get("conv1")
+	assert.NoError(t, err)
+	if assert.NotNil(t, conv) && assert.Len(t, conv.Messages, 2) {
+		assert.Equal(t, "a", conv.Messages[1].Content)
+	}
+}
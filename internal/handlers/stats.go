@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/aristath/gollama-ui/internal/client"
+)
+
+// ModelStats aggregates the token usage and speed of a model's responses
+type ModelStats struct {
+	Model            string `json:"model"`
+	Responses        int    `json:"responses"`
+	PromptTokens     int    `json:"prompt_tokens"`
+	CompletionTokens int    `json:"completion_tokens"`
+
+	// Speeds are total tokens over total time, for responses with timings
+	PromptPerSecond    float64 `json:"prompt_per_second,omitempty"`
+	PredictedPerSecond float64 `json:"predicted_per_second,omitempty"`
+
+	promptN     int
+	promptMs    float64
+	predictedN  int
+	predictedMs float64
+}
+
+// StatsStore aggregates response statistics per model in memory
+type StatsStore struct {
+	mu     sync.Mutex
+	models map[string]*ModelStats
+}
+
+// NewStatsStore creates an empty stats store
+func NewStatsStore() *StatsStore {
+	return &StatsStore{
+		models: make(map[string]*ModelStats),
+	}
+}
+
+// Record adds the usage and timings of one response
+func (s *StatsStore) Record(model string, usage *client.Usage, timings *client.Timings) {
+	if usage == nil && timings == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats, ok := s.models[model]
+	if !ok {
+		stats = &ModelStats{Model: model}
+		s.models[model] = stats
+	}
+
+	stats.Responses++
+	if usage != nil {
+		stats.PromptTokens += usage.PromptTokens
+		stats.CompletionTokens += usage.CompletionTokens
+	} else {
+		stats.PromptTokens += timings.PromptN
+		stats.CompletionTokens += timings.PredictedN
+	}
+
+	if timings != nil {
+		if timings.PromptMs > 0 {
+			stats.promptN += timings.PromptN
+			stats.promptMs += timings.PromptMs
+			stats.PromptPerSecond = float64(stats.promptN) / stats.promptMs * 1000
+		}
+		if timings.PredictedMs > 0 {
+			stats.predictedN += timings.PredictedN
+			stats.predictedMs += timings.PredictedMs
+			stats.PredictedPerSecond = float64(stats.predictedN) / stats.predictedMs * 1000
+		}
+	}
+}
+
+// List returns the stats of every model, sorted by model name
+func (s *StatsStore) List() []ModelStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := make([]ModelStats, 0, len(s.models))
+	for _, stats := range s.models {
+		result = append(result, *stats)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Model < result[j].Model
+	})
+	return result
+}
+
+// recordStats adds one response to the totals and to the stats of the user
+// of ctx
+func (h *ChatHandler) recordStats(ctx context.Context, model string, usage *client.Usage, timings *client.Timings) {
+	h.stats.Record(model, usage, timings)
+	h.userStats.Record(userFromContext(ctx), model, usage, timings)
+}
+
+// Stats handles GET /api/stats, returning the stats of the client's user
+func (h *ChatHandler) Stats(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"models": h.userStats.Get(userFromContext(r.Context())),
+	})
+}
+
+// AllStats handles GET /api/admin/stats, returning the totals and the stats
+// of every user
+func (h *ChatHandler) AllStats(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"models": h.stats.List(),
+		"users":  h.userStats.List(),
+	})
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aristath/gollama-ui/internal/client"
+)
+
+// timedChatClient streams one chunk and a done response with usage and timings
+type timedChatClient struct{}
+
+func (f *timedChatClient) ChatStream(ctx context.Context, req client.ChatRequest) (<-chan client.ChatResponse, error) {
+	ch := make(chan client.ChatResponse, 2)
+	ch <- client.ChatResponse{Model: req.Model, Message: client.ChatMessage{Role: "assistant", Content: "hi"}}
+	ch <- client.ChatResponse{
+		Model:   req.Model,
+		Done:    true,
+		Usage:   &client.Usage{PromptTokens: 8, CompletionTokens: 4, TotalTokens: 12},
+		Timings: &client.Timings{PromptN: 8, PromptMs: 100, PredictedN: 4, PredictedMs: 400},
+	}
+	close(ch)
+	return ch, nil
+}
+
+func TestStatsStore_Record(t *testing.T) {
+	s := NewStatsStore()
+
+	s.Record("a", &client.Usage{PromptTokens: 10, CompletionTokens: 10}, &client.Timings{PromptN: 10, PromptMs: 100, PredictedN: 10, PredictedMs: 1000})
+	s.Record("a", &client.Usage{PromptTokens: 30, CompletionTokens: 30}, &client.Timings{PromptN: 30, PromptMs: 100, PredictedN: 30, PredictedMs: 1000})
+	s.Record("b", &client.Usage{PromptTokens: 5, CompletionTokens: 7}, nil)
+	s.Record("c", nil, nil)
+
+	stats := s.List()
+	assert.Len(t, stats, 2)
+
+	assert.Equal(t, "a", stats[0].Model)
+	assert.Equal(t, 2, stats[0].Responses)
+	assert.Equal(t, 40, stats[0].CompletionTokens)
+	assert.Equal(t, 200.0, stats[0].PromptPerSecond)
+	assert.Equal(t, 20.0, stats[0].PredictedPerSecond)
+
+	assert.Equal(t, "b", stats[1].Model)
+	assert.Equal(t, 7, stats[1].CompletionTokens)
+	assert.Zero(t, stats[1].PredictedPerSecond)
+}
+
+func TestChatHandler_StreamRecordsStats(t *testing.T) {
+	h := NewChatHandler(&timedChatClient{}, nil)
+
+	rec := httptest.NewRecorder()
+	body := strings.NewReader(`{"model":"m","messages":[{"role":"user","content":"hi"}]}`)
+	h.Stream(rec, httptest.NewRequest(http.MethodPost, "/api/chat", body))
+
+	// The done response reaches the client with its stats
+	assert.Contains(t, rec.Body.String(), `"done":true`)
+	assert.Contains(t, rec.Body.String(), `"predicted_ms":400`)
+
+	statsRec := httptest.NewRecorder()
+	h.Stats(statsRec, httptest.NewRequest(http.MethodGet, "/api/stats", nil))
+
+	var result struct {
+		Models []ModelStats `json:"models"`
+	}
+	assert.NoError(t, json.NewDecoder(statsRec.Body).Decode(&result))
+	assert.Len(t, result.Models, 1)
+	assert.Equal(t, "m", result.Models[0].Model)
+	assert.Equal(t, 1, result.Models[0].Responses)
+	assert.Equal(t, 10.0, result.Models[0].PredictedPerSecond)
+	assert.Equal(t, 80.0, result.Models[0].PromptPerSecond)
+}
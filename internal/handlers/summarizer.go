@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/aristath/gollama-ui/internal/client"
+)
+
+// summaryPrompt instructs the model how to condense old turns
+const summaryPrompt = "You maintain a running summary of a conversation between a user and an assistant. " +
+	"Write a concise summary that preserves facts, names, numbers, decisions, tool results and open questions. " +
+	"Reply with the summary only."
+
+// Summarizer condenses old conversation turns into a summary using the current model
+type Summarizer struct {
+	chatClient ChatClientInterface
+	store      *ConversationStore
+}
+
+// NewSummarizer creates a new summarizer that caches summaries in store
+func NewSummarizer(chatClient ChatClientInterface, store *ConversationStore) *Summarizer {
+	return &Summarizer{
+		chatClient: chatClient,
+		store:      store,
+	}
+}
+
+// Summarize returns a summary covering at least messages[:cutoff] together with
+// the number of leading messages it actually covers. A cached summary is reused
+// when it still matches the history, and only extended when more turns overflow.
+func (s *Summarizer) Summarize(ctx context.Context, model, conversationID string, messages []client.ChatMessage, cutoff int) (string, int, error) {
+	store := s.store.ForContext(ctx)
+	conv, err := store.Get(conversationID)
+	if err != nil {
+		return "", 0, err
+	}
+	if conv == nil {
+		conv = &Conversation{ID: conversationID}
+	}
+
+	previous := ""
+	from := 0
+	if conv.Summary != "" && conv.SummarizedCount <= len(messages) &&
+		conv.SummaryHash == hashMessages(messages[:conv.SummarizedCount]) {
+		if conv.SummarizedCount >= cutoff {
+			return conv.Summary, conv.SummarizedCount, nil
+		}
+		previous = conv.Summary
+		from = conv.SummarizedCount
+	}
+
+	summary, err := s.generate(ctx, model, previous, messages[from:cutoff])
+	if err != nil {
+		return "", 0, err
+	}
+
+	// The conversation may have been saved while the summary was generated,
+	// so only the summary is written to its current version
+	hash := hashMessages(messages[:cutoff])
+	if err := store.Update(conversationID, func(conv *Conversation) {
+		conv.Summary = summary
+		conv.SummarizedCount = cutoff
+		conv.SummaryHash = hash
+	}); err != nil {
+		return "", 0, err
+	}
+
+	return summary, cutoff, nil
+}
+
+// generate asks the model to fold messages into the previous summary
+func (s *Summarizer) generate(ctx context.Context, model, previous string, messages []client.ChatMessage) (string, error) {
+	var transcript strings.Builder
+	if previous != "" {
+		transcript.WriteString("Summary so far:\n")
+		transcript.WriteString(previous)
+		transcript.WriteString("\n\n")
+	}
+	transcript.WriteString("New messages:\n")
+	for _, msg := range messages {
+		if msg.Role == "system" {
+			continue
+		}
+		transcript.WriteString(fmt.Sprintf("%s: %s\n", msg.Role, msg.Content))
+		for _, tc := range msg.ToolCalls {
+			transcript.WriteString(fmt.Sprintf("%s called %s(%s)\n", msg.Role, tc.Function.Name, tc.Function.Arguments))
+		}
+	}
+
+	stream, err := s.chatClient.ChatStream(WithPriority(ctx, PriorityBackground), client.ChatRequest{
+		Model: model,
+		Messages: []client.ChatMessage{
+			{Role: "system", Content: summaryPrompt},
+			{Role: "user", Content: transcript.String()},
+		},
+		Stream: true,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to start summary: %w", err)
+	}
+
+	var summary strings.Builder
+	for {
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+
+		case response, ok := <-stream:
+			if !ok {
+				return finishSummary(summary.String())
+			}
+			if response.Error != "" {
+				return "", fmt.Errorf("summary failed: %s", response.Error)
+			}
+			summary.WriteString(response.Message.Content)
+			if response.Done {
+				return finishSummary(summary.String())
+			}
+		}
+	}
+}
+
+// finishSummary trims the generated summary and rejects empty ones
+func finishSummary(summary string) (string, error) {
+	summary = strings.TrimSpace(summary)
+	if summary == "" {
+		return "", fmt.Errorf("model returned an empty summary")
+	}
+	return summary, nil
+}
+
+// hashMessages returns a stable hash of messages, used to detect edited history.
+// System messages are skipped since they may be regenerated on every request.
+func hashMessages(messages []client.ChatMessage) string {
+	h := sha256.New()
+	enc := json.NewEncoder(h)
+	for _, msg := range messages {
+		if msg.Role == "system" {
+			continue
+		}
+		enc.Encode(msg)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
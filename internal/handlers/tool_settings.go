@@ -13,6 +13,7 @@ type ToolSettings struct {
 	EnableFeeds     bool            `json:"enable_feeds"`
 	EnableSentinel  bool            `json:"enable_sentinel"`
 	configPath      string
+	saver           func(webSearch, feeds, sentinel bool) error
 	mu              sync.RWMutex
 }
 
@@ -58,8 +59,19 @@ func (ts *ToolSettings) Load() error {
 	return nil
 }
 
+// SetSaver makes Save persist settings with save, such as into the config
+// file, instead of writing them to the settings file
+func (ts *ToolSettings) SetSaver(save func(webSearch, feeds, sentinel bool) error) {
+	ts.saver = save
+}
+
 // Save persists tool settings to file
 func (ts *ToolSettings) Save() error {
+	if ts.saver != nil {
+		settings := ts.Get()
+		return ts.saver(settings.EnableWebSearch, settings.EnableFeeds, settings.EnableSentinel)
+	}
+
 	if ts.configPath == "" {
 		return fmt.Errorf("no config path set for saving settings")
 	}
@@ -120,3 +132,13 @@ func (ts *ToolSettings) Set(webSearch, feeds, sentinel bool) error {
 
 	return ts.Save()
 }
+
+// Reset updates tool settings without saving them, such as when the config
+// file they were loaded from is reloaded
+func (ts *ToolSettings) Reset(webSearch, feeds, sentinel bool) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.EnableWebSearch = webSearch
+	ts.EnableFeeds = feeds
+	ts.EnableSentinel = sentinel
+}
@@ -4,9 +4,12 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"strings"
+	"time"
 
 	"github.com/aristath/gollama-ui/internal/client"
+	"github.com/aristath/gollama-ui/internal/logging"
 )
 
 // ToolExecutor executes tool calls from llama.cpp
@@ -15,6 +18,24 @@ type ToolExecutor struct {
 	newsClient     *client.NewsClient
 	sentinelClient *client.SentinelClient
 	toolSettings   *ToolSettings
+	userTools      *UserToolSettings
+	services       *ServiceMonitor
+	metrics        *Metrics
+}
+
+// toolServices maps each tool to the service it needs
+var toolServices = map[string]string{
+	"web_search":        ServiceDDGS,
+	"get_news":          ServiceFeeds,
+	"analyze_portfolio": ServiceSentinel,
+}
+
+// ToolStatus reports whether a tool is enabled and whether it can run
+type ToolStatus struct {
+	Name      string `json:"name"`
+	Enabled   bool   `json:"enabled"`
+	Available bool   `json:"available"`
+	Reason    string `json:"reason,omitempty"` // Why the tool is unavailable
 }
 
 // NewToolExecutor creates a new tool executor
@@ -27,8 +48,51 @@ func NewToolExecutor(searchClient *client.SearchClient, newsClient *client.NewsC
 	}
 }
 
+// SetUserToolSettings sets the per-user overrides of the tool settings
+func (e *ToolExecutor) SetUserToolSettings(userTools *UserToolSettings) {
+	e.userTools = userTools
+}
+
+// SetServiceMonitor sets the monitor of the services tools need. Tools whose
+// service is down are disabled until it recovers.
+func (e *ToolExecutor) SetServiceMonitor(m *ServiceMonitor) {
+	e.services = m
+}
+
+// SetMetrics sets the instruments tool calls are recorded to
+func (e *ToolExecutor) SetMetrics(m *Metrics) {
+	e.metrics = m
+}
+
 // ExecuteToolCall executes a single tool call and returns formatted result
 func (e *ToolExecutor) ExecuteToolCall(ctx context.Context, name string, arguments string) (string, error) {
+	start := time.Now()
+	result, err := e.executeToolCall(ctx, name, arguments)
+
+	// Names come from the model, so unknown ones share a label
+	label := name
+	if len(e.GetToolsByName([]string{name})) == 0 {
+		label = "unknown"
+	}
+	duration := time.Since(start)
+	e.metrics.observeToolCall(label, duration, err)
+
+	if err != nil {
+		slog.WarnContext(ctx, "Tool call failed", "tool", label, "arguments", logging.Content(arguments), "duration", duration, "error", err)
+	} else {
+		slog.InfoContext(ctx, "Tool call", "tool", label, "arguments", logging.Content(arguments), "duration", duration)
+	}
+
+	return result, err
+}
+
+// executeToolCall dispatches a tool call to the tool's implementation
+func (e *ToolExecutor) executeToolCall(ctx context.Context, name string, arguments string) (string, error) {
+	// Fail at once rather than waiting for a service known to be down
+	if reason := e.unavailable(name); reason != "" {
+		return "", fmt.Errorf("tool disabled, %s", reason)
+	}
+
 	// Parse arguments JSON
 	var args map[string]interface{}
 	if err := json.Unmarshal([]byte(arguments), &args); err != nil {
@@ -354,96 +418,191 @@ func (e *ToolExecutor) executeFullAnalysis(ctx context.Context, focusArea string
 
 // GetAvailableTools returns tool definitions for llama.cpp (only enabled tools)
 func (e *ToolExecutor) GetAvailableTools() []client.Tool {
-	settings := e.toolSettings.Get()
+	return e.GetAvailableToolsForUser("")
+}
+
+// GetAvailableToolsForUser returns the definitions of the tools enabled for a
+// user, whose overrides are layered over the global tool settings. Tools
+// whose service is down are left out.
+func (e *ToolExecutor) GetAvailableToolsForUser(user string) []client.Tool {
 	tools := []client.Tool{}
 
-	// Add web_search tool if enabled
-	if settings.EnableWebSearch {
-		tools = append(tools, client.Tool{
-			Type: "function",
-			Function: client.Function{
-				Name:        "web_search",
-				Description: "Search the web for current information. Use this when you need up-to-date information or facts not in your training data.",
-				Parameters: map[string]interface{}{
-					"type": "object",
-					"properties": map[string]interface{}{
-						"query": map[string]interface{}{
-							"type":        "string",
-							"description": "The search query to find information about",
-						},
-						"max_results": map[string]interface{}{
-							"type":        "integer",
-							"description": "Maximum number of search results to return (default 5)",
-						},
-					},
-					"required": []string{"query"},
-				},
-			},
-		})
+	for _, status := range e.ToolStatuses(user) {
+		if !status.Enabled {
+			continue
+		}
+		if !status.Available {
+			slog.Debug("Tool disabled", "tool", status.Name, "reason", status.Reason)
+			continue
+		}
+		tools = append(tools, e.GetToolsByName([]string{status.Name})...)
+	}
+
+	return tools
+}
+
+// ToolStatuses reports for every tool whether it is enabled for a user and
+// whether its service is up
+func (e *ToolExecutor) ToolStatuses(user string) []ToolStatus {
+	settings := e.toolSettings.Get()
+	if e.userTools != nil {
+		e.userTools.applyOverrides(user, &settings)
 	}
 
-	// Add get_news tool if enabled
-	if settings.EnableFeeds {
-		topics := e.newsClient.GetAvailableTopics()
-		var toolDescription string
-		var topicDescription string
+	statuses := []ToolStatus{
+		{Name: "web_search", Enabled: settings.EnableWebSearch},
+		{Name: "get_news", Enabled: settings.EnableFeeds},
+		{Name: "analyze_portfolio", Enabled: settings.EnableSentinel},
+	}
+	for i := range statuses {
+		statuses[i].Reason = e.unavailable(statuses[i].Name)
+		statuses[i].Available = statuses[i].Reason == ""
+	}
+
+	return statuses
+}
+
+// GetAvailableToolsByName returns the definitions of the named tools that
+// the tool settings of user enable and whose service is up. A persona's tools
+// narrow the enabled tools down, but can't enable a tool that was turned off.
+func (e *ToolExecutor) GetAvailableToolsByName(user string, names []string) []client.Tool {
+	wanted := make(map[string]bool, len(names))
+	for _, name := range names {
+		wanted[name] = true
+	}
+
+	var available []string
+	for _, status := range e.ToolStatuses(user) {
+		if !wanted[status.Name] || !status.Enabled {
+			continue
+		}
+		if !status.Available {
+			slog.Debug("Tool disabled", "tool", status.Name, "reason", status.Reason)
+			continue
+		}
+		available = append(available, status.Name)
+	}
+
+	return e.GetToolsByName(available)
+}
+
+// unavailable returns why a tool can't run, or an empty string when it can.
+// Tools whose service hasn't been checked yet are assumed to work.
+func (e *ToolExecutor) unavailable(name string) string {
+	service, ok := toolServices[name]
+	if !ok {
+		return ""
+	}
+	status, checked := e.services.Status(service)
+	if !checked || status.Healthy {
+		return ""
+	}
+	return fmt.Sprintf("%s is unavailable: %s", service, status.Error)
+}
+
+// GetToolsByName returns the definitions of the named tools regardless of the
+// tool settings. Unknown names are ignored.
+func (e *ToolExecutor) GetToolsByName(names []string) []client.Tool {
+	tools := []client.Tool{}
 
-		if len(topics) == 0 {
-			toolDescription = "Get latest news articles. No feeds are currently configured."
-			topicDescription = "News topic (no feeds configured - add feeds in settings)"
-		} else {
-			topicDescription = fmt.Sprintf("Must be one of: %s. Use the exact topic name as shown.", strings.Join(topics, ", "))
-			toolDescription = fmt.Sprintf("Get latest news articles. Available topics: %s. Call this tool once per topic if you need multiple categories.", strings.Join(topics, ", "))
+	for _, name := range names {
+		switch name {
+		case "web_search":
+			tools = append(tools, e.webSearchTool())
+		case "get_news":
+			tools = append(tools, e.newsTool())
+		case "analyze_portfolio":
+			tools = append(tools, e.portfolioTool())
 		}
+	}
+
+	return tools
+}
 
-		tools = append(tools, client.Tool{
-			Type: "function",
-			Function: client.Function{
-				Name:        "get_news",
-				Description: toolDescription,
-				Parameters: map[string]interface{}{
-					"type": "object",
-					"properties": map[string]interface{}{
-						"topic": map[string]interface{}{
-							"type":        "string",
-							"description": topicDescription,
-						},
-						"max_articles": map[string]interface{}{
-							"type":        "integer",
-							"description": "Maximum number of articles to return (default 10)",
-						},
+// webSearchTool returns the web_search tool definition
+func (e *ToolExecutor) webSearchTool() client.Tool {
+	return client.Tool{
+		Type: "function",
+		Function: client.Function{
+			Name:        "web_search",
+			Description: "Search the web for current information. Use this when you need up-to-date information or facts not in your training data.",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"query": map[string]interface{}{
+						"type":        "string",
+						"description": "The search query to find information about",
+					},
+					"max_results": map[string]interface{}{
+						"type":        "integer",
+						"description": "Maximum number of search results to return (default 5)",
 					},
-					"required": []string{"topic"},
 				},
+				"required": []string{"query"},
 			},
-		})
-	}
-
-	// Add analyze_portfolio tool if enabled
-	if settings.EnableSentinel {
-		tools = append(tools, client.Tool{
-			Type: "function",
-			Function: client.Function{
-				Name:        "analyze_portfolio",
-				Description: "Analyze the Sentinel portfolio management system to get current portfolio state, trading opportunities, risk metrics, and market context. Use this to answer questions about portfolio health, performance, allocation, or to suggest next actions.",
-				Parameters: map[string]interface{}{
-					"type": "object",
-					"properties": map[string]interface{}{
-						"query_type": map[string]interface{}{
-							"type":        "string",
-							"description": "Type of analysis to perform: 'overview' for portfolio summary, 'opportunities' for trade suggestions, 'risk' for risk metrics, 'market_context' for market regime, 'full_analysis' for comprehensive snapshot",
-							"enum":        []interface{}{"overview", "opportunities", "risk", "market_context", "full_analysis"},
-						},
-						"focus_area": map[string]interface{}{
-							"type":        "string",
-							"description": "Optional: specific area to focus on (e.g., 'US allocation', 'technology sector', 'high volatility positions')",
-						},
+		},
+	}
+}
+
+// newsTool returns the get_news tool definition
+func (e *ToolExecutor) newsTool() client.Tool {
+	topics := e.newsClient.GetAvailableTopics()
+	var toolDescription string
+	var topicDescription string
+
+	if len(topics) == 0 {
+		toolDescription = "Get latest news articles. No feeds are currently configured."
+		topicDescription = "News topic (no feeds configured - add feeds in settings)"
+	} else {
+		topicDescription = fmt.Sprintf("Must be one of: %s. Use the exact topic name as shown.", strings.Join(topics, ", "))
+		toolDescription = fmt.Sprintf("Get latest news articles. Available topics: %s. Call this tool once per topic if you need multiple categories.", strings.Join(topics, ", "))
+	}
+
+	return client.Tool{
+		Type: "function",
+		Function: client.Function{
+			Name:        "get_news",
+			Description: toolDescription,
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"topic": map[string]interface{}{
+						"type":        "string",
+						"description": topicDescription,
+					},
+					"max_articles": map[string]interface{}{
+						"type":        "integer",
+						"description": "Maximum number of articles to return (default 10)",
 					},
-					"required": []interface{}{"query_type"},
 				},
+				"required": []string{"topic"},
 			},
-		})
+		},
 	}
+}
 
-	return tools
+// portfolioTool returns the analyze_portfolio tool definition
+func (e *ToolExecutor) portfolioTool() client.Tool {
+	return client.Tool{
+		Type: "function",
+		Function: client.Function{
+			Name:        "analyze_portfolio",
+			Description: "Analyze the Sentinel portfolio management system to get current portfolio state, trading opportunities, risk metrics, and market context. Use this to answer questions about portfolio health, performance, allocation, or to suggest next actions.",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"query_type": map[string]interface{}{
+						"type":        "string",
+						"description": "Type of analysis to perform: 'overview' for portfolio summary, 'opportunities' for trade suggestions, 'risk' for risk metrics, 'market_context' for market regime, 'full_analysis' for comprehensive snapshot",
+						"enum":        []interface{}{"overview", "opportunities", "risk", "market_context", "full_analysis"},
+					},
+					"focus_area": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: specific area to focus on (e.g., 'US allocation', 'technology sector', 'high volatility positions')",
+					},
+				},
+				"required": []interface{}{"query_type"},
+			},
+		},
+	}
 }
@@ -0,0 +1,277 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/aristath/gollama-ui/internal/auth"
+	"github.com/aristath/gollama-ui/internal/client"
+)
+
+// userFromContext returns the name of the authenticated user of ctx, or an
+// empty string when authentication is disabled
+func userFromContext(ctx context.Context) string {
+	id, ok := auth.FromContext(ctx)
+	if !ok || id.Method == auth.MethodNone {
+		return ""
+	}
+	return id.Name
+}
+
+// canAccess reports whether the client of ctx may see a chat or job started
+// by owner. Admins see everything.
+func canAccess(ctx context.Context, owner string) bool {
+	id, ok := auth.FromContext(ctx)
+	if !ok {
+		return owner == ""
+	}
+	return id.IsAdmin() || id.Name == owner
+}
+
+// ToolOverrides are a user's changes to the global tool settings. Nil fields
+// keep the global setting.
+type ToolOverrides struct {
+	EnableWebSearch *bool `json:"enable_web_search,omitempty"`
+	EnableFeeds     *bool `json:"enable_feeds,omitempty"`
+	EnableSentinel  *bool `json:"enable_sentinel,omitempty"`
+}
+
+// apply overrides the fields of settings that are set
+func (o ToolOverrides) apply(settings *ToolSettings) {
+	if o.EnableWebSearch != nil {
+		settings.EnableWebSearch = *o.EnableWebSearch
+	}
+	if o.EnableFeeds != nil {
+		settings.EnableFeeds = *o.EnableFeeds
+	}
+	if o.EnableSentinel != nil {
+		settings.EnableSentinel = *o.EnableSentinel
+	}
+}
+
+// UserToolSettings layers each user's tool overrides, stored in
+// <dir>/<user>/tool-settings.json, over the global tool settings
+type UserToolSettings struct {
+	dir    string
+	global *ToolSettings
+
+	mu    sync.Mutex
+	users map[string]ToolOverrides
+}
+
+// NewUserToolSettings creates per-user tool settings over global
+func NewUserToolSettings(dir string, global *ToolSettings) *UserToolSettings {
+	return &UserToolSettings{
+		dir:    dir,
+		global: global,
+		users:  make(map[string]ToolOverrides),
+	}
+}
+
+// Overrides returns the overrides of a user
+func (s *UserToolSettings) Overrides(user string) (ToolOverrides, error) {
+	if user == "" {
+		return ToolOverrides{}, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if o, ok := s.users[user]; ok {
+		return o, nil
+	}
+
+	var o ToolOverrides
+	data, err := os.ReadFile(s.path(user))
+	if err != nil && !os.IsNotExist(err) {
+		return o, fmt.Errorf("failed to read tool settings: %w", err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &o); err != nil {
+			return o, fmt.Errorf("failed to parse tool settings: %w", err)
+		}
+	}
+
+	s.users[user] = o
+	return o, nil
+}
+
+// SetOverrides replaces the overrides of a user
+func (s *UserToolSettings) SetOverrides(user string, o ToolOverrides) error {
+	if user == "" {
+		return fmt.Errorf("per-user settings need authentication")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(s.path(user)), 0755); err != nil {
+		return fmt.Errorf("failed to create user directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(o, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal tool settings: %w", err)
+	}
+
+	if err := os.WriteFile(s.path(user), data, 0644); err != nil {
+		return fmt.Errorf("failed to write tool settings: %w", err)
+	}
+
+	s.users[user] = o
+	return nil
+}
+
+// applyOverrides applies the overrides of a user to settings. Overrides that
+// cannot be read are ignored.
+func (s *UserToolSettings) applyOverrides(user string, settings *ToolSettings) {
+	if o, err := s.Overrides(user); err == nil {
+		o.apply(settings)
+	}
+}
+
+// path returns the tool settings file of a user
+func (s *UserToolSettings) path(user string) string {
+	return filepath.Join(s.dir, user, "tool-settings.json")
+}
+
+// UserSettingsHandler serves the settings users change for themselves
+type UserSettingsHandler struct {
+	tools    *UserToolSettings
+	executor *ToolExecutor
+}
+
+// NewUserSettingsHandler creates a new user settings handler
+func NewUserSettingsHandler(tools *UserToolSettings) *UserSettingsHandler {
+	return &UserSettingsHandler{
+		tools: tools,
+	}
+}
+
+// SetToolExecutor sets the executor whose tool availability is reported
+func (h *UserSettingsHandler) SetToolExecutor(e *ToolExecutor) {
+	h.executor = e
+}
+
+// GetTools handles GET /api/me/tools, returning the global tool settings,
+// the user's overrides and the result, and which tools are disabled because
+// their service is down
+func (h *UserSettingsHandler) GetTools(w http.ResponseWriter, r *http.Request) {
+	user := userFromContext(r.Context())
+	overrides, err := h.tools.Overrides(user)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	defaults := h.tools.global.Get()
+	effective := h.tools.global.Get()
+	h.tools.applyOverrides(user, &effective)
+	response := map[string]interface{}{
+		"defaults":  &defaults,
+		"overrides": overrides,
+		"effective": &effective,
+	}
+	if h.executor != nil {
+		response["tools"] = h.executor.ToolStatuses(user)
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// UpdateTools handles PUT /api/me/tools, replacing the user's overrides
+func (h *UserSettingsHandler) UpdateTools(w http.ResponseWriter, r *http.Request) {
+	user := userFromContext(r.Context())
+	if user == "" {
+		http.Error(w, "per-user settings need authentication", http.StatusBadRequest)
+		return
+	}
+
+	var overrides ToolOverrides
+	if err := json.NewDecoder(r.Body).Decode(&overrides); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.tools.SetOverrides(user, overrides); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to save tool settings: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	effective := h.tools.global.Get()
+	overrides.apply(&effective)
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"overrides": overrides,
+		"effective": &effective,
+	})
+}
+
+// UserModelStats are the response statistics of one user
+type UserModelStats struct {
+	User   string       `json:"user"`
+	Models []ModelStats `json:"models"`
+}
+
+// UserStats aggregates response statistics per user in memory
+type UserStats struct {
+	mu    sync.Mutex
+	users map[string]*StatsStore
+}
+
+// NewUserStats creates empty per-user stats
+func NewUserStats() *UserStats {
+	return &UserStats{
+		users: make(map[string]*StatsStore),
+	}
+}
+
+// Record adds the usage and timings of one response of a user
+func (s *UserStats) Record(user, model string, usage *client.Usage, timings *client.Timings) {
+	if usage == nil && timings == nil {
+		return
+	}
+
+	s.mu.Lock()
+	stats, ok := s.users[user]
+	if !ok {
+		stats = NewStatsStore()
+		s.users[user] = stats
+	}
+	s.mu.Unlock()
+
+	stats.Record(model, usage, timings)
+}
+
+// Get returns the stats of a user
+func (s *UserStats) Get(user string) []ModelStats {
+	s.mu.Lock()
+	stats, ok := s.users[user]
+	s.mu.Unlock()
+
+	if !ok {
+		return []ModelStats{}
+	}
+	return stats.List()
+}
+
+// List returns the stats of every user, sorted by user name
+func (s *UserStats) List() []UserModelStats {
+	s.mu.Lock()
+	users := make([]string, 0, len(s.users))
+	for user := range s.users {
+		users = append(users, user)
+	}
+	s.mu.Unlock()
+
+	sort.Strings(users)
+	result := make([]UserModelStats, 0, len(users))
+	for _, user := range users {
+		result = append(result, UserModelStats{User: user, Models: s.Get(user)})
+	}
+	return result
+}
@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aristath/gollama-ui/internal/auth"
+	"github.com/aristath/gollama-ui/internal/client"
+)
+
+// asUser returns a context authenticated as a user with the given role
+func asUser(name, role string) context.Context {
+	return auth.WithIdentity(context.Background(), auth.Identity{Name: name, Role: role, Method: auth.MethodSession})
+}
+
+func TestConversationStore_ForContext(t *testing.T) {
+	dir := t.TempDir()
+	store := NewConversationStore(filepath.Join(dir, "conversations"))
+	store.SetUsersDir(filepath.Join(dir, "users"))
+
+	alice := store.ForContext(asUser("alice", auth.RoleUser))
+	assert.NoError(t, alice.Save(&Conversation{ID: "conv1", Summary: "alice's"}))
+	assert.Same(t, alice, store.ForContext(asUser("alice", auth.RoleUser)))
+
+	conv, err := store.ForContext(asUser("bob", auth.RoleUser)).Get("conv1")
+	assert.NoError(t, err)
+	assert.Nil(t, conv)
+	assert.FileExists(t, filepath.Join(dir, "users", "alice", "conversations", "conv1.json"))
+
+	// Without authentication everyone shares the global store
+	anonymous := auth.WithIdentity(context.Background(), auth.Identity{Role: auth.RoleAdmin, Method: auth.MethodNone})
+	assert.Same(t, store, store.ForContext(anonymous))
+	assert.Same(t, store, store.ForContext(context.Background()))
+}
+
+func TestPersonasHandler_PerUser(t *testing.T) {
+	dir := t.TempDir()
+	store := NewPersonaStore(filepath.Join(dir, "personas.json"))
+	store.SetUsersDir(filepath.Join(dir, "users"))
+	h := NewPersonasHandler(store)
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/personas", strings.NewReader(`{"name":"Analyst"}`))
+	h.Create(rec, req.WithContext(asUser("alice", auth.RoleUser)))
+	assert.Equal(t, http.StatusCreated, rec.Code)
+
+	list := func(ctx context.Context) []Persona {
+		rec := httptest.NewRecorder()
+		h.List(rec, httptest.NewRequest(http.MethodGet, "/api/personas", nil).WithContext(ctx))
+		var body struct {
+			Personas []Persona `json:"personas"`
+		}
+		json.NewDecoder(rec.Body).Decode(&body)
+		return body.Personas
+	}
+
+	assert.Len(t, list(asUser("alice", auth.RoleUser)), 1)
+	assert.Empty(t, list(asUser("bob", auth.RoleUser)))
+	assert.Empty(t, store.List())
+}
+
+func TestUserToolSettings(t *testing.T) {
+	global := NewToolSettings("")
+	global.EnableWebSearch = true
+
+	userTools := NewUserToolSettings(t.TempDir(), global)
+	executor := NewToolExecutor(nil, nil, nil, global)
+	executor.SetUserToolSettings(userTools)
+
+	off, on := false, true
+	assert.NoError(t, userTools.SetOverrides("alice", ToolOverrides{EnableWebSearch: &off, EnableSentinel: &on}))
+
+	names := func(tools []client.Tool) []string {
+		result := []string{}
+		for _, tool := range tools {
+			result = append(result, tool.Function.Name)
+		}
+		return result
+	}
+	assert.Equal(t, []string{"analyze_portfolio"}, names(executor.GetAvailableToolsForUser("alice")))
+	assert.Equal(t, []string{"web_search"}, names(executor.GetAvailableToolsForUser("bob")))
+	assert.Equal(t, []string{"web_search"}, names(executor.GetAvailableTools()))
+
+	// Overrides are read back from the user's directory
+	reloaded := NewUserToolSettings(userTools.dir, global)
+	overrides, err := reloaded.Overrides("alice")
+	assert.NoError(t, err)
+	assert.Equal(t, &off, overrides.EnableWebSearch)
+	assert.Nil(t, overrides.EnableFeeds)
+
+	assert.Error(t, userTools.SetOverrides("", ToolOverrides{}))
+}
+
+func TestUserSettingsHandler_Tools(t *testing.T) {
+	global := NewToolSettings("")
+	global.EnableFeeds = true
+	h := NewUserSettingsHandler(NewUserToolSettings(t.TempDir(), global))
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPut, "/api/me/tools", strings.NewReader(`{"enable_feeds":false}`))
+	h.UpdateTools(rec, req.WithContext(asUser("alice", auth.RoleUser)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = httptest.NewRecorder()
+	h.GetTools(rec, httptest.NewRequest(http.MethodGet, "/api/me/tools", nil).WithContext(asUser("alice", auth.RoleUser)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var body struct {
+		Defaults  map[string]bool `json:"defaults"`
+		Overrides map[string]bool `json:"overrides"`
+		Effective map[string]bool `json:"effective"`
+	}
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&body))
+	assert.True(t, body.Defaults["enable_feeds"])
+	assert.Equal(t, map[string]bool{"enable_feeds": false}, body.Overrides)
+	assert.False(t, body.Effective["enable_feeds"])
+
+	// Anonymous clients have nothing to override
+	rec = httptest.NewRecorder()
+	h.UpdateTools(rec, httptest.NewRequest(http.MethodPut, "/api/me/tools", strings.NewReader(`{}`)))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestChatHandler_JobsPerUser(t *testing.T) {
+	fake := &gatedChatClient{gate: make(chan struct{})}
+	close(fake.gate)
+	dir := t.TempDir()
+	store := NewConversationStore(filepath.Join(dir, "conversations"))
+	store.SetUsersDir(filepath.Join(dir, "users"))
+
+	h := NewChatHandler(fake, nil)
+	h.SetConversationStore(store)
+
+	alice := asUser("alice", auth.RoleUser)
+	rec := httptest.NewRecorder()
+	body := strings.NewReader(`{"model":"m","conversation_id":"conv1","detach":true,"messages":[{"role":"user","content":"hi"}]}`)
+	h.Stream(rec, httptest.NewRequest(http.MethodPost, "/api/chat", body).WithContext(alice))
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+
+	var started Job
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&started))
+	assert.Equal(t, "alice", started.User)
+	waitForJob(t, h, started.ID)
+
+	// The result is saved to alice's conversations
+	conv, err := store.ForContext(alice).Get("conv1")
+	assert.NoError(t, err)
+	if assert.NotNil(t, conv) {
+		assert.Len(t, conv.Messages, 2)
+	}
+
+	listJobs := func(ctx context.Context) []Job {
+		rec := httptest.NewRecorder()
+		h.ListJobs(rec, httptest.NewRequest(http.MethodGet, "/api/jobs", nil).WithContext(ctx))
+		var body struct {
+			Jobs []Job `json:"jobs"`
+		}
+		json.NewDecoder(rec.Body).Decode(&body)
+		return body.Jobs
+	}
+	assert.Len(t, listJobs(alice), 1)
+	assert.Empty(t, listJobs(asUser("bob", auth.RoleUser)))
+	assert.Len(t, listJobs(asUser("root", auth.RoleAdmin)), 1)
+
+	// Other users can neither read nor resume alice's chats
+	_, ok := h.getGeneration(asUser("bob", auth.RoleUser), started.ID)
+	assert.False(t, ok)
+	_, ok = h.getGeneration(alice, started.ID)
+	assert.True(t, ok)
+
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", started.ID)
+	rec = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/jobs/"+started.ID, nil)
+	h.GetJob(rec, req.WithContext(context.WithValue(asUser("bob", auth.RoleUser), chi.RouteCtxKey, rctx)))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestUserStats(t *testing.T) {
+	s := NewUserStats()
+	s.Record("alice", "m", &client.Usage{PromptTokens: 10, CompletionTokens: 5}, nil)
+	s.Record("bob", "m", &client.Usage{PromptTokens: 1, CompletionTokens: 1}, nil)
+	s.Record("carol", "m", nil, nil)
+
+	alice := s.Get("alice")
+	if assert.Len(t, alice, 1) {
+		assert.Equal(t, 10, alice[0].PromptTokens)
+	}
+	assert.Empty(t, s.Get("carol"))
+
+	all := s.List()
+	if assert.Len(t, all, 2) {
+		assert.Equal(t, "alice", all[0].User)
+		assert.Equal(t, "bob", all[1].User)
+	}
+}
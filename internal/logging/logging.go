@@ -0,0 +1,101 @@
+// Package logging configures structured logging with log/slog. It tags
+// records with the ID of the request they belong to and redacts chat content
+// unless content logging is enabled.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+)
+
+// Log formats
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// logContent reports whether message content is logged instead of redacted
+var logContent atomic.Bool
+
+// logLevel is the level of the default logger, which can change at runtime
+var logLevel slog.LevelVar
+
+// Setup installs the default logger writing to w at the given level and in
+// the given format. Messages of the standard log package go through it too.
+func Setup(w io.Writer, level, format string, content bool) error {
+	if err := SetLevel(level); err != nil {
+		return err
+	}
+
+	opts := &slog.HandlerOptions{Level: &logLevel}
+	var handler slog.Handler
+	switch strings.ToLower(format) {
+	case FormatText:
+		handler = slog.NewTextHandler(w, opts)
+	case FormatJSON:
+		handler = slog.NewJSONHandler(w, opts)
+	default:
+		return fmt.Errorf("unknown log format: %s", format)
+	}
+
+	slog.SetDefault(slog.New(NewContextHandler(handler)))
+	SetContent(content)
+	return nil
+}
+
+// SetLevel changes the level of the logger installed by Setup
+func SetLevel(level string) error {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return fmt.Errorf("unknown log level: %s", level)
+	}
+	logLevel.Set(lvl)
+	return nil
+}
+
+// SetContent sets whether message content is logged instead of redacted
+func SetContent(content bool) {
+	logContent.Store(content)
+}
+
+// Content returns a log value for chat content, such as messages, tool
+// arguments or request bodies. Unless content logging is enabled, only the
+// size of the content is logged.
+func Content(s string) slog.Value {
+	if logContent.Load() {
+		return slog.StringValue(s)
+	}
+	return slog.StringValue(fmt.Sprintf("[redacted %d bytes]", len(s)))
+}
+
+// ContextHandler adds the request ID of the record's context to every record
+type ContextHandler struct {
+	slog.Handler
+}
+
+// NewContextHandler wraps h so records carry their request ID
+func NewContextHandler(h slog.Handler) *ContextHandler {
+	return &ContextHandler{Handler: h}
+}
+
+// Handle implements slog.Handler
+func (h *ContextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+// WithAttrs implements slog.Handler
+func (h *ContextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &ContextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+// WithGroup implements slog.Handler
+func (h *ContextHandler) WithGroup(name string) slog.Handler {
+	return &ContextHandler{Handler: h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,105 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// setup installs a JSON logger writing to a buffer for the duration of a test
+func setup(t *testing.T, level string, content bool) *bytes.Buffer {
+	previous := slog.Default()
+	t.Cleanup(func() {
+		slog.SetDefault(previous)
+		logContent.Store(false)
+	})
+
+	var buf bytes.Buffer
+	if err := Setup(&buf, level, FormatJSON, content); err != nil {
+		t.Fatal(err)
+	}
+	return &buf
+}
+
+func TestSetup_Invalid(t *testing.T) {
+	assert.Error(t, Setup(&bytes.Buffer{}, "loud", FormatText, false))
+	assert.Error(t, Setup(&bytes.Buffer{}, "info", "xml", false))
+}
+
+func TestSetup_Level(t *testing.T) {
+	buf := setup(t, "warn", false)
+
+	slog.Info("hidden")
+	slog.Warn("shown")
+
+	assert.NotContains(t, buf.String(), "hidden")
+	assert.Contains(t, buf.String(), "shown")
+
+	// The level can change without replacing the logger
+	assert.NoError(t, SetLevel("debug"))
+	slog.Debug("debugging")
+	assert.Contains(t, buf.String(), "debugging")
+	assert.Error(t, SetLevel("verbose"))
+}
+
+func TestContent(t *testing.T) {
+	buf := setup(t, "info", false)
+	slog.Info("message", "content", Content("secret prompt"))
+	assert.Contains(t, buf.String(), `"content":"[redacted 13 bytes]"`)
+	assert.NotContains(t, buf.String(), "secret")
+
+	buf = setup(t, "info", true)
+	slog.Info("message", "content", Content("secret prompt"))
+	assert.Contains(t, buf.String(), `"content":"secret prompt"`)
+}
+
+func TestRequestIDMiddleware(t *testing.T) {
+	buf := setup(t, "info", false)
+
+	var seen string
+	handler := RequestIDMiddleware(RequestLogger(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = RequestID(r.Context())
+		slog.InfoContext(r.Context(), "handled")
+		w.WriteHeader(http.StatusTeapot)
+	})))
+
+	// A valid ID sent by the client is kept
+	req := httptest.NewRequest(http.MethodGet, "/api/models", nil)
+	req.Header.Set(RequestIDHeader, "client-id.1")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	assert.Equal(t, "client-id.1", seen)
+	assert.Equal(t, "client-id.1", w.Header().Get(RequestIDHeader))
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	if !assert.Len(t, lines, 2) {
+		return
+	}
+	for _, line := range lines {
+		var record map[string]any
+		assert.NoError(t, json.Unmarshal(line, &record))
+		assert.Equal(t, "client-id.1", record["request_id"])
+	}
+
+	var request map[string]any
+	assert.NoError(t, json.Unmarshal(lines[1], &request))
+	assert.Equal(t, "request", request["msg"])
+	assert.Equal(t, "/api/models", request["path"])
+	assert.Equal(t, float64(http.StatusTeapot), request["status"])
+
+	// An invalid one is replaced
+	req = httptest.NewRequest(http.MethodGet, "/api/models", nil)
+	req.Header.Set(RequestIDHeader, "bad id\n")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	assert.NotEqual(t, "bad id\n", seen)
+	assert.Regexp(t, `^[0-9a-f]{16}$`, seen)
+	assert.Equal(t, seen, w.Header().Get(RequestIDHeader))
+}
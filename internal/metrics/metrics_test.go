@@ -0,0 +1,79 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry_Write(t *testing.T) {
+	r := NewRegistry()
+
+	requests := r.NewCounterVec("requests_total", "Requests served.", "route", "status")
+	requests.Inc("/api/chat", "200")
+	requests.Inc("/api/chat", "200")
+	requests.Add(3, "/api/models", "500")
+
+	active := r.NewGaugeVec("active", "Active streams.")
+	active.Add(2)
+	active.Add(-1)
+
+	r.NewGaugeFunc("up", "Whether a service is up.", []string{"service"}, func(emit func(float64, ...string)) {
+		emit(1, "ddgs")
+		emit(0, `sen"tinel`)
+	})
+
+	latency := r.NewHistogramVec("latency_seconds", "Latency.", []float64{0.1, 1}, "model")
+	latency.Observe(0.05, "m")
+	latency.Observe(0.1, "m")
+	latency.Observe(0.5, "m")
+	latency.Observe(5, "m")
+
+	var b strings.Builder
+	assert.NoError(t, r.Write(&b))
+
+	assert.Equal(t, `# HELP requests_total Requests served.
+# TYPE requests_total counter
+requests_total{route="/api/chat",status="200"} 2
+requests_total{route="/api/models",status="500"} 3
+# HELP active Active streams.
+# TYPE active gauge
+active 1
+# HELP up Whether a service is up.
+# TYPE up gauge
+up{service="ddgs"} 1
+up{service="sen\"tinel"} 0
+# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{model="m",le="0.1"} 2
+latency_seconds_bucket{model="m",le="1"} 3
+latency_seconds_bucket{model="m",le="+Inf"} 4
+latency_seconds_sum{model="m"} 5.65
+latency_seconds_count{model="m"} 4
+`, b.String())
+}
+
+func TestRegistry_Panics(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounterVec("c", "A counter.", "a")
+
+	assert.Panics(t, func() { r.NewGaugeVec("c", "Duplicate.") })
+	assert.Panics(t, func() { c.Inc() })
+	assert.Panics(t, func() { c.Add(-1, "x") })
+	assert.Panics(t, func() { r.NewHistogramVec("h", "Unsorted.", []float64{1, 0.5}) })
+}
+
+func TestRegistry_ServeHTTP(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounterVec("c", "A counter.").Inc()
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Body.String(), "c 1\n")
+}
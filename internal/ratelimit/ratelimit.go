@@ -0,0 +1,261 @@
+// Package ratelimit limits how often and how many requests each client can
+// make, so one client can't monopolize the server
+package ratelimit
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aristath/gollama-ui/internal/auth"
+)
+
+// maxBuckets is the number of clients tracked before idle ones are dropped
+const maxBuckets = 4096
+
+// streamRetryAfter is suggested to clients with too many open streams. Streams
+// last as long as a generation, so there is no exact time.
+const streamRetryAfter = 10 * time.Second
+
+// Limit is a token bucket: clients can make Burst requests at once, and
+// regain PerMinute requests every minute. A zero PerMinute disables it.
+type Limit struct {
+	PerMinute float64 `json:"per_minute"`
+	Burst     int     `json:"burst"`
+}
+
+// Config holds the limits applied to each client
+type Config struct {
+	Chat      Limit `json:"chat"`       // Chat requests
+	Tools     Limit `json:"tools"`      // Tool calls made by models
+	ModelLoad Limit `json:"model_load"` // Model loads and unloads
+	Login     Limit `json:"login"`      // Password sign-in attempts
+
+	// MaxStreams caps the chat streams a client has open at once; 0 disables it
+	MaxStreams int `json:"max_streams"`
+}
+
+// DefaultConfig returns limits generous enough for interactive use
+func DefaultConfig() Config {
+	return Config{
+		Chat:       Limit{PerMinute: 20, Burst: 5},
+		Tools:      Limit{PerMinute: 10, Burst: 3},
+		ModelLoad:  Limit{PerMinute: 4, Burst: 2},
+		Login:      Limit{PerMinute: 5, Burst: 5},
+		MaxStreams: 4,
+	}
+}
+
+// LoadConfig reads limits from a JSON file. Limits missing from the file
+// keep their defaults; an empty path returns the defaults.
+func LoadConfig(path string) (Config, error) {
+	cfg := DefaultConfig()
+	if path == "" {
+		return cfg, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, fmt.Errorf("failed to read rate limits: %w", err)
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("failed to parse rate limits: %w", err)
+	}
+
+	for name, l := range map[string]Limit{"chat": cfg.Chat, "tools": cfg.Tools, "model_load": cfg.ModelLoad} {
+		if l.PerMinute < 0 || l.Burst < 0 {
+			return cfg, fmt.Errorf("invalid %s limit: values must not be negative", name)
+		}
+	}
+	if cfg.MaxStreams < 0 {
+		return cfg, fmt.Errorf("invalid max_streams: must not be negative")
+	}
+
+	return cfg, nil
+}
+
+// bucket holds the tokens left to a client
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter applies a Limit to each client. A nil Limiter allows everything.
+type Limiter struct {
+	rate  float64 // Tokens regained per second
+	burst float64
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	now     func() time.Time
+}
+
+// NewLimiter creates a limiter, or returns nil when l is disabled
+func NewLimiter(l Limit) *Limiter {
+	if l.PerMinute <= 0 {
+		return nil
+	}
+	burst := l.Burst
+	if burst < 1 {
+		burst = 1
+	}
+	return &Limiter{
+		rate:    l.PerMinute / 60,
+		burst:   float64(burst),
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// Allow takes a token from the bucket of key. When none is left, it returns
+// false and how long until one is.
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	if l == nil {
+		return true, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	b, ok := l.buckets[key]
+	if !ok {
+		if len(l.buckets) >= maxBuckets {
+			l.pruneLocked(now)
+		}
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// pruneLocked drops the buckets that have refilled, as their clients have
+// been idle. l.mu must be held.
+func (l *Limiter) pruneLocked(now time.Time) {
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// Middleware rejects requests from clients over the limit
+func (l *Limiter) Middleware(next http.Handler) http.Handler {
+	if l == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ok, retryAfter := l.Allow(ClientKey(r)); !ok {
+			Reject(w, r, retryAfter)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// StreamLimiter caps the requests each client has in flight. A nil
+// StreamLimiter allows everything.
+type StreamLimiter struct {
+	max int
+
+	mu     sync.Mutex
+	active map[string]int
+}
+
+// NewStreamLimiter creates a stream limiter, or returns nil when limit is 0
+func NewStreamLimiter(limit int) *StreamLimiter {
+	if limit <= 0 {
+		return nil
+	}
+	return &StreamLimiter{
+		max:    limit,
+		active: make(map[string]int),
+	}
+}
+
+// Acquire counts a new stream of key, unless it has the maximum open
+func (s *StreamLimiter) Acquire(key string) bool {
+	if s == nil {
+		return true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.active[key] >= s.max {
+		return false
+	}
+	s.active[key]++
+	return true
+}
+
+// Release ends a stream of key
+func (s *StreamLimiter) Release(key string) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.active[key]--; s.active[key] <= 0 {
+		delete(s.active, key)
+	}
+}
+
+// Middleware rejects requests from clients with too many streams open
+func (s *StreamLimiter) Middleware(next http.Handler) http.Handler {
+	if s == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := ClientKey(r)
+		if !s.Acquire(key) {
+			Reject(w, r, streamRetryAfter)
+			return
+		}
+		defer s.Release(key)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ClientKey identifies the client of a request: its user when authenticated,
+// otherwise its IP address
+func ClientKey(r *http.Request) string {
+	if id, ok := auth.FromContext(r.Context()); ok && id.Method != auth.MethodNone {
+		return "user:" + id.Name
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// Reject responds 429 Too Many Requests, with the seconds to wait before
+// retrying in Retry-After
+func Reject(w http.ResponseWriter, r *http.Request, retryAfter time.Duration) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	slog.WarnContext(r.Context(), "Rate limited", "client", ClientKey(r), "retry_after", seconds)
+
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	http.Error(w, "too many requests", http.StatusTooManyRequests)
+}
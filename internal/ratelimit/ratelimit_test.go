@@ -0,0 +1,122 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aristath/gollama-ui/internal/auth"
+)
+
+func TestLimiter_Allow(t *testing.T) {
+	l := NewLimiter(Limit{PerMinute: 6, Burst: 2})
+	now := time.Unix(0, 0)
+	l.now = func() time.Time { return now }
+
+	ok, _ := l.Allow("a")
+	assert.True(t, ok)
+	ok, _ = l.Allow("a")
+	assert.True(t, ok)
+
+	ok, retryAfter := l.Allow("a")
+	assert.False(t, ok)
+	assert.Equal(t, 10*time.Second, retryAfter)
+
+	// Other clients have their own bucket
+	ok, _ = l.Allow("b")
+	assert.True(t, ok)
+
+	now = now.Add(5 * time.Second)
+	ok, retryAfter = l.Allow("a")
+	assert.False(t, ok)
+	assert.Equal(t, 5*time.Second, retryAfter)
+
+	now = now.Add(5 * time.Second)
+	ok, _ = l.Allow("a")
+	assert.True(t, ok)
+}
+
+func TestLimiter_Disabled(t *testing.T) {
+	l := NewLimiter(Limit{})
+	assert.Nil(t, l)
+	ok, _ := l.Allow("a")
+	assert.True(t, ok)
+}
+
+func TestLimiter_Middleware(t *testing.T) {
+	l := NewLimiter(Limit{PerMinute: 1, Burst: 1})
+	handler := l.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	request := func(remoteAddr string, id *auth.Identity) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/chat", nil)
+		req.RemoteAddr = remoteAddr
+		if id != nil {
+			req = req.WithContext(auth.WithIdentity(context.Background(), *id))
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	assert.Equal(t, http.StatusOK, request("10.0.0.2:1234", nil).Code)
+	rec := request("10.0.0.2:5678", nil)
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "60", rec.Header().Get("Retry-After"))
+
+	// Users are limited by name, wherever they connect from
+	alice := &auth.Identity{Name: "alice", Role: auth.RoleUser, Method: auth.MethodToken}
+	assert.Equal(t, http.StatusOK, request("10.0.0.2:1234", alice).Code)
+	assert.Equal(t, http.StatusTooManyRequests, request("10.0.0.3:1234", alice).Code)
+}
+
+func TestStreamLimiter(t *testing.T) {
+	s := NewStreamLimiter(1)
+	release := make(chan struct{})
+	started := make(chan struct{})
+	handler := s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/chat", nil)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}()
+	<-started
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "10", rec.Header().Get("Retry-After"))
+
+	close(release)
+	<-done
+	assert.True(t, s.Acquire(ClientKey(req)))
+}
+
+func TestLoadConfig(t *testing.T) {
+	cfg, err := LoadConfig("")
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultConfig(), cfg)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "limits.json")
+	os.WriteFile(path, []byte(`{"chat": {"per_minute": 2, "burst": 1}, "max_streams": 0}`), 0644)
+	cfg, err = LoadConfig(path)
+	assert.NoError(t, err)
+	assert.Equal(t, Limit{PerMinute: 2, Burst: 1}, cfg.Chat)
+	assert.Equal(t, DefaultConfig().Tools, cfg.Tools)
+	assert.Zero(t, cfg.MaxStreams)
+
+	os.WriteFile(path, []byte(`{"tools": {"per_minute": -1}}`), 0644)
+	_, err = LoadConfig(path)
+	assert.Error(t, err)
+}
@@ -0,0 +1,240 @@
+// Package schema validates JSON values against a practical subset of JSON Schema.
+//
+// Supported keywords: type, enum, const, properties, required,
+// additionalProperties, items, minItems, maxItems, minLength, maxLength,
+// pattern, minimum, maximum, allOf, anyOf and oneOf. Unknown keywords are
+// ignored, which matches how llama.cpp treats schemas it cannot enforce.
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ValidateJSON parses data as JSON and validates it against schema
+func ValidateJSON(schema map[string]interface{}, data string) []string {
+	var value interface{}
+	if err := json.Unmarshal([]byte(strings.TrimSpace(data)), &value); err != nil {
+		return []string{fmt.Sprintf("invalid JSON: %v", err)}
+	}
+	if schema == nil {
+		return nil
+	}
+	return Validate(schema, value)
+}
+
+// Validate checks a decoded JSON value against schema and returns every violation found
+func Validate(schema map[string]interface{}, value interface{}) []string {
+	var errs []string
+	validate(schema, value, "$", &errs)
+	return errs
+}
+
+// validate appends the violations of value at path to errs
+func validate(schema map[string]interface{}, value interface{}, path string, errs *[]string) {
+	if t, ok := schema["type"]; ok && !matchesType(t, value) {
+		*errs = append(*errs, fmt.Sprintf("%s: expected type %v, got %s", path, t, typeOf(value)))
+		return
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, candidate := range enum {
+			if reflect.DeepEqual(candidate, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			*errs = append(*errs, fmt.Sprintf("%s: value is not one of %v", path, enum))
+		}
+	}
+
+	if c, ok := schema["const"]; ok && !reflect.DeepEqual(c, value) {
+		*errs = append(*errs, fmt.Sprintf("%s: value must be %v", path, c))
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		validateObject(schema, v, path, errs)
+	case []interface{}:
+		validateArray(schema, v, path, errs)
+	case string:
+		validateString(schema, v, path, errs)
+	case float64:
+		validateNumber(schema, v, path, errs)
+	}
+
+	if all, ok := schema["allOf"].([]interface{}); ok {
+		for _, sub := range all {
+			if subSchema, ok := sub.(map[string]interface{}); ok {
+				validate(subSchema, value, path, errs)
+			}
+		}
+	}
+
+	if anyOf, ok := schema["anyOf"].([]interface{}); ok {
+		if countMatches(anyOf, value, path) == 0 {
+			*errs = append(*errs, fmt.Sprintf("%s: value does not match any of the allowed schemas", path))
+		}
+	}
+
+	if oneOf, ok := schema["oneOf"].([]interface{}); ok {
+		if n := countMatches(oneOf, value, path); n != 1 {
+			*errs = append(*errs, fmt.Sprintf("%s: value matches %d schemas, expected exactly one", path, n))
+		}
+	}
+}
+
+// validateObject checks object keywords
+func validateObject(schema map[string]interface{}, obj map[string]interface{}, path string, errs *[]string) {
+	if required, ok := schema["required"].([]interface{}); ok {
+		for _, r := range required {
+			name, _ := r.(string)
+			if _, present := obj[name]; !present {
+				*errs = append(*errs, fmt.Sprintf("%s: missing required property %q", path, name))
+			}
+		}
+	}
+
+	properties, _ := schema["properties"].(map[string]interface{})
+
+	// Iterate in a stable order so error messages are deterministic
+	keys := make([]string, 0, len(obj))
+	for key := range obj {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		propPath := path + "." + key
+		if propSchema, ok := properties[key].(map[string]interface{}); ok {
+			validate(propSchema, obj[key], propPath, errs)
+			continue
+		}
+
+		switch additional := schema["additionalProperties"].(type) {
+		case bool:
+			if !additional {
+				*errs = append(*errs, fmt.Sprintf("%s: additional property is not allowed", propPath))
+			}
+		case map[string]interface{}:
+			validate(additional, obj[key], propPath, errs)
+		}
+	}
+}
+
+// validateArray checks array keywords
+func validateArray(schema map[string]interface{}, arr []interface{}, path string, errs *[]string) {
+	if min, ok := schema["minItems"].(float64); ok && float64(len(arr)) < min {
+		*errs = append(*errs, fmt.Sprintf("%s: expected at least %v items, got %d", path, min, len(arr)))
+	}
+	if max, ok := schema["maxItems"].(float64); ok && float64(len(arr)) > max {
+		*errs = append(*errs, fmt.Sprintf("%s: expected at most %v items, got %d", path, max, len(arr)))
+	}
+
+	if items, ok := schema["items"].(map[string]interface{}); ok {
+		for i, item := range arr {
+			validate(items, item, fmt.Sprintf("%s[%d]", path, i), errs)
+		}
+	}
+}
+
+// validateString checks string keywords
+func validateString(schema map[string]interface{}, str string, path string, errs *[]string) {
+	length := len([]rune(str))
+	if min, ok := schema["minLength"].(float64); ok && float64(length) < min {
+		*errs = append(*errs, fmt.Sprintf("%s: expected at least %v characters", path, min))
+	}
+	if max, ok := schema["maxLength"].(float64); ok && float64(length) > max {
+		*errs = append(*errs, fmt.Sprintf("%s: expected at most %v characters", path, max))
+	}
+	if pattern, ok := schema["pattern"].(string); ok {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			*errs = append(*errs, fmt.Sprintf("%s: invalid pattern %q in schema", path, pattern))
+		} else if !re.MatchString(str) {
+			*errs = append(*errs, fmt.Sprintf("%s: value does not match pattern %q", path, pattern))
+		}
+	}
+}
+
+// validateNumber checks numeric keywords
+func validateNumber(schema map[string]interface{}, num float64, path string, errs *[]string) {
+	if min, ok := schema["minimum"].(float64); ok && num < min {
+		*errs = append(*errs, fmt.Sprintf("%s: value %v is below minimum %v", path, num, min))
+	}
+	if max, ok := schema["maximum"].(float64); ok && num > max {
+		*errs = append(*errs, fmt.Sprintf("%s: value %v is above maximum %v", path, num, max))
+	}
+}
+
+// countMatches returns how many of the schemas value satisfies
+func countMatches(schemas []interface{}, value interface{}, path string) int {
+	n := 0
+	for _, sub := range schemas {
+		subSchema, ok := sub.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		var subErrs []string
+		validate(subSchema, value, path, &subErrs)
+		if len(subErrs) == 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// matchesType reports whether value has the type (or one of the types) in t
+func matchesType(t interface{}, value interface{}) bool {
+	switch types := t.(type) {
+	case string:
+		return matchesSingleType(types, value)
+	case []interface{}:
+		for _, candidate := range types {
+			if name, ok := candidate.(string); ok && matchesSingleType(name, value) {
+				return true
+			}
+		}
+		return false
+	default:
+		return true
+	}
+}
+
+// matchesSingleType reports whether value has the named JSON type
+func matchesSingleType(name string, value interface{}) bool {
+	actual := typeOf(value)
+	if name == "number" && actual == "integer" {
+		return true
+	}
+	return name == actual
+}
+
+// typeOf returns the JSON type name of a decoded value
+func typeOf(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == float64(int64(v)) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
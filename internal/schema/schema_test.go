@@ -0,0 +1,75 @@
+package schema
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func mustSchema(t *testing.T, s string) map[string]interface{} {
+	var schema map[string]interface{}
+	if err := json.Unmarshal([]byte(s), &schema); err != nil {
+		t.Fatalf("invalid test schema: %v", err)
+	}
+	return schema
+}
+
+const personSchema = `{
+	"type": "object",
+	"properties": {
+		"name": {"type": "string", "minLength": 1},
+		"age": {"type": "integer", "minimum": 0},
+		"tags": {"type": "array", "items": {"type": "string"}, "maxItems": 2},
+		"role": {"enum": ["admin", "user"]}
+	},
+	"required": ["name", "age"],
+	"additionalProperties": false
+}`
+
+func TestValidateJSON_Valid(t *testing.T) {
+	schema := mustSchema(t, personSchema)
+
+	errs := ValidateJSON(schema, `{"name": "Ada", "age": 36, "tags": ["math"], "role": "admin"}`)
+	assert.Empty(t, errs)
+}
+
+func TestValidateJSON_InvalidJSON(t *testing.T) {
+	schema := mustSchema(t, personSchema)
+
+	errs := ValidateJSON(schema, `{"name": "Ada",`)
+	assert.Len(t, errs, 1)
+	assert.Contains(t, errs[0], "invalid JSON")
+}
+
+func TestValidateJSON_Violations(t *testing.T) {
+	schema := mustSchema(t, personSchema)
+
+	errs := ValidateJSON(schema, `{"name": "", "age": 1.5, "tags": ["a", "b", 3], "role": "root", "extra": true}`)
+	assert.Contains(t, errs, `$.name: expected at least 1 characters`)
+	assert.Contains(t, errs, `$.age: expected type integer, got number`)
+	assert.Contains(t, errs, `$.tags: expected at most 2 items, got 3`)
+	assert.Contains(t, errs, `$.tags[2]: expected type string, got integer`)
+	assert.Contains(t, errs, `$.extra: additional property is not allowed`)
+	assert.Len(t, errs, 6)
+}
+
+func TestValidateJSON_MissingRequired(t *testing.T) {
+	schema := mustSchema(t, personSchema)
+
+	errs := ValidateJSON(schema, `{"name": "Ada"}`)
+	assert.Equal(t, []string{`$: missing required property "age"`}, errs)
+}
+
+func TestValidateJSON_AnyOf(t *testing.T) {
+	schema := mustSchema(t, `{"anyOf": [{"type": "string"}, {"type": "null"}]}`)
+
+	assert.Empty(t, ValidateJSON(schema, `"text"`))
+	assert.Empty(t, ValidateJSON(schema, `null`))
+	assert.Len(t, ValidateJSON(schema, `42`), 1)
+}
+
+func TestValidateJSON_NilSchemaOnlyChecksSyntax(t *testing.T) {
+	assert.Empty(t, ValidateJSON(nil, `{"anything": [1, 2, 3]}`))
+	assert.Len(t, ValidateJSON(nil, `not json`), 1)
+}
@@ -1,55 +1,193 @@
 package server
 
 import (
+	"fmt"
+	"log/slog"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
 
+	"github.com/aristath/gollama-ui/internal/auth"
 	"github.com/aristath/gollama-ui/internal/handlers"
+	"github.com/aristath/gollama-ui/internal/logging"
+	"github.com/aristath/gollama-ui/internal/metrics"
+	"github.com/aristath/gollama-ui/internal/ratelimit"
+	"github.com/aristath/gollama-ui/internal/static"
+	"github.com/aristath/gollama-ui/web"
 )
 
+// requestDurationBuckets are the latency buckets of HTTP requests. Chat
+// streams stay open for the whole generation.
+var requestDurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300}
+
+// Handlers groups the HTTP handlers served by the server
+type Handlers struct {
+	Models   *handlers.ModelsHandler
+	Chat     *handlers.ChatHandler
+	Unload   *handlers.UnloadHandler
+	Load     *handlers.LoadHandler
+	Settings *handlers.SettingsHandler
+	Presets  *handlers.PresetsHandler
+	Personas *handlers.PersonasHandler
+	Backends *handlers.BackendsHandler
+
+	// UserSettings serves the settings users change for themselves
+	UserSettings *handlers.UserSettingsHandler
+
+	// ChatTimeout serves the chat timeout setting
+	ChatTimeout *handlers.ChatTimeoutHandler
+
+	// Feeds saves custom feeds changed in the UI when set
+	Feeds *handlers.FeedSettings
+
+	// Health serves the /healthz and /readyz probes when set
+	Health *handlers.HealthHandler
+
+	// Metrics is served at /metrics and records every request when set
+	Metrics *metrics.Registry
+
+	// Auth authenticates API requests; nil disables authentication
+	Auth *auth.Authenticator
+}
+
+// Config holds the settings of the server
+type Config struct {
+	// StaticDir overrides the embedded web UI with the files of a directory,
+	// for editing the UI without rebuilding
+	StaticDir string
+
+	// AllowedOrigins are the other origins, such as
+	// "https://dashboard.example.com", that may call the API from a browser.
+	// By default only the UI served by this server can.
+	AllowedOrigins []string
+
+	// RateLimits are applied to each client; the zero value disables them
+	RateLimits ratelimit.Config
+}
+
 // Server holds the HTTP server and dependencies
 type Server struct {
-	router        *chi.Mux
-	modelsHandler *handlers.ModelsHandler
-	chatHandler   *handlers.ChatHandler
-	unloadHandler *handlers.UnloadHandler
-	staticDir     string
+	router   *chi.Mux
+	handlers Handlers
+	static   http.Handler
+	origins  *http.CrossOriginProtection
+
+	chatLimit  *ratelimit.Limiter
+	modelLimit *ratelimit.Limiter
+	loginLimit *ratelimit.Limiter
+	streams    *ratelimit.StreamLimiter
 }
 
 // New creates a new server instance
-func New(modelsHandler *handlers.ModelsHandler, chatHandler *handlers.ChatHandler, unloadHandler *handlers.UnloadHandler, staticDir string) *Server {
+func New(h Handlers, cfg Config) (*Server, error) {
 	s := &Server{
-		router:        chi.NewRouter(),
-		modelsHandler: modelsHandler,
-		chatHandler:   chatHandler,
-		unloadHandler: unloadHandler,
-		staticDir:     staticDir,
+		router:   chi.NewRouter(),
+		handlers: h,
+		origins:  http.NewCrossOriginProtection(),
+
+		chatLimit:  ratelimit.NewLimiter(cfg.RateLimits.Chat),
+		modelLimit: ratelimit.NewLimiter(cfg.RateLimits.ModelLoad),
+		loginLimit: ratelimit.NewLimiter(cfg.RateLimits.Login),
+		streams:    ratelimit.NewStreamLimiter(cfg.RateLimits.MaxStreams),
+	}
+
+	for _, origin := range cfg.AllowedOrigins {
+		if origin == "*" {
+			return nil, fmt.Errorf("allowing every origin would let any website use the API; list the origins instead")
+		}
+		if err := s.origins.AddTrustedOrigin(origin); err != nil {
+			return nil, fmt.Errorf("invalid allowed origin %q: %w", origin, err)
+		}
 	}
+	s.origins.SetDenyHandler(http.HandlerFunc(rejectCrossOrigin))
 
-	s.setupMiddleware()
+	if cfg.StaticDir != "" {
+		s.static = static.Dir(cfg.StaticDir)
+	} else {
+		assets, err := static.New(web.FS)
+		if err != nil {
+			return nil, err
+		}
+		s.static = assets
+	}
+
+	s.setupMiddleware(cfg.AllowedOrigins)
 	s.setupRoutes()
 
-	return s
+	return s, nil
 }
 
 // setupMiddleware configures middleware
-func (s *Server) setupMiddleware() {
-	// CORS middleware - allow all origins for local development
-	s.router.Use(cors.Handler(cors.Options{
-		AllowedOrigins:   []string{"*"},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-		ExposedHeaders:   []string{"Link"},
-		AllowCredentials: false,
-		MaxAge:           300,
-	}))
-
-	// Request logging
-	s.router.Use(middleware.Logger)
+func (s *Server) setupMiddleware(allowedOrigins []string) {
+	// Without allowed origins no CORS headers are sent, so browsers only let
+	// the UI served from this origin read responses
+	if len(allowedOrigins) > 0 {
+		s.router.Use(cors.Handler(cors.Options{
+			AllowedOrigins:   allowedOrigins,
+			AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+			AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "Last-Event-ID"},
+			ExposedHeaders:   []string{"Link", logging.RequestIDHeader},
+			AllowCredentials: false,
+			MaxAge:           300,
+		}))
+	}
+
+	// Request IDs and logging
+	s.router.Use(logging.RequestIDMiddleware)
+	s.router.Use(logging.RequestLogger)
 	s.router.Use(middleware.Recoverer)
+
+	// CORS only controls reading responses: browsers still send cross-origin
+	// form posts and simple requests, so reject state-changing requests from
+	// other origins
+	s.router.Use(s.origins.Handler)
+
+	if s.handlers.Metrics != nil {
+		s.router.Use(requestMetrics(s.handlers.Metrics))
+	}
+}
+
+// rejectCrossOrigin responds to a state-changing request sent by a browser
+// from another origin
+func rejectCrossOrigin(w http.ResponseWriter, r *http.Request) {
+	slog.WarnContext(r.Context(), "Rejected cross-origin request",
+		"origin", r.Header.Get("Origin"),
+		"sec_fetch_site", r.Header.Get("Sec-Fetch-Site"),
+	)
+	http.Error(w, "cross-origin request rejected", http.StatusForbidden)
+}
+
+// requestMetrics counts requests and their latency per route pattern, so
+// paths with IDs share one series
+func requestMetrics(reg *metrics.Registry) func(http.Handler) http.Handler {
+	requests := reg.NewCounterVec("gollama_http_requests_total",
+		"HTTP requests served.", "method", "route", "status")
+	durations := reg.NewHistogramVec("gollama_http_request_duration_seconds",
+		"Duration of HTTP requests.", requestDurationBuckets, "method", "route")
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r)
+
+			route := "unmatched"
+			if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+				route = rctx.RoutePattern()
+			}
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			requests.Inc(r.Method, route, strconv.Itoa(status))
+			durations.Observe(time.Since(start).Seconds(), r.Method, route)
+		})
+	}
 }
 
 // setupRoutes configures all routes
@@ -57,23 +195,83 @@ func (s *Server) setupRoutes() {
 	// API routes - must be registered before catch-all
 	// Order matters: more specific routes first
 	s.router.Route("/api", func(r chi.Router) {
-		r.Post("/models/{model}/unload", s.unloadHandler.Unload)
-		r.Get("/models", s.modelsHandler.List)
-		r.Post("/chat", s.chatHandler.Stream)
-	})
-
-	// Serve static files - root path serves index.html
-	s.router.Get("/*", func(w http.ResponseWriter, r *http.Request) {
-		// For root path, serve index.html
-		if r.URL.Path == "/" {
-			http.ServeFile(w, r, s.staticDir+"/index.html")
-			return
+		if s.handlers.Auth != nil {
+			r.With(s.loginLimit.Middleware).Post("/auth/login", s.handlers.Auth.Login)
+			r.Post("/auth/logout", s.handlers.Auth.Logout)
 		}
-		
-		// Serve other static files
-		fs := http.FileServer(http.Dir(s.staticDir))
-		fs.ServeHTTP(w, r)
+
+		// Everything else needs an authenticated client
+		r.Group(func(r chi.Router) {
+			r.Use(s.handlers.Auth.Middleware)
+
+			r.Get("/auth/me", auth.Me)
+			r.Get("/models/status", s.handlers.Load.Status)
+			r.Get("/models/available", s.handlers.Load.Available)
+			r.Get("/models", s.handlers.Models.List)
+			r.Get("/backends", s.handlers.Backends.Status)
+			r.Get("/backends/events", s.handlers.Backends.Events)
+			r.With(s.chatLimit.Middleware, s.streams.Middleware).Post("/chat", s.handlers.Chat.Stream)
+			r.Post("/chat/{id}/cancel", s.handlers.Chat.Cancel)
+			r.With(s.streams.Middleware).Get("/chat/{id}/stream", s.handlers.Chat.Resume)
+			r.Get("/jobs", s.handlers.Chat.ListJobs)
+			r.Get("/jobs/{id}", s.handlers.Chat.GetJob)
+			r.Get("/stats", s.handlers.Chat.Stats)
+			r.Get("/me/tools", s.handlers.UserSettings.GetTools)
+			r.Put("/me/tools", s.handlers.UserSettings.UpdateTools)
+
+			r.Route("/personas", func(r chi.Router) {
+				r.Get("/", s.handlers.Personas.List)
+				r.Post("/", s.handlers.Personas.Create)
+				r.Get("/{id}", s.handlers.Personas.Get)
+				r.Put("/{id}", s.handlers.Personas.Update)
+				r.Delete("/{id}", s.handlers.Personas.Delete)
+			})
+
+			// Loading models and changing settings affects every user
+			r.Group(func(r chi.Router) {
+				r.Use(auth.RequireAdmin)
+
+				r.With(s.modelLimit.Middleware).Post("/models/{model}/load", s.handlers.Load.Load)
+				r.With(s.modelLimit.Middleware).Post("/models/{model}/unload", s.handlers.Unload.Unload)
+				r.Get("/admin/stats", s.handlers.Chat.AllStats)
+
+				if s.handlers.Auth != nil {
+					r.Route("/admin/users", func(r chi.Router) {
+						r.Get("/", s.handlers.Auth.ListUsers)
+						r.Put("/{name}", s.handlers.Auth.PutUser)
+						r.Delete("/{name}", s.handlers.Auth.DeleteUser)
+					})
+				}
+
+				r.Route("/settings", func(r chi.Router) {
+					r.Get("/tools", s.handlers.Settings.GetTools)
+					r.Post("/tools", s.handlers.Settings.UpdateTools)
+					r.Get("/feeds", s.handlers.Settings.GetFeeds)
+					r.With(s.handlers.Feeds.SaveUpdates).Post("/feeds", s.handlers.Settings.UpdateFeeds)
+					r.Get("/chat-timeout", s.handlers.ChatTimeout.Get)
+					r.Post("/chat-timeout", s.handlers.ChatTimeout.Update)
+					r.Get("/presets", s.handlers.Presets.List)
+					// Model names can contain slashes, as in backend/model
+					r.Put("/presets/*", s.handlers.Presets.Update)
+					r.Delete("/presets/*", s.handlers.Presets.Delete)
+				})
+			})
+		})
 	})
+
+	// Probes are public so orchestrators and monitors can call them without
+	// credentials
+	if s.handlers.Health != nil {
+		s.router.Get("/healthz", s.handlers.Health.Live)
+		s.router.Get("/readyz", s.handlers.Health.Ready)
+	}
+
+	if s.handlers.Metrics != nil {
+		s.router.Method(http.MethodGet, "/metrics", s.handlers.Auth.Middleware(s.handlers.Metrics))
+	}
+
+	// Serve the web UI
+	s.router.Method(http.MethodGet, "/*", s.static)
 }
 
 // ServeHTTP implements http.Handler
@@ -0,0 +1,232 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/aristath/gollama-ui/internal/auth"
+	"github.com/aristath/gollama-ui/internal/client"
+	"github.com/aristath/gollama-ui/internal/handlers"
+	"github.com/aristath/gollama-ui/internal/ratelimit"
+)
+
+// gatedChatClient streams "a", then waits for the gate before finishing
+type gatedChatClient struct {
+	gate chan struct{}
+}
+
+func (f *gatedChatClient) ChatStream(ctx context.Context, req client.ChatRequest) (<-chan client.ChatResponse, error) {
+	ch := make(chan client.ChatResponse)
+	go func() {
+		defer close(ch)
+		ch <- client.ChatResponse{Message: client.ChatMessage{Role: "assistant", Content: "a"}}
+		select {
+		case <-f.gate:
+		case <-ctx.Done():
+			return
+		}
+		ch <- client.ChatResponse{Message: client.ChatMessage{Role: "assistant", Content: "b"}, Done: true}
+	}()
+	return ch, nil
+}
+
+func newTestServer(t *testing.T, allowedOrigins ...string) *Server {
+	store := handlers.NewPersonaStore(filepath.Join(t.TempDir(), "personas.json"))
+	s, err := New(Handlers{Personas: handlers.NewPersonasHandler(store)}, Config{
+		StaticDir:      t.TempDir(),
+		AllowedOrigins: allowedOrigins,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// createPersona posts a persona with the given browser headers
+func createPersona(s *Server, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "http://pi.local:3000/api/personas", strings.NewReader(`{"name":"Analyst"}`))
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestServer_RejectsCrossOriginRequests(t *testing.T) {
+	s := newTestServer(t)
+
+	tests := map[string]struct {
+		headers map[string]string
+		status  int
+	}{
+		"same origin":       {map[string]string{"Sec-Fetch-Site": "same-origin", "Origin": "http://pi.local:3000"}, http.StatusCreated},
+		"non-browser":       {nil, http.StatusCreated},
+		"cross site":        {map[string]string{"Sec-Fetch-Site": "cross-site", "Origin": "https://evil.example"}, http.StatusForbidden},
+		"same site":         {map[string]string{"Sec-Fetch-Site": "same-site", "Origin": "http://router.local"}, http.StatusForbidden},
+		"old browser":       {map[string]string{"Origin": "https://evil.example"}, http.StatusForbidden},
+		"old browser, same": {map[string]string{"Origin": "http://pi.local:3000"}, http.StatusCreated},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.status, createPersona(s, tt.headers).Code)
+		})
+	}
+
+	// Reading stays possible, but without CORS headers browsers hide the response
+	req := httptest.NewRequest(http.MethodGet, "/api/personas", nil)
+	req.Header.Set("Origin", "https://evil.example")
+	req.Header.Set("Sec-Fetch-Site", "cross-site")
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"))
+}
+
+func TestServer_AllowedOrigins(t *testing.T) {
+	s := newTestServer(t, "https://dashboard.example.com")
+
+	rec := createPersona(s, map[string]string{"Sec-Fetch-Site": "cross-site", "Origin": "https://dashboard.example.com"})
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, "https://dashboard.example.com", rec.Header().Get("Access-Control-Allow-Origin"))
+
+	rec = createPersona(s, map[string]string{"Sec-Fetch-Site": "cross-site", "Origin": "https://evil.example"})
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"))
+}
+
+func TestNew_InvalidOrigins(t *testing.T) {
+	for _, origin := range []string{"*", "dashboard.example.com", "https://dashboard.example.com/path"} {
+		_, err := New(Handlers{}, Config{AllowedOrigins: []string{origin}})
+		assert.Error(t, err, origin)
+	}
+}
+
+func TestServer_StreamsOverTLS(t *testing.T) {
+	fake := &gatedChatClient{gate: make(chan struct{})}
+	s, err := New(Handlers{Chat: handlers.NewChatHandler(fake, nil)}, Config{StaticDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewUnstartedServer(s)
+	ts.EnableHTTP2 = true
+	ts.StartTLS()
+	defer ts.Close()
+
+	body := strings.NewReader(`{"model":"m","messages":[{"role":"user","content":"hi"}]}`)
+	resp, err := ts.Client().Post(ts.URL+"/api/chat", "application/json", body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	assert.Equal(t, 2, resp.ProtoMajor)
+	assert.Empty(t, resp.Header.Get("Connection"))
+
+	// The first chunk arrives while the generation is still running
+	reader := bufio.NewReader(resp.Body)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(line, `"content":"a"`) {
+			break
+		}
+	}
+	close(fake.gate)
+}
+
+func TestServer_ServesEmbeddedUI(t *testing.T) {
+	s, err := New(Handlers{}, Config{})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+	assert.NotEmpty(t, rec.Header().Get("ETag"))
+}
+
+// readyBackends reports a single ready backend
+type readyBackends struct{}
+
+func (readyBackends) Statuses() []client.BackendStatus {
+	return []client.BackendStatus{{Name: "default", State: client.StateReady}}
+}
+
+func TestServer_HealthProbes(t *testing.T) {
+	health := handlers.NewHealthHandler(readyBackends{}, handlers.NewServiceMonitor(nil))
+	s, err := New(Handlers{Health: health}, Config{StaticDir: t.TempDir()})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	for _, path := range []string{"/healthz", "/readyz"} {
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, http.StatusOK, rec.Code, path)
+		assert.Contains(t, rec.Header().Get("Content-Type"), "application/json", path)
+	}
+}
+
+func TestServer_PresetsOfBackendModels(t *testing.T) {
+	presets := handlers.NewModelPresets(filepath.Join(t.TempDir(), "model-presets.json"))
+	s, err := New(Handlers{Presets: handlers.NewPresetsHandler(presets)}, Config{StaticDir: t.TempDir()})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	req := httptest.NewRequest(http.MethodPut, "/api/settings/presets/desktop/qwen2.5-32b", strings.NewReader(`{"temperature":0.4}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Contains(t, presets.All(), "desktop/qwen2.5-32b")
+}
+
+func TestServer_RateLimitsLogin(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("alice-password"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authenticator, err := auth.New(auth.Config{Users: []auth.UserConfig{{Username: "alice", PasswordHash: string(hash)}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := New(Handlers{Auth: authenticator}, Config{
+		StaticDir:  t.TempDir(),
+		RateLimits: ratelimit.Config{Login: ratelimit.Limit{PerMinute: 1, Burst: 2}},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	login := func(password string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/auth/login", strings.NewReader(`{"username":"alice","password":"`+password+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		return rec
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, login("guess-1").Code)
+	assert.Equal(t, http.StatusUnauthorized, login("guess-2").Code)
+	rec := login("alice-password")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code, "even the right password once limited")
+	assert.NotEmpty(t, rec.Header().Get("Retry-After"))
+}
@@ -0,0 +1,238 @@
+// Package static serves the files of the web UI, precompressed and with
+// cache headers
+package static
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/andybalholm/brotli"
+)
+
+// Cache-Control values. Asset URLs carrying the hash of their content never
+// change, so browsers keep them; anything else is revalidated with its ETag.
+const (
+	cacheImmutable  = "public, max-age=31536000, immutable"
+	cacheRevalidate = "no-cache"
+)
+
+// Content encodings, in order of preference
+const (
+	encodingBrotli   = "br"
+	encodingGzip     = "gzip"
+	encodingIdentity = ""
+)
+
+// minSavings is the fraction of its size a compressed variant must save to
+// be kept
+const minSavings = 0.1
+
+// variant is a file in one content encoding
+type variant struct {
+	encoding string
+	data     []byte
+	etag     string
+}
+
+// asset is a file with its precompressed variants
+type asset struct {
+	contentType string
+	hash        string    // Short hash of the content, used to version URLs
+	variants    []variant // Preferred encoding first; the identity variant is last
+}
+
+// Handler serves files read once from a file system, such as the embedded web
+// UI. References to other files in HTML files are versioned with the hash of
+// their content.
+type Handler struct {
+	assets map[string]*asset
+}
+
+// New reads the files of fsys and precompresses them
+func New(fsys fs.FS) (*Handler, error) {
+	files := make(map[string][]byte)
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || path.Ext(name) == ".go" {
+			return err
+		}
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		files[name] = data
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read web assets: %w", err)
+	}
+
+	hashes := make(map[string]string)
+	for name, data := range files {
+		if path.Ext(name) != ".html" {
+			hashes[name] = hash(data)
+		}
+	}
+
+	h := &Handler{assets: make(map[string]*asset)}
+	for name, data := range files {
+		if path.Ext(name) == ".html" {
+			data = versionReferences(data, path.Dir(name), hashes)
+		}
+		a, err := newAsset(name, data)
+		if err != nil {
+			return nil, err
+		}
+		h.assets[name] = a
+	}
+
+	return h, nil
+}
+
+// versionReferences appends ?v=<hash> to the src and href attributes of an
+// HTML file in dir that name other files, so they can be cached for good
+func versionReferences(html []byte, dir string, hashes map[string]string) []byte {
+	for name, h := range hashes {
+		rel := name
+		if dir != "." {
+			var ok bool
+			if rel, ok = strings.CutPrefix(name, dir+"/"); !ok {
+				continue
+			}
+		}
+		for _, attr := range []string{"src", "href"} {
+			html = bytes.ReplaceAll(html,
+				[]byte(fmt.Sprintf(`%s="%s"`, attr, rel)),
+				[]byte(fmt.Sprintf(`%s="%s?v=%s"`, attr, rel, h)))
+		}
+	}
+	return html
+}
+
+// newAsset precompresses a file
+func newAsset(name string, data []byte) (*asset, error) {
+	contentType := mime.TypeByExtension(path.Ext(name))
+	if contentType == "" {
+		contentType = http.DetectContentType(data)
+	}
+	a := &asset{contentType: contentType, hash: hash(data)}
+
+	for _, encoding := range []string{encodingBrotli, encodingGzip} {
+		compressed, err := compress(encoding, data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compress %s: %w", name, err)
+		}
+		if float64(len(compressed)) <= float64(len(data))*(1-minSavings) {
+			a.variants = append(a.variants, variant{
+				encoding: encoding,
+				data:     compressed,
+				etag:     fmt.Sprintf(`"%s-%s"`, a.hash, encoding),
+			})
+		}
+	}
+	a.variants = append(a.variants, variant{data: data, etag: fmt.Sprintf(`"%s"`, a.hash)})
+
+	return a, nil
+}
+
+// compress encodes data with the best compression of encoding
+func compress(encoding string, data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch encoding {
+	case encodingBrotli:
+		w = brotli.NewWriterLevel(&buf, brotli.BestCompression)
+	case encodingGzip:
+		gw, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+		if err != nil {
+			return nil, err
+		}
+		w = gw
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// hash returns a short hash of data
+func hash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+// ServeHTTP serves a file, index.html for directories, in the best encoding
+// the client accepts. Conditional and range requests are handled by
+// http.ServeContent.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+	if name == "" || strings.HasSuffix(r.URL.Path, "/") {
+		name = path.Join(name, "index.html")
+	}
+
+	a, ok := h.assets[name]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	v := a.variants[len(a.variants)-1]
+	for _, candidate := range a.variants {
+		if candidate.encoding != encodingIdentity && acceptsEncoding(r.Header.Get("Accept-Encoding"), candidate.encoding) {
+			v = candidate
+			break
+		}
+	}
+
+	header := w.Header()
+	if r.URL.Query().Get("v") == a.hash {
+		header.Set("Cache-Control", cacheImmutable)
+	} else {
+		header.Set("Cache-Control", cacheRevalidate)
+	}
+	if len(a.variants) > 1 {
+		header.Add("Vary", "Accept-Encoding")
+	}
+	if v.encoding != encodingIdentity {
+		header.Set("Content-Encoding", v.encoding)
+	}
+	header.Set("Content-Type", a.contentType)
+	header.Set("ETag", v.etag)
+
+	http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(v.data))
+}
+
+// acceptsEncoding reports whether an Accept-Encoding header accepts encoding
+func acceptsEncoding(header, encoding string) bool {
+	for _, item := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(item), ";")
+		if !strings.EqualFold(strings.TrimSpace(coding), encoding) {
+			continue
+		}
+		q, found := strings.CutPrefix(strings.ReplaceAll(params, " ", ""), "q=")
+		return !found || strings.Trim(q, "0.") != ""
+	}
+	return false
+}
+
+// Dir serves the files of a directory as they are on disk, for editing the
+// web UI without rebuilding. Browsers revalidate every file.
+func Dir(dir string) http.Handler {
+	files := http.FileServer(http.Dir(dir))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", cacheRevalidate)
+		files.ServeHTTP(w, r)
+	})
+}
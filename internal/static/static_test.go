@@ -0,0 +1,127 @@
+package static
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/andybalholm/brotli"
+	"github.com/stretchr/testify/assert"
+)
+
+var testFiles = fstest.MapFS{
+	"index.html": {Data: []byte(`<link rel="stylesheet" href="styles.css"><script src="app.js"></script>`)},
+	"app.js":     {Data: []byte(strings.Repeat("console.log('hello');\n", 100))},
+	"styles.css": {Data: []byte(strings.Repeat("body { margin: 0; }\n", 100))},
+	"tiny.txt":   {Data: []byte("x")},
+	"embed.go":   {Data: []byte("package web")},
+}
+
+func newHandler(t *testing.T) *Handler {
+	h, err := New(testFiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func get(h http.Handler, target string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestHandler_VersionsReferences(t *testing.T) {
+	h := newHandler(t)
+
+	rec := get(h, "/", nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/html; charset=utf-8", rec.Header().Get("Content-Type"))
+	assert.Equal(t, "no-cache", rec.Header().Get("Cache-Control"))
+
+	jsHash := h.assets["app.js"].hash
+	assert.Contains(t, rec.Body.String(), `src="app.js?v=`+jsHash+`"`)
+	assert.Contains(t, rec.Body.String(), `href="styles.css?v=`+h.assets["styles.css"].hash+`"`)
+
+	// Versioned URLs can be cached for good, others are revalidated
+	rec = get(h, "/app.js?v="+jsHash, nil)
+	assert.Equal(t, "public, max-age=31536000, immutable", rec.Header().Get("Cache-Control"))
+	rec = get(h, "/app.js?v=stale", nil)
+	assert.Equal(t, "no-cache", rec.Header().Get("Cache-Control"))
+}
+
+func TestHandler_Precompressed(t *testing.T) {
+	h := newHandler(t)
+	want, _ := testFiles.ReadFile("app.js")
+
+	rec := get(h, "/app.js", map[string]string{"Accept-Encoding": "gzip, deflate, br"})
+	assert.Equal(t, "br", rec.Header().Get("Content-Encoding"))
+	assert.Equal(t, "Accept-Encoding", rec.Header().Get("Vary"))
+	body, err := io.ReadAll(brotli.NewReader(rec.Body))
+	assert.NoError(t, err)
+	assert.Equal(t, want, body)
+
+	rec = get(h, "/app.js", map[string]string{"Accept-Encoding": "gzip, br;q=0"})
+	assert.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+	zr, err := gzip.NewReader(rec.Body)
+	if !assert.NoError(t, err) {
+		return
+	}
+	body, err = io.ReadAll(zr)
+	assert.NoError(t, err)
+	assert.Equal(t, want, body)
+
+	rec = get(h, "/app.js", nil)
+	assert.Empty(t, rec.Header().Get("Content-Encoding"))
+	assert.Equal(t, want, rec.Body.Bytes())
+
+	// Files that don't compress are only served as they are
+	rec = get(h, "/tiny.txt", map[string]string{"Accept-Encoding": "br"})
+	assert.Empty(t, rec.Header().Get("Content-Encoding"))
+	assert.Empty(t, rec.Header().Get("Vary"))
+	assert.Equal(t, "x", rec.Body.String())
+}
+
+func TestHandler_ETag(t *testing.T) {
+	h := newHandler(t)
+
+	rec := get(h, "/styles.css", map[string]string{"Accept-Encoding": "gzip"})
+	etag := rec.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+	assert.NotEqual(t, etag, get(h, "/styles.css", nil).Header().Get("ETag"), "each encoding has its own ETag")
+
+	rec = get(h, "/styles.css", map[string]string{"Accept-Encoding": "gzip", "If-None-Match": etag})
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+	assert.Empty(t, rec.Body.Bytes())
+}
+
+func TestHandler_NotFound(t *testing.T) {
+	h := newHandler(t)
+
+	assert.Equal(t, http.StatusNotFound, get(h, "/missing.js", nil).Code)
+	assert.Equal(t, http.StatusNotFound, get(h, "/embed.go", nil).Code, "Go sources aren't served")
+	assert.Equal(t, http.StatusNotFound, get(h, "/../go.mod", nil).Code)
+}
+
+func TestDir(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "index.html"), []byte("<p>editing</p>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := get(Dir(dir), "/", nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "no-cache", rec.Header().Get("Cache-Control"))
+	assert.True(t, bytes.Contains(rec.Body.Bytes(), []byte("editing")))
+}
@@ -2,8 +2,15 @@
 let models = [];
 let currentModel = null;
 let conversationHistory = [];
+let conversationId = Date.now().toString(36) + Math.random().toString(36).slice(2, 10);
 let isStreaming = false;
 let currentStreamController = null;
+let currentChatId = null;
+let stopRequested = false;
+let currentUser = null;
+
+// Number of times a dropped chat stream is resumed before giving up
+const MAX_RESUME_ATTEMPTS = 5;
 
 // DOM elements
 const modelSelect = document.getElementById('model-select');
@@ -14,7 +21,11 @@ const messagesContainer = document.getElementById('messages');
 
 // Initialize
 document.addEventListener('DOMContentLoaded', () => {
-    loadModels();
+    checkAuth().then((signedIn) => {
+        if (signedIn) {
+            loadModels();
+        }
+    });
 
     modelSelect.addEventListener('change', (e) => {
         const selectedModel = e.target.value;
@@ -32,19 +43,101 @@ document.addEventListener('DOMContentLoaded', () => {
         }
     });
 
-    sendButton.addEventListener('click', sendMessage);
+    sendButton.addEventListener('click', () => {
+        if (isStreaming) {
+            stopMessage();
+        } else {
+            sendMessage();
+        }
+    });
     unloadButton.addEventListener('click', unloadModel);
     
     messageInput.addEventListener('keydown', (e) => {
         if (e.key === 'Enter' && !e.shiftKey) {
             e.preventDefault();
-            if (!sendButton.disabled) {
+            if (!sendButton.disabled && !isStreaming) {
                 sendMessage();
             }
         }
     });
 });
 
+// Check who is signed in, showing the sign-in form when nobody is
+async function checkAuth() {
+    try {
+        const response = await fetch('/api/auth/me');
+        if (response.status === 401) {
+            showLoginPanel();
+            return false;
+        }
+        if (response.ok) {
+            currentUser = await response.json();
+            applyRole();
+        }
+    } catch (error) {
+        console.error('Error checking sign-in:', error);
+    }
+    return true;
+}
+
+// Hide what only admins may use and offer signing out of sessions
+function applyRole() {
+    if (currentUser.role !== 'admin') {
+        for (const id of ['switch-model-btn', 'unload-button', 'settings-btn']) {
+            const button = document.getElementById(id);
+            if (button) {
+                button.style.display = 'none';
+            }
+        }
+    }
+
+    const logoutBtn = document.getElementById('logout-btn');
+    if (logoutBtn && currentUser.method === 'session') {
+        logoutBtn.classList.remove('hidden');
+        logoutBtn.title = `Sign out ${currentUser.name}`;
+        logoutBtn.addEventListener('click', logout);
+    }
+}
+
+// Show the sign-in form
+function showLoginPanel() {
+    const panel = document.getElementById('login-panel');
+    const form = document.getElementById('login-form');
+    panel.classList.remove('hidden');
+    form.addEventListener('submit', login);
+    document.getElementById('login-username').focus();
+}
+
+// Sign in with the username and password of the form
+async function login(e) {
+    e.preventDefault();
+    const status = document.getElementById('login-status');
+
+    try {
+        const response = await fetch('/api/auth/login', {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({
+                username: document.getElementById('login-username').value,
+                password: document.getElementById('login-password').value,
+            }),
+        });
+        if (!response.ok) {
+            throw new Error((await response.text()).trim() || response.statusText);
+        }
+        window.location.reload();
+    } catch (error) {
+        status.textContent = `✗ ${error.message}`;
+        status.classList.add('error');
+    }
+}
+
+// Sign out of the current session
+async function logout() {
+    await fetch('/api/auth/logout', { method: 'POST' });
+    window.location.reload();
+}
+
 // Load available models
 async function loadModels() {
     try {
@@ -107,8 +200,9 @@ async function sendMessage() {
     // Clear input and disable
     messageInput.value = '';
     messageInput.disabled = true;
-    sendButton.disabled = true;
     isStreaming = true;
+    stopRequested = false;
+    updateSendButtonState();
     
     // Create assistant message placeholder
     const assistantMessageId = addMessage('assistant', '', true);
@@ -119,7 +213,7 @@ async function sendMessage() {
         // Create abort controller for cancellation
         currentStreamController = new AbortController();
         
-        const response = await fetch('/api/chat', {
+        let response = await fetch('/api/chat', {
             method: 'POST',
             headers: {
                 'Content-Type': 'application/json',
@@ -127,57 +221,119 @@ async function sendMessage() {
             body: JSON.stringify({
                 model: currentModel,
                 messages: conversationHistory,
+                conversation_id: conversationId,
                 stream: true,
             }),
             signal: currentStreamController.signal,
         });
         
+        if (response.status === 429) {
+            const retryAfter = response.headers.get('Retry-After');
+            throw new Error(`Too many requests, try again in ${retryAfter || 'a few'} seconds`);
+        }
         if (!response.ok) {
             throw new Error(`Chat request failed: ${response.statusText}`);
         }
         
-        // Read streaming response
-        const reader = response.body.getReader();
+        // Read streaming response, resuming it if the connection drops
+        const chatId = response.headers.get('X-Chat-ID');
+        currentChatId = chatId;
         const decoder = new TextDecoder();
         let buffer = '';
         let assistantContent = '';
+        let eventName = '';
+        let lastEventId = '';
+        let sawDone = false;
+        let resumeAttempts = 0;
         
         while (true) {
-            const { done, value } = await reader.read();
-            
-            if (done) {
-                break;
-            }
-            
-            buffer += decoder.decode(value, { stream: true });
-            const lines = buffer.split('\n');
-            buffer = lines.pop(); // Keep incomplete line in buffer
-            
-            for (const line of lines) {
-                if (line.startsWith('data: ')) {
-                    try {
-                        const data = JSON.parse(line.slice(6));
+            try {
+                const reader = response.body.getReader();
+                while (true) {
+                    const { done, value } = await reader.read();
+                    
+                    if (done) {
+                        break;
+                    }
+                    
+                    buffer += decoder.decode(value, { stream: true });
+                    const lines = buffer.split('\n');
+                    buffer = lines.pop(); // Keep incomplete line in buffer
+                    
+                    for (const line of lines) {
+                        if (line.startsWith('id: ')) {
+                            lastEventId = line.slice(4).trim();
+                        } else if (line.startsWith('event: ')) {
+                            eventName = line.slice(7).trim();
+                        } else if (line === '') {
+                            eventName = '';
+                        } else if (line.startsWith('data: ') && eventName) {
+                            // Named events report progress rather than content
+                            try {
+                                const data = JSON.parse(line.slice(6));
+                                if (eventName === 'server_shutting_down') {
+                                    showStreamNotice(assistantMessageEl, 'The server is restarting; this reply may be cut short.');
+                                }
+                                if (assistantContent === '') {
+                                    if (eventName === 'queue') {
+                                        contentEl.textContent = `Queued (position ${data.position})…`;
+                                    } else if (eventName === 'backend') {
+                                        contentEl.textContent = `Waiting for backend (${data.state})…`;
+                                    }
+                                }
+                            } catch (e) {
+                                console.error('Error parsing stream event:', e);
+                            }
+                        } else if (line.startsWith('data: ')) {
+                            try {
+                                const data = JSON.parse(line.slice(6));
+                                if (data.done) {
+                                    sawDone = true;
+                                }
                         
-                        if (data.error) {
-                            throw new Error(data.error);
-                        }
+                                if (data.error) {
+                                    throw new Error(data.error);
+                                }
                         
-                        if (data.message && data.message.content) {
-                            assistantContent += data.message.content;
-                            contentEl.textContent = assistantContent;
-                            scrollToBottom();
-                        }
+                                if (data.message && data.message.content) {
+                                    assistantContent += data.message.content;
+                                    contentEl.textContent = assistantContent;
+                                    scrollToBottom();
+                                }
                         
-                        if (data.done) {
-                            // Update conversation history with complete message
-                            conversationHistory.push({ role: 'assistant', content: assistantContent });
-                            assistantMessageEl.classList.remove('streaming');
-                            break;
+                                if (data.done) {
+                                    // Update conversation history with complete message and its stats
+                                    const assistantMessage = { role: 'assistant', content: assistantContent };
+                                    if (data.usage) {
+                                        assistantMessage.usage = data.usage;
+                                    }
+                                    if (data.timings) {
+                                        assistantMessage.timings = data.timings;
+                                    }
+                                    conversationHistory.push(assistantMessage);
+                                    showMessageStats(assistantMessageEl, assistantMessage);
+                                    assistantMessageEl.classList.remove('streaming');
+                                    break;
+                                }
+                            } catch (e) {
+                                console.error('Error parsing stream data:', e);
+                            }
                         }
-                    } catch (e) {
-                        console.error('Error parsing stream data:', e);
                     }
                 }
+                
+                if (sawDone || !chatId) {
+                    break;
+                }
+                throw new Error('Stream ended unexpectedly');
+            } catch (error) {
+                if (error.name === 'AbortError' || !chatId || resumeAttempts >= MAX_RESUME_ATTEMPTS) {
+                    throw error;
+                }
+                resumeAttempts++;
+                response = await resumeStream(chatId, lastEventId, resumeAttempts, currentStreamController.signal);
+                buffer = '';
+                eventName = '';
             }
         }
         
@@ -186,7 +342,7 @@ async function sendMessage() {
         }
         
     } catch (error) {
-        if (error.name === 'AbortError') {
+        if (error.name === 'AbortError' || stopRequested) {
             contentEl.textContent = '(Cancelled)';
         } else {
             console.error('Error sending message:', error);
@@ -196,6 +352,7 @@ async function sendMessage() {
     } finally {
         isStreaming = false;
         currentStreamController = null;
+        currentChatId = null;
         messageInput.disabled = false;
         updateSendButtonState();
         updateUnloadButtonState();
@@ -207,6 +364,34 @@ async function sendMessage() {
     }
 }
 
+// Stop the running chat. Aborting the request alone would leave the server
+// generating, waiting for the client to resume, so cancel it there first.
+async function stopMessage() {
+    stopRequested = true;
+    if (currentChatId) {
+        try {
+            await fetch(`/api/chat/${currentChatId}/cancel`, { method: 'POST' });
+        } catch (error) {
+            console.error('Error cancelling chat:', error);
+        }
+    }
+    if (currentStreamController) {
+        currentStreamController.abort();
+    }
+}
+
+// Reconnect to a running chat, replaying the events after lastEventId
+async function resumeStream(chatId, lastEventId, attempt, signal) {
+    await new Promise(resolve => setTimeout(resolve, 1000 * attempt));
+    
+    const headers = lastEventId ? { 'Last-Event-ID': lastEventId } : {};
+    const response = await fetch(`/api/chat/${chatId}/stream`, { headers, signal });
+    if (!response.ok) {
+        throw new Error(`Failed to resume chat: ${response.statusText}`);
+    }
+    return response;
+}
+
 // Add message to UI
 function addMessage(role, content, streaming = false) {
     const messageId = `msg-${Date.now()}-${Math.random().toString(36).substr(2, 9)}`;
@@ -225,6 +410,37 @@ function addMessage(role, content, streaming = false) {
     return messageId;
 }
 
+// Show token usage and generation speed under a message
+function showMessageStats(messageEl, message) {
+    const parts = [];
+    const tokens = message.usage ? message.usage.completion_tokens : message.timings && message.timings.predicted_n;
+    if (tokens) {
+        parts.push(`${tokens} tokens`);
+    }
+    if (message.timings && message.timings.predicted_per_second) {
+        parts.push(`${message.timings.predicted_per_second.toFixed(1)} tok/s`);
+    }
+    if (message.timings && message.timings.prompt_per_second) {
+        parts.push(`prompt ${message.timings.prompt_per_second.toFixed(1)} tok/s`);
+    }
+    if (parts.length === 0) {
+        return;
+    }
+    
+    const statsEl = document.createElement('div');
+    statsEl.className = 'stats';
+    statsEl.textContent = parts.join(' · ');
+    messageEl.appendChild(statsEl);
+}
+
+// Show a notice about the stream under a message
+function showStreamNotice(messageEl, text) {
+    const noticeEl = document.createElement('div');
+    noticeEl.className = 'stats';
+    noticeEl.textContent = text;
+    messageEl.appendChild(noticeEl);
+}
+
 // Add system/error message
 function addSystemMessage(content) {
     const messageEl = document.createElement('div');
@@ -285,7 +501,9 @@ async function unloadModel() {
 
 // Update send button state
 function updateSendButtonState() {
-    sendButton.disabled = !currentModel || isStreaming || messageInput.value.trim() === '';
+    // While a reply streams, the button stops it
+    sendButton.textContent = isStreaming ? 'Stop' : 'Send';
+    sendButton.disabled = isStreaming ? false : !currentModel || messageInput.value.trim() === '';
 }
 
 // Update unload button state
@@ -898,11 +1116,50 @@ async function loadToolSettings() {
         if (toolSentinel) {
             toolSentinel.checked = data.enable_sentinel || false;
         }
+
+        await loadToolAvailability();
     } catch (error) {
         console.error('Error loading tool settings:', error);
     }
 }
 
+// Tools whose service is down are disabled by the server; show why next to
+// their toggles
+const toolToggleIds = {
+    web_search: 'tool-web-search',
+    get_news: 'tool-feeds',
+    analyze_portfolio: 'tool-sentinel',
+};
+
+async function loadToolAvailability() {
+    const response = await fetch('/api/me/tools');
+    if (!response.ok) {
+        return;
+    }
+
+    const data = await response.json();
+    for (const tool of data.tools || []) {
+        const input = document.getElementById(toolToggleIds[tool.name]);
+        if (!input) {
+            continue;
+        }
+        const toggle = input.closest('.tool-toggle');
+        let note = toggle.querySelector('.tool-unavailable');
+        if (tool.available) {
+            if (note) {
+                note.remove();
+            }
+            continue;
+        }
+        if (!note) {
+            note = document.createElement('p');
+            note.className = 'tool-unavailable';
+            toggle.appendChild(note);
+        }
+        note.textContent = `⚠ Unavailable: ${tool.reason}`;
+    }
+}
+
 async function saveToolSettings() {
     try {
         const toolWebSearch = document.getElementById('tool-web-search');
@@ -0,0 +1,9 @@
+// Package web holds the web UI, embedded into the server binary
+package web
+
+import "embed"
+
+// FS contains the files of the web UI
+//
+//go:embed index.html app.js styles.css
+var FS embed.FS